@@ -0,0 +1,314 @@
+package llmcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"maps"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// AdaptiveThresholdOptions contains options for configuring the adaptive thresholds of the LRUSimilarityEngine.
+// The engine keeps a threshold per region of the embedding space, which is lowered by reported bad hits
+// and raised by reported good hits of the region.
+type AdaptiveThresholdOptions struct {
+	// Region returns the region of a cached prompt, e.g. a tag. By default the region is the
+	// IVF cluster of the prompt if an IVF index is configured, otherwise there is a single region.
+	// The clusters change whenever the index is trained, so the threshold of each cluster moves to
+	// the new cluster with the closest centroid. After a restart the thresholds are learned again.
+	Region func(prompt string) string
+	// LearningRate is the fraction of the threshold range by which a threshold is adjusted per feedback. Default is 0.05.
+	LearningRate float32
+	// MinThreshold is the lower bound of the thresholds. Default is half of the threshold below it.
+	MinThreshold float32
+	// MaxThreshold is the upper bound of the thresholds. Default is half of the threshold above it.
+	MaxThreshold float32
+	// Store persists the learned thresholds. If nil, the thresholds are kept in memory.
+	Store ThresholdStore
+}
+
+// ThresholdStore persists the learned thresholds of the regions.
+type ThresholdStore interface {
+	// Load returns the stored thresholds, or nil if there are none.
+	Load(ctx context.Context) (map[string]float32, error)
+
+	// Save stores the thresholds.
+	Save(ctx context.Context, thresholds map[string]float32) error
+}
+
+// Compile time check to ensure MemoryThresholdStore satisfies the ThresholdStore interface.
+var _ ThresholdStore = (*MemoryThresholdStore)(nil)
+
+// MemoryThresholdStore is a ThresholdStore keeping the thresholds in memory.
+type MemoryThresholdStore struct {
+	mu         sync.RWMutex
+	thresholds map[string]float32
+}
+
+// NewMemoryThresholdStore creates a new MemoryThresholdStore instance.
+func NewMemoryThresholdStore() *MemoryThresholdStore {
+	return &MemoryThresholdStore{}
+}
+
+// Load returns the stored thresholds, or nil if there are none.
+func (s *MemoryThresholdStore) Load(ctx context.Context) (map[string]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return maps.Clone(s.thresholds), nil
+}
+
+// Save stores the thresholds.
+func (s *MemoryThresholdStore) Save(ctx context.Context, thresholds map[string]float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.thresholds = maps.Clone(thresholds)
+
+	return nil
+}
+
+// Compile time check to ensure FileThresholdStore satisfies the ThresholdStore interface.
+var _ ThresholdStore = (*FileThresholdStore)(nil)
+
+// FileThresholdStore is a ThresholdStore keeping the thresholds in a JSON file.
+type FileThresholdStore struct {
+	mu   sync.Mutex
+	path string
+}
+
+// NewFileThresholdStore creates a new FileThresholdStore instance storing the thresholds in the file at path.
+func NewFileThresholdStore(path string) *FileThresholdStore {
+	return &FileThresholdStore{
+		path: path,
+	}
+}
+
+// Load returns the stored thresholds, or nil if there are none.
+func (s *FileThresholdStore) Load(ctx context.Context) (map[string]float32, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := os.ReadFile(s.path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	var thresholds map[string]float32
+	if err := json.Unmarshal(b, &thresholds); err != nil {
+		return nil, err
+	}
+
+	return thresholds, nil
+}
+
+// Save stores the thresholds. The file is replaced atomically.
+func (s *FileThresholdStore) Save(ctx context.Context, thresholds map[string]float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	b, err := json.Marshal(thresholds)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+
+	defer os.Remove(tmp.Name()) // nolint errcheck
+
+	if _, err := tmp.Write(b); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), s.path)
+}
+
+// adaptiveThresholds holds the learned thresholds of the regions.
+type adaptiveThresholds struct {
+	mu   sync.RWMutex
+	opts AdaptiveThresholdOptions
+	// threshold is the threshold of regions without feedback.
+	threshold float32
+	// thresholds holds the learned threshold of each region.
+	thresholds map[string]float32
+	// version is incremented when the thresholds change.
+	version uint64
+	// saveMu serializes the saves, so that the store is not accessed while holding mu.
+	saveMu sync.Mutex
+	// saved is the version of the saved thresholds.
+	saved uint64
+}
+
+// newAdaptiveThresholds creates a new adaptiveThresholds instance and loads the stored thresholds.
+func newAdaptiveThresholds(ctx context.Context, opts AdaptiveThresholdOptions, threshold float32) (*adaptiveThresholds, error) {
+	if opts.LearningRate <= 0 {
+		opts.LearningRate = 0.05
+	}
+
+	if opts.MinThreshold == 0 && opts.MaxThreshold == 0 {
+		delta := abs(threshold) / 2
+		opts.MinThreshold = threshold - delta
+		opts.MaxThreshold = threshold + delta
+	}
+
+	if opts.MinThreshold > opts.MaxThreshold {
+		return nil, errors.New("minimum threshold must not be greater than maximum threshold")
+	}
+
+	if opts.Store == nil {
+		opts.Store = NewMemoryThresholdStore()
+	}
+
+	thresholds, err := opts.Store.Load(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if thresholds == nil {
+		thresholds = make(map[string]float32)
+	}
+
+	return &adaptiveThresholds{
+		opts:       opts,
+		threshold:  threshold,
+		thresholds: thresholds,
+	}, nil
+}
+
+// get returns the threshold of the region.
+func (a *adaptiveThresholds) get(region string) float32 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	if t, ok := a.thresholds[region]; ok {
+		return t
+	}
+
+	return a.threshold
+}
+
+// upper returns the upper bound of all thresholds.
+func (a *adaptiveThresholds) upper() float32 {
+	return max(a.opts.MaxThreshold, a.threshold)
+}
+
+// report adjusts the threshold of the region to a good or bad hit with the given distance.
+// A bad hit lowers the threshold below its distance, a good hit raises the threshold.
+// The thresholds are saved to the store.
+func (a *adaptiveThresholds) report(ctx context.Context, region string, distance float32, good bool) error {
+	a.mu.Lock()
+
+	t, ok := a.thresholds[region]
+	if !ok {
+		t = a.threshold
+	}
+
+	step := a.opts.LearningRate * (a.opts.MaxThreshold - a.opts.MinThreshold)
+
+	if good {
+		t += step
+	} else {
+		t = min(t, distance) - step
+	}
+
+	a.thresholds[region] = min(max(t, a.opts.MinThreshold), a.opts.MaxThreshold)
+	version, thresholds := a.change()
+
+	a.mu.Unlock()
+
+	return a.save(ctx, version, thresholds)
+}
+
+// remap moves the thresholds of the regions with the prefix to the regions returned by fn, or removes
+// them if fn returns false, and saves the thresholds. If several regions are moved to the same region,
+// it keeps the lowest of their thresholds.
+func (a *adaptiveThresholds) remap(ctx context.Context, prefix string, fn func(region string) (string, bool)) error {
+	a.mu.Lock()
+
+	remapped := make(map[string]float32, len(a.thresholds))
+
+	for region, t := range a.thresholds {
+		if strings.HasPrefix(region, prefix) {
+			var ok bool
+			if region, ok = fn(region); !ok {
+				continue
+			}
+		}
+
+		if prev, ok := remapped[region]; ok {
+			t = min(t, prev)
+		}
+
+		remapped[region] = t
+	}
+
+	if maps.Equal(remapped, a.thresholds) {
+		a.mu.Unlock()
+		return nil
+	}
+
+	a.thresholds = remapped
+	version, thresholds := a.change()
+
+	a.mu.Unlock()
+
+	return a.save(ctx, version, thresholds)
+}
+
+// change records a change of the thresholds and returns the new version and a copy of the
+// thresholds to save. The caller must hold the lock.
+func (a *adaptiveThresholds) change() (uint64, map[string]float32) {
+	a.version++
+	return a.version, maps.Clone(a.thresholds)
+}
+
+// save saves the thresholds of the version unless a later version was saved already.
+func (a *adaptiveThresholds) save(ctx context.Context, version uint64, thresholds map[string]float32) error {
+	a.saveMu.Lock()
+	defer a.saveMu.Unlock()
+
+	if version <= a.saved {
+		return nil
+	}
+
+	if err := a.opts.Store.Save(ctx, thresholds); err != nil {
+		return err
+	}
+
+	a.saved = version
+
+	return nil
+}
+
+// snapshot returns a copy of the learned thresholds.
+func (a *adaptiveThresholds) snapshot() map[string]float32 {
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	return maps.Clone(a.thresholds)
+}
+
+// abs returns the absolute value of x.
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
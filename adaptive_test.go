@@ -0,0 +1,252 @@
+package llmcache
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveThresholds(t *testing.T) {
+	ctx := context.TODO()
+
+	a, err := newAdaptiveThresholds(ctx, AdaptiveThresholdOptions{LearningRate: 0.1}, 0.2)
+	assert.NoError(t, err)
+	assert.Equal(t, float32(0.1), a.opts.MinThreshold)
+	assert.Equal(t, float32(0.3), a.opts.MaxThreshold)
+
+	t.Run("Bad Hit", func(t *testing.T) {
+		err := a.report(ctx, "bad", 0.15, false)
+		assert.NoError(t, err)
+		assert.InDelta(t, 0.13, a.get("bad"), 1e-6)
+
+		// Bounded by the minimum threshold
+		err = a.report(ctx, "bad", 0.05, false)
+		assert.NoError(t, err)
+		assert.InDelta(t, 0.1, a.get("bad"), 1e-6)
+	})
+
+	t.Run("Good Hit", func(t *testing.T) {
+		for i := 0; i < 20; i++ {
+			err := a.report(ctx, "good", 0.1, true)
+			assert.NoError(t, err)
+		}
+
+		assert.InDelta(t, 0.3, a.get("good"), 1e-6)
+	})
+
+	t.Run("Default", func(t *testing.T) {
+		assert.Equal(t, float32(0.2), a.get("other"))
+	})
+
+	t.Run("Invalid Bounds", func(t *testing.T) {
+		_, err := newAdaptiveThresholds(ctx, AdaptiveThresholdOptions{MinThreshold: 0.3, MaxThreshold: 0.1}, 0.2)
+		assert.Error(t, err)
+	})
+
+	t.Run("Remap", func(t *testing.T) {
+		store := NewMemoryThresholdStore()
+		assert.NoError(t, store.Save(ctx, map[string]float32{"ivf-0": 0.1, "ivf-1": 0.3, "ivf-2": 0.25, "math": 0.15}))
+
+		a, err := newAdaptiveThresholds(ctx, AdaptiveThresholdOptions{Store: store}, 0.2)
+		assert.NoError(t, err)
+
+		// Regions moved to the same region keep the lowest threshold, regions without a target are removed
+		assert.NoError(t, a.remap(ctx, "ivf-", func(region string) (string, bool) {
+			return "ivf-1", region != "ivf-2"
+		}))
+		assert.Equal(t, map[string]float32{"ivf-1": 0.1, "math": 0.15}, a.snapshot())
+
+		stored, err := store.Load(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]float32{"ivf-1": 0.1, "math": 0.15}, stored)
+	})
+
+	t.Run("Save Outside Lock", func(t *testing.T) {
+		store := &blockingThresholdStore{release: make(chan struct{}), saving: make(chan struct{}, 1)}
+
+		a, err := newAdaptiveThresholds(ctx, AdaptiveThresholdOptions{Store: store}, 0.2)
+		assert.NoError(t, err)
+
+		done := make(chan error)
+
+		go func() {
+			done <- a.report(ctx, "math", 0.15, false)
+		}()
+
+		<-store.saving
+
+		// Lookups are not blocked by a slow store
+		assert.Less(t, a.get("math"), float32(0.15))
+
+		close(store.release)
+		assert.NoError(t, <-done)
+	})
+}
+
+// blockingThresholdStore is a ThresholdStore blocking saves until it is released.
+type blockingThresholdStore struct {
+	MemoryThresholdStore
+	release chan struct{}
+	saving  chan struct{}
+}
+
+func (s *blockingThresholdStore) Save(ctx context.Context, thresholds map[string]float32) error {
+	s.saving <- struct{}{}
+	<-s.release
+
+	return s.MemoryThresholdStore.Save(ctx, thresholds)
+}
+
+func TestFileThresholdStore(t *testing.T) {
+	ctx := context.TODO()
+	store := NewFileThresholdStore(filepath.Join(t.TempDir(), "thresholds.json"))
+
+	thresholds, err := store.Load(ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, thresholds)
+
+	err = store.Save(ctx, map[string]float32{"a": 0.1, "b": 0.25})
+	assert.NoError(t, err)
+
+	thresholds, err = store.Load(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]float32{"a": 0.1, "b": 0.25}, thresholds)
+}
+
+func TestLRUSimilarityEngine_AdaptiveThreshold(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"math: what is 2 plus 2?":       {1, 0, 0, 0},
+			"math: what is 2 plus 3?":       {1, 0.3, 0, 0}, // distance ~0.042
+			"chat: how are you?":            {0, 1, 0, 0},
+			"chat: how are you doing?":      {0, 1, 0.3, 0}, // distance ~0.042
+			"chat: how are you doing today": {0, 1, 0.6, 0}, // distance ~0.14
+		},
+	}
+
+	ctx := context.TODO()
+	path := filepath.Join(t.TempDir(), "thresholds.json")
+
+	newEngine := func() *LRUSimilarityEngine[string] {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Threshold = 0.1
+			o.AdaptiveThreshold = &AdaptiveThresholdOptions{
+				Region: func(prompt string) string {
+					tag, _, _ := strings.Cut(prompt, ":")
+					return tag
+				},
+				LearningRate: 0.2,
+				MinThreshold: 0.01,
+				MaxThreshold: 0.3,
+				Store:        NewFileThresholdStore(path),
+			}
+		})
+		assert.NoError(t, err)
+
+		err = cache.Update(ctx, "math: what is 2 plus 2?", "4")
+		assert.NoError(t, err)
+
+		err = cache.Update(ctx, "chat: how are you?", "fine")
+		assert.NoError(t, err)
+
+		return cache
+	}
+
+	cache := newEngine()
+
+	_, ok := cache.Lookup(ctx, "math: what is 2 plus 3?")
+	assert.True(t, ok)
+
+	err := cache.ReportBadHit(ctx, "math: what is 2 plus 3?", "math: what is 2 plus 2?")
+	assert.NoError(t, err)
+	assert.Less(t, cache.Thresholds()["math"], float32(0.042))
+
+	_, ok = cache.Lookup(ctx, "chat: how are you doing today")
+	assert.False(t, ok)
+
+	for i := 0; i < 5; i++ {
+		err = cache.ReportGoodHit(ctx, "chat: how are you doing?", "chat: how are you?")
+		assert.NoError(t, err)
+	}
+
+	assert.Greater(t, cache.Thresholds()["chat"], float32(0.14))
+
+	t.Run("Persisted", func(t *testing.T) {
+		cache := newEngine()
+
+		// The threshold of the math region was lowered
+		_, ok := cache.Lookup(ctx, "math: what is 2 plus 3?")
+		assert.False(t, ok)
+
+		// The threshold of the chat region was raised
+		_, ok = cache.Lookup(ctx, "chat: how are you doing today")
+		assert.True(t, ok)
+	})
+
+	t.Run("Not Cached", func(t *testing.T) {
+		err := cache.ReportBadHit(ctx, "math: what is 2 plus 3?", "unknown")
+		assert.ErrorIs(t, err, ErrNotCached)
+	})
+
+	t.Run("IVF Regions", func(t *testing.T) {
+		store := NewMemoryThresholdStore()
+		assert.NoError(t, store.Save(ctx, map[string]float32{"ivf-0": 0.01, "ivf-1": 0.3}))
+
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Threshold = 0.1
+			o.IVF = &IVFOptions{Lists: 2, Probes: 2, TrainingSize: 1000}
+			o.AdaptiveThreshold = &AdaptiveThresholdOptions{Store: store, MinThreshold: 0.01, MaxThreshold: 0.2}
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, cache.Update(ctx, "math: what is 2 plus 2?", "4"))
+		assert.NoError(t, cache.Update(ctx, "chat: how are you?", "fine"))
+
+		idx := cache.index.(*ivfIndex)
+
+		// The centroids of the lists before a restart are unknown, so their thresholds do not apply
+		idx.train()
+		assert.Empty(t, cache.Thresholds())
+
+		assert.NoError(t, cache.ReportBadHit(ctx, "math: what is 2 plus 3?", "math: what is 2 plus 2?"))
+
+		thresholds := cache.Thresholds()
+		assert.Len(t, thresholds, 1)
+
+		threshold := thresholds[cache.region(namespaceKey("", "math: what is 2 plus 2?"))]
+		assert.Less(t, threshold, float32(0.1))
+
+		// Retraining numbers the lists anew, the thresholds move to the closest lists
+		for i := 0; i < 5; i++ {
+			idx.train()
+			assert.Equal(t, map[string]float32{cache.region(namespaceKey("", "math: what is 2 plus 2?")): threshold}, cache.Thresholds())
+		}
+
+		_, ok := cache.Lookup(ctx, "math: what is 2 plus 3?")
+		assert.False(t, ok)
+
+		// The thresholds also survive a clear of the cache
+		assert.NoError(t, cache.Clear(ctx))
+		assert.NoError(t, cache.Update(ctx, "chat: how are you?", "fine"))
+		assert.NoError(t, cache.Update(ctx, "math: what is 2 plus 2?", "4"))
+
+		idx.train()
+		assert.Equal(t, map[string]float32{cache.region(namespaceKey("", "math: what is 2 plus 2?")): threshold}, cache.Thresholds())
+
+		stored, err := store.Load(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, cache.Thresholds(), stored)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder)
+		assert.NoError(t, err)
+
+		err = cache.ReportGoodHit(ctx, "chat: how are you doing?", "chat: how are you?")
+		assert.Error(t, err)
+		assert.Nil(t, cache.Thresholds())
+	})
+}
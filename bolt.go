@@ -0,0 +1,624 @@
+package llmcache
+
+import (
+	"cmp"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Compile time check to ensure BoltEngine satisfies the Engine interface.
+var _ Engine[any] = (*BoltEngine[any])(nil)
+
+// Compile time check to ensure BoltEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*BoltEngine[any])(nil)
+
+// Compile time check to ensure BoltEngine satisfies the Deleter interface.
+var _ Deleter = (*BoltEngine[any])(nil)
+
+// Compile time check to ensure BoltEngine satisfies the Namespacer interface.
+var _ Namespacer = (*BoltEngine[any])(nil)
+
+// Buckets of the BoltEngine, each keyed by the prompt qualified with its namespace.
+var (
+	boltResults    = []byte("results")
+	boltEmbeddings = []byte("embeddings")
+	boltAccess     = []byte("access")
+)
+
+// BoltEngineOptions contains options for configuring the BoltEngine.
+type BoltEngineOptions[T any] struct {
+	// DistanceFunc represents the distance function used for calculating the similarity between embeddings.
+	DistanceFunc DistanceFunc
+	// Threshold is the maximum distance allowed for a result to be considered a match.
+	Threshold float32
+	// MaxEntries is the maximum number of entries. Default is 10000.
+	MaxEntries int
+	// MaxBytes is the maximum size of the prompts, results and embeddings. Default is 0, no limit.
+	MaxBytes int64
+	// Codec serializes the results. Default is JSONCodec.
+	Codec Codec[T]
+	// Timeout is the time to wait for the file lock of the database. Default is 1 second.
+	Timeout time.Duration
+	// Namespaces contains the quotas of the namespaces. The size of a result is the size of its encoding.
+	Namespaces NamespaceOptions
+}
+
+// boltEntry is the in-memory state of an entry.
+type boltEntry struct {
+	// embedding is the embedding of the prompt, or nil if only exact matches are served.
+	embedding []float32
+	// access is the time of the last access in nanoseconds since the epoch.
+	access int64
+	// size is the size of the prompt, the result and the embedding.
+	size int64
+	// namespace is the namespace of the entry.
+	namespace string
+}
+
+// BoltEngine is a cache engine implementation which stores the results and embeddings in an
+// embedded bbolt database. The embeddings are loaded into memory on open for similarity search.
+// If the cache is full, the least recently accessed entries are evicted. The entries are partitioned
+// by the namespace of the context, see WithNamespace.
+type BoltEngine[T comparable] struct {
+	// db is the bbolt database.
+	db *bolt.DB
+	// embedder embeds the prompts, or is nil if only exact matches are served.
+	embedder Embedder
+	// opts contains options for configuring the BoltEngine.
+	opts BoltEngineOptions[T]
+	// mu guards the in-memory state and orders the writes.
+	mu sync.RWMutex
+	// entries holds the in-memory state of each prompt, keyed by namespace and prompt.
+	entries map[string]*boltEntry
+	// namespaces tracks the entries and statistics of the namespaces.
+	namespaces *namespaceTracker
+	// bytes is the total size of the entries.
+	bytes int64
+	// touched holds the prompts whose access time is not persisted yet.
+	touched map[string]struct{}
+	// now returns the current time.
+	now func() time.Time
+	// closed indicates whether the engine is closed.
+	closed bool
+}
+
+// NewBoltEngine creates a new BoltEngine instance storing its database in the file at path,
+// which is created if necessary. If the embedder is nil, only exact matches are served.
+func NewBoltEngine[T comparable](path string, embedder Embedder, optFns ...func(o *BoltEngineOptions[T])) (*BoltEngine[T], error) {
+	opts := BoltEngineOptions[T]{
+		DistanceFunc: CosineDistance,
+		Threshold:    0.2,
+		MaxEntries:   10000,
+		Codec:        JSONCodec[T]{},
+		Timeout:      time.Second,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Codec == nil {
+		return nil, errors.New("codec is required")
+	}
+
+	if opts.DistanceFunc == nil {
+		return nil, errors.New("distance function is required")
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: opts.Timeout})
+	if err != nil {
+		return nil, err
+	}
+
+	e := &BoltEngine[T]{
+		db:         db,
+		embedder:   embedder,
+		opts:       opts,
+		entries:    make(map[string]*boltEntry),
+		namespaces: newNamespaceTracker(opts.Namespaces),
+		touched:    make(map[string]struct{}),
+		now:        time.Now,
+	}
+
+	if err := e.load(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// load creates the buckets, loads the entries into memory and evicts entries exceeding the limits.
+func (e *BoltEngine[T]) load() error {
+	return e.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltResults, boltEmbeddings, boltAccess} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		embeddings, access := tx.Bucket(boltEmbeddings), tx.Bucket(boltAccess)
+
+		err := tx.Bucket(boltResults).ForEach(func(k, v []byte) error {
+			namespace, prompt := splitNamespaceKey(string(k))
+			entry := &boltEntry{size: int64(len(k) + len(v)), namespace: namespace}
+
+			if data := embeddings.Get(k); data != nil {
+				entry.embedding = decodeFloats(data)
+				entry.size += int64(len(data))
+			}
+
+			if data := access.Get(k); len(data) == 8 {
+				entry.access = int64(binary.BigEndian.Uint64(data))
+			}
+
+			e.entries[string(k)] = entry
+			e.bytes += entry.size
+			e.namespaces.addSize(string(k), int64(len(prompt)+len(v)))
+
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		return e.evict(tx, "")
+	})
+}
+
+// Lookup retrieves the cached result associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (e *BoltEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	match, ok := e.LookupMatch(ctx, prompt)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the result of the prompt, or the closest cached entry within the
+// threshold distance if the prompt is not cached.
+func (e *BoltEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	namespace := NamespaceFromContext(ctx)
+
+	match, ok := e.lookupMatch(ctx, namespace, prompt)
+	e.namespaces.lookup(namespace, ok)
+
+	return match, ok
+}
+
+// lookupMatch retrieves the result of the prompt in the namespace, or the closest cached entry
+// of the namespace within the threshold distance.
+func (e *BoltEngine[T]) lookupMatch(ctx context.Context, namespace, prompt string) (Match[T], bool) {
+	key := namespaceKey(namespace, prompt)
+
+	e.mu.RLock()
+	_, exact := e.entries[key]
+	e.mu.RUnlock()
+
+	if exact {
+		result, ok := e.result(key)
+		if !ok {
+			return Match[T]{}, false
+		}
+
+		return Match[T]{Result: result, Prompt: prompt, Exact: true}, true
+	}
+
+	if e.embedder == nil {
+		return Match[T]{}, false
+	}
+
+	embedding, err := e.embedder.EmbedText(ctx, prompt)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	matched, distance, ok := e.search(namespace, embedding)
+	if !ok {
+		return Match[T]{}, false
+	}
+
+	result, ok := e.result(matched)
+	if !ok {
+		return Match[T]{}, false
+	}
+
+	_, matchedPrompt := splitNamespaceKey(matched)
+
+	return Match[T]{Result: result, Prompt: matchedPrompt, Distance: distance}, true
+}
+
+// Update updates the cache with the provided prompt and result, evicting the least
+// recently accessed entries if the cache is full.
+func (e *BoltEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	data, err := e.opts.Codec.Encode(result)
+	if err != nil {
+		return fmt.Errorf("encode result: %w", err)
+	}
+
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+	namespace, _ := splitNamespaceKey(key)
+
+	e.mu.RLock()
+	current, cached := e.entries[key]
+	e.mu.RUnlock()
+
+	var embedding []float32
+
+	switch {
+	case cached:
+		embedding = current.embedding
+	case e.embedder != nil:
+		embedding, err = e.embedder.EmbedText(ctx, prompt)
+		if err != nil {
+			return err
+		}
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	e.namespaces.update(namespace)
+
+	entry := &boltEntry{
+		embedding: embedding,
+		access:    e.now().UnixNano(),
+		size:      int64(len(key) + len(data) + 4*len(embedding)),
+		namespace: namespace,
+	}
+
+	return e.write(func(tx *bolt.Tx) error {
+		k := []byte(key)
+
+		if err := tx.Bucket(boltResults).Put(k, data); err != nil {
+			return err
+		}
+
+		if embedding != nil {
+			if err := tx.Bucket(boltEmbeddings).Put(k, encodeFloats(embedding)); err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Bucket(boltAccess).Put(k, encodeAccess(entry.access)); err != nil {
+			return err
+		}
+
+		if old, ok := e.entries[key]; ok {
+			e.bytes -= old.size
+		}
+
+		e.entries[key] = entry
+		e.bytes += entry.size
+		e.namespaces.addSize(key, int64(len(prompt)+len(data)))
+		delete(e.touched, key)
+
+		if err := e.persistAccess(tx); err != nil {
+			return err
+		}
+
+		if err := e.evict(tx, key); err != nil {
+			return err
+		}
+
+		var err error
+
+		e.namespaces.enforce(key, e.keysByAccess, func(k string) {
+			if err == nil {
+				err = e.remove(tx, k)
+			}
+		})
+
+		return err
+	})
+}
+
+// Delete removes the entry of the given prompt.
+func (e *BoltEngine[T]) Delete(ctx context.Context, prompt string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+	namespace, _ := splitNamespaceKey(key)
+
+	e.namespaces.delete(namespace)
+
+	return e.write(func(tx *bolt.Tx) error {
+		return e.remove(tx, key)
+	})
+}
+
+// ClearNamespace removes all entries of the namespace.
+func (e *BoltEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	keys := make([]string, 0, len(e.entries))
+	for key := range e.entries {
+		keys = append(keys, key)
+	}
+
+	return e.write(func(tx *bolt.Tx) error {
+		var err error
+
+		clearNamespace(namespace, keys, func(key string) {
+			if err == nil {
+				err = e.remove(tx, key)
+			}
+		})
+
+		return err
+	})
+}
+
+// NamespaceStats returns the usage statistics of the namespace.
+func (e *BoltEngine[T]) NamespaceStats(namespace string) Stats {
+	return e.namespaces.stats(namespace)
+}
+
+// Namespaces returns the names of the namespaces with entries or statistics.
+func (e *BoltEngine[T]) Namespaces() []string {
+	return e.namespaces.names()
+}
+
+// Clear clears the cache, removing all entries.
+// It returns an error if the clear operation fails.
+func (e *BoltEngine[T]) Clear(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	err := e.db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltResults, boltEmbeddings, boltAccess} {
+			if err := tx.DeleteBucket(name); err != nil {
+				return err
+			}
+
+			if _, err := tx.CreateBucket(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	clear(e.entries)
+	clear(e.touched)
+	e.namespaces.reset()
+	e.bytes = 0
+
+	return nil
+}
+
+// Len returns the number of cached entries.
+func (e *BoltEngine[T]) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	return len(e.entries)
+}
+
+// Close persists the access times of the entries and closes the database.
+func (e *BoltEngine[T]) Close() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return nil
+	}
+
+	e.closed = true
+
+	err := e.db.Update(e.persistAccess)
+
+	return errors.Join(err, e.db.Close())
+}
+
+// write runs fn in a read-write transaction. If the transaction is rolled back, the in-memory
+// state changed by fn is reloaded from the database. It must be called with the write lock held.
+func (e *BoltEngine[T]) write(fn func(tx *bolt.Tx) error) error {
+	err := e.db.Update(fn)
+	if err == nil {
+		return nil
+	}
+
+	clear(e.entries)
+	clear(e.touched)
+	e.namespaces.reset()
+	e.bytes = 0
+
+	return errors.Join(err, e.load())
+}
+
+// result reads and decodes the result of a prompt and records the access.
+func (e *BoltEngine[T]) result(prompt string) (T, bool) {
+	var data []byte
+
+	err := e.db.View(func(tx *bolt.Tx) error {
+		if v := tx.Bucket(boltResults).Get([]byte(prompt)); v != nil {
+			data = make([]byte, len(v)) // v is only valid during the transaction
+			copy(data, v)
+		}
+
+		return nil
+	})
+	if err != nil || data == nil {
+		return *new(T), false
+	}
+
+	result, err := e.opts.Codec.Decode(data)
+	if err != nil {
+		return *new(T), false
+	}
+
+	e.mu.Lock()
+	if entry, ok := e.entries[prompt]; ok {
+		entry.access = e.now().UnixNano()
+		e.touched[prompt] = struct{}{}
+	}
+	e.mu.Unlock()
+
+	return result, true
+}
+
+// search returns the key of the closest entry of the namespace within the threshold distance.
+func (e *BoltEngine[T]) search(namespace string, embedding []float32) (string, float32, bool) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	var (
+		best     string
+		bestDist = float32(math.Inf(1))
+	)
+
+	for prompt, entry := range e.entries {
+		if entry.embedding == nil || entry.namespace != namespace {
+			continue
+		}
+
+		d, err := e.opts.DistanceFunc(embedding, entry.embedding)
+		if err != nil {
+			continue
+		}
+
+		if d <= e.opts.Threshold && d < bestDist {
+			best, bestDist = prompt, d
+		}
+	}
+
+	return best, bestDist, best != ""
+}
+
+// evict removes the least recently accessed entries, except the given prompt, until the limits are met.
+// It must be called with the write lock held.
+func (e *BoltEngine[T]) evict(tx *bolt.Tx, keep string) error {
+	for len(e.entries) > e.opts.MaxEntries || (e.opts.MaxBytes > 0 && e.bytes > e.opts.MaxBytes) {
+		var (
+			oldest string
+			access int64
+			found  bool
+		)
+
+		for prompt, entry := range e.entries {
+			if prompt != keep && (!found || entry.access < access) {
+				oldest, access, found = prompt, entry.access, true
+			}
+		}
+
+		if !found {
+			return nil // only the kept entry is left
+		}
+
+		if err := e.remove(tx, oldest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// remove deletes the entry of a prompt. It must be called with the write lock held.
+func (e *BoltEngine[T]) remove(tx *bolt.Tx, prompt string) error {
+	key := []byte(prompt)
+
+	for _, name := range [][]byte{boltResults, boltEmbeddings, boltAccess} {
+		if err := tx.Bucket(name).Delete(key); err != nil {
+			return err
+		}
+	}
+
+	if entry, ok := e.entries[prompt]; ok {
+		e.bytes -= entry.size
+		delete(e.entries, prompt)
+	}
+
+	e.namespaces.remove(prompt)
+	delete(e.touched, prompt)
+
+	return nil
+}
+
+// keysByAccess returns the keys from the least to the most recently accessed.
+// It must be called with the lock held.
+func (e *BoltEngine[T]) keysByAccess() []string {
+	keys := make([]string, 0, len(e.entries))
+	for key := range e.entries {
+		keys = append(keys, key)
+	}
+
+	slices.SortFunc(keys, func(a, b string) int {
+		return cmp.Compare(e.entries[a].access, e.entries[b].access)
+	})
+
+	return keys
+}
+
+// persistAccess writes the access times of the touched entries. It must be called with the write lock held.
+func (e *BoltEngine[T]) persistAccess(tx *bolt.Tx) error {
+	bucket := tx.Bucket(boltAccess)
+
+	for prompt := range e.touched {
+		if entry, ok := e.entries[prompt]; ok {
+			if err := bucket.Put([]byte(prompt), encodeAccess(entry.access)); err != nil {
+				return err
+			}
+		}
+	}
+
+	clear(e.touched)
+
+	return nil
+}
+
+// encodeAccess encodes an access time.
+func encodeAccess(access int64) []byte {
+	return binary.BigEndian.AppendUint64(nil, uint64(access))
+}
+
+// encodeFloats encodes a vector in little endian byte order.
+func encodeFloats(v []float32) []byte {
+	data := make([]byte, 0, 4*len(v))
+	for _, x := range v {
+		data = binary.LittleEndian.AppendUint32(data, math.Float32bits(x))
+	}
+
+	return data
+}
+
+// decodeFloats decodes a vector in little endian byte order.
+func decodeFloats(data []byte) []float32 {
+	v := make([]float32, len(data)/4)
+	for i := range v {
+		v[i] = math.Float32frombits(binary.LittleEndian.Uint32(data[4*i:]))
+	}
+
+	return v
+}
@@ -0,0 +1,203 @@
+package llmcache
+
+import (
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBoltEngine(t *testing.T) {
+	ctx := context.TODO()
+
+	type answer struct {
+		Text string
+	}
+
+	open := func(t *testing.T, path string, embedder Embedder, optFns ...func(o *BoltEngineOptions[answer])) *BoltEngine[answer] {
+		t.Helper()
+
+		engine, err := NewBoltEngine[answer](path, embedder, optFns...)
+		assert.NoError(t, err)
+
+		return engine
+	}
+
+	// clock returns a function setting the clock of the engine to the given second.
+	clock := func(engine *BoltEngine[answer]) func(sec int64) {
+		var now time.Time
+		engine.now = func() time.Time { return now }
+
+		return func(sec int64) {
+			now = time.Unix(sec, 0)
+		}
+	}
+
+	t.Run("Persistence", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+
+		engine := open(t, path, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		assert.NoError(t, engine.Update(ctx, "a", answer{"3"}))
+		assert.NoError(t, engine.Delete(ctx, "b"))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, path, nil)
+		defer engine.Close()
+
+		result, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"3"}, result)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+		assert.Equal(t, 1, engine.Len())
+	})
+
+	t.Run("Embeddings Are Persisted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+		embedder := &countingEmbedder{Embedder: NewHashEmbedder()}
+		threshold := func(o *BoltEngineOptions[answer]) {
+			o.Threshold = 0.5
+		}
+
+		engine := open(t, path, embedder, threshold)
+		assert.NoError(t, engine.Update(ctx, "What year was Albert Einstein born?", answer{"1879"}))
+		assert.NoError(t, engine.Close())
+		assert.Equal(t, int32(1), embedder.calls.Load())
+
+		engine = open(t, path, embedder, threshold)
+		defer engine.Close()
+
+		// Only the looked up prompt is embedded
+		assert.Equal(t, int32(1), embedder.calls.Load())
+
+		match, ok := engine.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"1879"}, match.Result)
+		assert.Equal(t, "What year was Albert Einstein born?", match.Prompt)
+		assert.False(t, match.Exact)
+		assert.Equal(t, int32(2), embedder.calls.Load())
+
+		_, ok = engine.Lookup(ctx, "How do I bake sourdough bread?")
+		assert.False(t, ok)
+	})
+
+	t.Run("Eviction", func(t *testing.T) {
+		engine := open(t, filepath.Join(t.TempDir(), "cache.db"), nil, func(o *BoltEngineOptions[answer]) {
+			o.MaxEntries = 2
+		})
+		defer engine.Close()
+
+		set := clock(engine)
+
+		set(1)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		set(2)
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+
+		// The access of a makes b the least recently accessed entry
+		set(3)
+		_, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+
+		set(4)
+		assert.NoError(t, engine.Update(ctx, "c", answer{"3"}))
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+		assert.Equal(t, 2, engine.Len())
+	})
+
+	t.Run("Eviction By Size", func(t *testing.T) {
+		engine := open(t, filepath.Join(t.TempDir(), "cache.db"), nil, func(o *BoltEngineOptions[answer]) {
+			o.MaxBytes = 100
+		})
+		defer engine.Close()
+
+		set := clock(engine)
+
+		set(1)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		set(2)
+		assert.NoError(t, engine.Update(ctx, "b", answer{strings.Repeat("x", 80)}))
+
+		_, ok := engine.Lookup(ctx, "a")
+		assert.False(t, ok)
+
+		// The updated entry is kept even if it exceeds the limit
+		set(3)
+		assert.NoError(t, engine.Update(ctx, "c", answer{strings.Repeat("x", 200)}))
+		assert.Equal(t, 1, engine.Len())
+
+		_, ok = engine.Lookup(ctx, "c")
+		assert.True(t, ok)
+	})
+
+	t.Run("Access Times Are Persisted", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+
+		engine := open(t, path, nil)
+		set := clock(engine)
+
+		set(1)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		set(2)
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		set(3)
+		_, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+		assert.NoError(t, engine.Close())
+
+		// The limit is lowered on open, so the least recently accessed entry is evicted
+		engine = open(t, path, nil, func(o *BoltEngineOptions[answer]) {
+			o.MaxEntries = 1
+		})
+		defer engine.Close()
+
+		_, ok = engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "cache.db")
+
+		engine := open(t, path, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Clear(ctx))
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, path, nil)
+		defer engine.Close()
+
+		_, ok := engine.Lookup(ctx, "a")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.True(t, ok)
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		engine := open(t, filepath.Join(t.TempDir(), "cache.db"), nil)
+		assert.NoError(t, engine.Close())
+		assert.NoError(t, engine.Close())
+
+		assert.ErrorIs(t, engine.Update(ctx, "a", answer{"1"}), ErrClosed)
+		assert.ErrorIs(t, engine.Delete(ctx, "a"), ErrClosed)
+		assert.ErrorIs(t, engine.Clear(ctx), ErrClosed)
+	})
+}
+
+func TestFloatEncoding(t *testing.T) {
+	v := []float32{0.5, -1, 3.25}
+	assert.Equal(t, v, decodeFloats(encodeFloats(v)))
+	assert.Empty(t, decodeFloats(nil))
+}
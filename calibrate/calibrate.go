@@ -0,0 +1,344 @@
+// Package calibrate chooses the similarity threshold of a cache engine from labelled prompt pairs.
+package calibrate
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/hupe1980/go-llmcache"
+)
+
+// Label values of a prompt pair.
+const (
+	// LabelSame marks two prompts which can be answered with the same response.
+	LabelSame = "same"
+	// LabelDifferent marks two prompts which need different responses.
+	LabelDifferent = "different"
+)
+
+// Pair is a labelled pair of prompts.
+type Pair struct {
+	// A is the first prompt.
+	A string `json:"a"`
+	// B is the second prompt.
+	B string `json:"b"`
+	// Label is either LabelSame or LabelDifferent.
+	Label string `json:"label"`
+}
+
+// Same reports whether the prompts of the pair are labelled as the same.
+func (p Pair) Same() bool {
+	return p.Label == LabelSame
+}
+
+// ReadPairs reads a JSONL file of prompt pairs, one pair per line. Empty lines are skipped.
+func ReadPairs(r io.Reader) ([]Pair, error) {
+	var pairs []Pair
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var p Pair
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		p.Label = strings.ToLower(strings.TrimSpace(p.Label))
+		if p.Label != LabelSame && p.Label != LabelDifferent {
+			return nil, fmt.Errorf("line %d: invalid label %q", line, p.Label)
+		}
+
+		pairs = append(pairs, p)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return pairs, nil
+}
+
+// Metric is a named distance function.
+type Metric struct {
+	// Name is the name of the distance function.
+	Name string
+	// DistanceFunc is the distance function.
+	DistanceFunc llmcache.DistanceFunc
+}
+
+// DefaultMetrics are the distance functions calibrated by default.
+var DefaultMetrics = []Metric{
+	{Name: "cosine", DistanceFunc: llmcache.CosineDistance},
+	{Name: "angular", DistanceFunc: llmcache.AngularDistance},
+	{Name: "squared-l2", DistanceFunc: llmcache.SquaredL2},
+	{Name: "euclidean", DistanceFunc: llmcache.Euclidean},
+	{Name: "inner-product", DistanceFunc: llmcache.NegativeInnerProduct},
+	{Name: "manhattan", DistanceFunc: llmcache.Manhattan},
+}
+
+// Options contains options for configuring the calibration.
+type Options struct {
+	// Metrics are the calibrated distance functions. Default is DefaultMetrics.
+	Metrics []Metric
+	// TargetFalseHitRate is the maximum fraction of wrong hits. Default is 0.01.
+	TargetFalseHitRate float64
+}
+
+// Point is the quality of a threshold. A pair is a hit if its distance is below the threshold.
+type Point struct {
+	// Threshold is the threshold of the point.
+	Threshold float32 `json:"threshold"`
+	// Precision is the fraction of hits which are labelled as the same.
+	Precision float64 `json:"precision"`
+	// Recall is the fraction of pairs labelled as the same which are hits.
+	Recall float64 `json:"recall"`
+	// F1 is the harmonic mean of precision and recall.
+	F1 float64 `json:"f1"`
+	// FalseHitRate is the fraction of hits which are labelled as different, i.e. 1 - precision.
+	FalseHitRate float64 `json:"falseHitRate"`
+}
+
+// Result is the calibration result of a distance function.
+type Result struct {
+	// Metric is the name of the distance function.
+	Metric string `json:"metric"`
+	// Curve holds a point for each threshold separating two distances, in ascending order.
+	Curve []Point `json:"curve"`
+	// Best is the point with the highest F1 score.
+	Best Point `json:"best"`
+	// Target is the point with the highest recall which keeps the target false-hit rate.
+	Target Point `json:"target"`
+	// TargetFound indicates that a threshold with at least one hit keeps the target false-hit rate.
+	TargetFound bool `json:"targetFound"`
+}
+
+// Run embeds the prompts of the pairs and calibrates the threshold of each distance function.
+func Run(ctx context.Context, embedder llmcache.Embedder, pairs []Pair, optFns ...func(o *Options)) ([]Result, error) {
+	opts := Options{
+		Metrics:            DefaultMetrics,
+		TargetFalseHitRate: 0.01,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if len(pairs) == 0 {
+		return nil, errors.New("no prompt pairs")
+	}
+
+	// Embed each prompt once
+	embeddings := make(map[string][]float32)
+
+	embed := func(prompt string) ([]float32, error) {
+		if v, ok := embeddings[prompt]; ok {
+			return v, nil
+		}
+
+		v, err := embedder.EmbedText(ctx, prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		embeddings[prompt] = v
+
+		return v, nil
+	}
+
+	vectors := make([][2][]float32, len(pairs))
+
+	for i, p := range pairs {
+		a, err := embed(p.A)
+		if err != nil {
+			return nil, err
+		}
+
+		b, err := embed(p.B)
+		if err != nil {
+			return nil, err
+		}
+
+		vectors[i] = [2][]float32{a, b}
+	}
+
+	results := make([]Result, 0, len(opts.Metrics))
+
+	for _, m := range opts.Metrics {
+		samples := make([]sample, len(pairs))
+
+		for i, p := range pairs {
+			d, err := m.DistanceFunc(vectors[i][0], vectors[i][1])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", m.Name, err)
+			}
+
+			samples[i] = sample{distance: d, same: p.Same()}
+		}
+
+		result := evaluate(samples, opts.TargetFalseHitRate)
+		result.Metric = m.Name
+
+		results = append(results, result)
+	}
+
+	return results, nil
+}
+
+// sample is the distance of a labelled pair.
+type sample struct {
+	distance float32
+	same     bool
+}
+
+// evaluate computes the curve of the samples and selects the best and the target threshold.
+func evaluate(samples []sample, target float64) Result {
+	slices.SortFunc(samples, func(a, b sample) int {
+		switch {
+		case a.distance < b.distance:
+			return -1
+		case a.distance > b.distance:
+			return 1
+		default:
+			return 0
+		}
+	})
+
+	positives := 0
+
+	for _, s := range samples {
+		if s.same {
+			positives++
+		}
+	}
+
+	var result Result
+
+	// A threshold below all distances, between each pair of distinct distances and above all distances
+	thresholds := []float32{samples[0].distance - 1e-3*max(1, abs(samples[0].distance))}
+
+	for i := 1; i < len(samples); i++ {
+		if samples[i].distance != samples[i-1].distance {
+			thresholds = append(thresholds, (samples[i-1].distance+samples[i].distance)/2)
+		}
+	}
+
+	last := samples[len(samples)-1].distance
+	thresholds = append(thresholds, last+1e-3*max(1, abs(last)))
+
+	hits, truePositives := 0, 0
+
+	for _, t := range thresholds {
+		for hits < len(samples) && samples[hits].distance < t {
+			if samples[hits].same {
+				truePositives++
+			}
+
+			hits++
+		}
+
+		p := point(t, hits, truePositives, positives)
+		result.Curve = append(result.Curve, p)
+
+		if p.F1 > result.Best.F1 || len(result.Curve) == 1 {
+			result.Best = p
+		}
+
+		if hits > 0 && p.FalseHitRate <= target && (!result.TargetFound || p.Recall >= result.Target.Recall) {
+			result.Target = p
+			result.TargetFound = true
+		}
+	}
+
+	return result
+}
+
+// point computes the quality of a threshold.
+func point(threshold float32, hits, truePositives, positives int) Point {
+	p := Point{Threshold: threshold}
+
+	if hits > 0 {
+		p.Precision = float64(truePositives) / float64(hits)
+		p.FalseHitRate = 1 - p.Precision
+	} else {
+		p.Precision = 1
+	}
+
+	if positives > 0 {
+		p.Recall = float64(truePositives) / float64(positives)
+	}
+
+	if p.Precision+p.Recall > 0 {
+		p.F1 = 2 * p.Precision * p.Recall / (p.Precision + p.Recall)
+	}
+
+	return p
+}
+
+// WriteReport writes a summary of the results as a table.
+func WriteReport(w io.Writer, results []Result, target float64) error {
+	if _, err := fmt.Fprintf(w, "%-14s %12s %9s %9s %9s %22s %9s\n",
+		"metric", "best", "precision", "recall", "f1", fmt.Sprintf("threshold@fhr<=%.3g", target), "recall"); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		targetThreshold, targetRecall := "-", "-"
+		if r.TargetFound {
+			targetThreshold = formatFloat(r.Target.Threshold)
+			targetRecall = fmt.Sprintf("%.3f", r.Target.Recall)
+		}
+
+		if _, err := fmt.Fprintf(w, "%-14s %12s %9.3f %9.3f %9.3f %22s %9s\n",
+			r.Metric, formatFloat(r.Best.Threshold), r.Best.Precision, r.Best.Recall, r.Best.F1, targetThreshold, targetRecall); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// WriteCurve writes the curve of a result as tab separated values.
+func WriteCurve(w io.Writer, r Result) error {
+	if _, err := fmt.Fprintln(w, "threshold\tprecision\trecall\tf1\tfalse_hit_rate"); err != nil {
+		return err
+	}
+
+	for _, p := range r.Curve {
+		if _, err := fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%.4f\t%.4f\n",
+			formatFloat(p.Threshold), p.Precision, p.Recall, p.F1, p.FalseHitRate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// formatFloat formats a threshold with four significant digits.
+func formatFloat(f float32) string {
+	return fmt.Sprintf("%.4g", f)
+}
+
+// abs returns the absolute value of x.
+func abs(x float32) float32 {
+	if x < 0 {
+		return -x
+	}
+
+	return x
+}
@@ -0,0 +1,108 @@
+package calibrate
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadPairs(t *testing.T) {
+	t.Run("Valid", func(t *testing.T) {
+		pairs, err := ReadPairs(strings.NewReader(`{"a": "x", "b": "y", "label": "same"}
+
+{"a": "x", "b": "z", "label": "Different"}
+`))
+		assert.NoError(t, err)
+		assert.Equal(t, []Pair{
+			{A: "x", B: "y", Label: LabelSame},
+			{A: "x", B: "z", Label: LabelDifferent},
+		}, pairs)
+	})
+
+	t.Run("Invalid Label", func(t *testing.T) {
+		_, err := ReadPairs(strings.NewReader(`{"a": "x", "b": "y", "label": "maybe"}`))
+		assert.ErrorContains(t, err, "line 1")
+	})
+
+	t.Run("Invalid JSON", func(t *testing.T) {
+		_, err := ReadPairs(strings.NewReader(`{"a": "x", "b": "y", "label": "same"}` + "\n{"))
+		assert.ErrorContains(t, err, "line 2")
+	})
+}
+
+func TestEvaluate(t *testing.T) {
+	samples := []sample{
+		{distance: 0.05, same: true},
+		{distance: 0.1, same: true},
+		{distance: 0.15, same: false},
+		{distance: 0.2, same: true},
+		{distance: 0.3, same: false},
+		{distance: 0.4, same: false},
+	}
+
+	result := evaluate(samples, 0)
+
+	assert.Len(t, result.Curve, 7)
+	assert.Equal(t, float64(0), result.Curve[0].Recall)
+	assert.Equal(t, float64(1), result.Curve[6].Recall)
+	assert.InDelta(t, 0.5, result.Curve[6].FalseHitRate, 1e-9)
+
+	// Separating the first two samples keeps all hits correct
+	assert.True(t, result.TargetFound)
+	assert.InDelta(t, 0.125, result.Target.Threshold, 1e-6)
+	assert.InDelta(t, 2.0/3, result.Target.Recall, 1e-9)
+
+	// The F1 score is highest when all samples labelled as the same are hits
+	assert.InDelta(t, 0.25, result.Best.Threshold, 1e-6)
+	assert.InDelta(t, 0.75, result.Best.Precision, 1e-9)
+	assert.InDelta(t, 1, result.Best.Recall, 1e-9)
+
+	t.Run("Target Not Found", func(t *testing.T) {
+		result := evaluate([]sample{{distance: 0.1, same: false}, {distance: 0.2, same: true}}, 0)
+		assert.False(t, result.TargetFound)
+	})
+}
+
+func TestRun(t *testing.T) {
+	pairs := []Pair{
+		{A: "What year was Albert Einstein born?", B: "In what year was Albert Einstein born?", Label: LabelSame},
+		{A: "What is the capital of France?", B: "What is the capital city of France?", Label: LabelSame},
+		{A: "What year was Albert Einstein born?", B: "How do I bake sourdough bread?", Label: LabelDifferent},
+		{A: "What is the capital of France?", B: "Recommend a good science fiction novel", Label: LabelDifferent},
+	}
+
+	results, err := Run(context.TODO(), llmcache.NewHashEmbedder(), pairs, func(o *Options) {
+		o.Metrics = DefaultMetrics[:2]
+	})
+	assert.NoError(t, err)
+	assert.Len(t, results, 2)
+
+	for _, r := range results {
+		assert.Equal(t, float64(1), r.Best.F1, r.Metric)
+		assert.True(t, r.TargetFound, r.Metric)
+	}
+
+	t.Run("Report", func(t *testing.T) {
+		var buf bytes.Buffer
+
+		err := WriteReport(&buf, results, 0.01)
+		assert.NoError(t, err)
+		assert.Contains(t, buf.String(), "cosine")
+		assert.Contains(t, buf.String(), "angular")
+
+		buf.Reset()
+
+		err = WriteCurve(&buf, results[0])
+		assert.NoError(t, err)
+		assert.Len(t, strings.Split(strings.TrimSpace(buf.String()), "\n"), len(results[0].Curve)+1)
+	})
+
+	t.Run("No Pairs", func(t *testing.T) {
+		_, err := Run(context.TODO(), llmcache.NewHashEmbedder(), nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,134 @@
+// Command llmcache-calibrate chooses the similarity threshold of a cache engine from labelled prompt pairs.
+//
+// The input is a JSONL file with one prompt pair per line:
+//
+//	{"a": "What year was Einstein born?", "b": "When was Albert Einstein born?", "label": "same"}
+//	{"a": "What year was Einstein born?", "b": "What year was Alan Turing born?", "label": "different"}
+//
+// Usage:
+//
+//	llmcache-calibrate -input pairs.jsonl -embedder openai -target 0.01
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/hupe1980/go-llmcache/calibrate"
+	"github.com/hupe1980/go-llmcache/internal/embedding"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "llmcache-calibrate:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("llmcache-calibrate", flag.ContinueOnError)
+
+	input := fs.String("input", "-", "JSONL file of labelled prompt pairs, - for stdin")
+	embedderName := fs.String("embedder", "hash", "embedder: hash (offline) or openai")
+	model := fs.String("model", "text-embedding-3-small", "embedding model of the openai embedder")
+	baseURL := fs.String("base-url", "https://api.openai.com/v1", "base URL of the openai embedder")
+	dimension := fs.Int("dim", 256, "dimension of the hash embedder")
+	target := fs.Float64("target", 0.01, "target false-hit rate")
+	metrics := fs.String("metrics", "", "comma separated distance functions, default all")
+	curve := fs.String("curve", "", "print the precision/recall curve of a distance function")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	embedder, err := embedding.New(*embedderName, embedding.Options{
+		Model:     *model,
+		BaseURL:   *baseURL,
+		Dimension: *dimension,
+	})
+	if err != nil {
+		return err
+	}
+
+	selected, err := selectMetrics(*metrics)
+	if err != nil {
+		return err
+	}
+
+	r := io.Reader(os.Stdin)
+
+	if *input != "-" {
+		f, err := os.Open(*input)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r = f
+	}
+
+	pairs, err := calibrate.ReadPairs(r)
+	if err != nil {
+		return err
+	}
+
+	results, err := calibrate.Run(context.Background(), embedder, pairs, func(o *calibrate.Options) {
+		o.Metrics = selected
+		o.TargetFalseHitRate = *target
+	})
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(results)
+	}
+
+	if *curve != "" {
+		for _, result := range results {
+			if result.Metric == *curve {
+				return calibrate.WriteCurve(stdout, result)
+			}
+		}
+
+		return fmt.Errorf("unknown distance function %q", *curve)
+	}
+
+	return calibrate.WriteReport(stdout, results, *target)
+}
+
+// selectMetrics returns the distance functions with the given names.
+func selectMetrics(names string) ([]calibrate.Metric, error) {
+	if names == "" {
+		return calibrate.DefaultMetrics, nil
+	}
+
+	var selected []calibrate.Metric
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		found := false
+
+		for _, m := range calibrate.DefaultMetrics {
+			if m.Name == name {
+				selected = append(selected, m)
+				found = true
+			}
+		}
+
+		if !found {
+			return nil, fmt.Errorf("unknown distance function %q", name)
+		}
+	}
+
+	return selected, nil
+}
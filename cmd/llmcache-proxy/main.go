@@ -0,0 +1,107 @@
+// Command llmcache-proxy serves an OpenAI-compatible API with cached responses.
+//
+// Point the base URL of any OpenAI client to the proxy, e.g. http://localhost:8080/v1.
+// Chat completions, completions and embeddings are served from the cache, the
+// X-Cache response header reports HIT, SEMANTIC or MISS. All other requests are
+// forwarded to the upstream API.
+//
+// Without -api-key, clients send their own key and only share cached responses with clients
+// sending the same key. With -api-key, clients must send the key of the LLMCACHE_CLIENT_KEY
+// environment variable instead.
+//
+// Usage:
+//
+//	llmcache-proxy -addr :8080 -engine similarity -embedder openai -threshold 0.1
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/hupe1980/go-llmcache/internal/embedding"
+	"github.com/hupe1980/go-llmcache/proxy"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "llmcache-proxy:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("llmcache-proxy", flag.ContinueOnError)
+
+	addr := fs.String("addr", ":8080", "listen address")
+	upstream := fs.String("upstream", "https://api.openai.com/v1", "base URL of the upstream API")
+	apiKey := fs.String("api-key", "", "API key of the upstream API shared by all clients, default the key of each client")
+	engineName := fs.String("engine", "lru", "engine: lru (exact matches only) or similarity")
+	embedderName := fs.String("embedder", "hash", "embedder: hash (offline) or openai")
+	model := fs.String("model", "text-embedding-3-small", "embedding model of the openai embedder")
+	dimension := fs.Int("dim", 256, "dimension of the hash embedder")
+	threshold := fs.Float64("threshold", 0.1, "threshold of the similarity engine")
+	maxSize := fs.Int("max-size", 1000, "cache size of each model and parameters")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	newLRUEngine := func() (llmcache.Engine[string], error) {
+		return llmcache.NewLRUEngine[string](func(o *llmcache.LRUEngineOptions) {
+			o.MaxCacheSize = *maxSize
+		})
+	}
+
+	newEngine := newLRUEngine
+
+	switch *engineName {
+	case "lru":
+	case "similarity":
+		embedder, err := embedding.New(*embedderName, embedding.Options{
+			Model:     *model,
+			BaseURL:   *upstream,
+			APIKey:    *apiKey,
+			Dimension: *dimension,
+		})
+		if err != nil {
+			return err
+		}
+
+		newEngine = func() (llmcache.Engine[string], error) {
+			return llmcache.NewLRUSimilarityEngine[string](proxy.NewTextEmbedder(embedder), func(o *llmcache.LRUSimilarityEngineOptions) {
+				o.MaxCacheSize = *maxSize
+				o.Threshold = float32(*threshold)
+			})
+		}
+	default:
+		return fmt.Errorf("unknown engine %q", *engineName)
+	}
+
+	p, err := proxy.New(*upstream, func(o *proxy.Options) {
+		o.NewEngine = newEngine
+		o.NewEmbeddingsEngine = newLRUEngine
+		o.APIKey = *apiKey
+		o.ClientKey = os.Getenv("LLMCACHE_CLIENT_KEY")
+	})
+	if err != nil {
+		return err
+	}
+
+	log.Printf("llmcache-proxy listening on %s, forwarding to %s", *addr, *upstream)
+
+	// No write timeout, the responses of the upstream API may take minutes
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           p,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       time.Minute,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	return srv.ListenAndServe()
+}
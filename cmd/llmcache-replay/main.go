@@ -0,0 +1,222 @@
+// Command llmcache-replay simulates cache configurations by replaying a log of LLM requests.
+//
+// The input is a JSONL file with one request per line:
+//
+//	{"timestamp": "2024-05-01T10:00:00Z", "prompt": "What year was Einstein born?", "response": "1879", "cost": 0.002, "key": "einstein-birth"}
+//
+// The optional key is the ground truth: requests with the same key can be answered with the same response.
+// Each combination of the given cache sizes, distance functions and thresholds is replayed.
+//
+// Usage:
+//
+//	llmcache-replay -input requests.jsonl -max-size 1000,10000 -threshold 0.1,0.2
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/hupe1980/go-llmcache/calibrate"
+	"github.com/hupe1980/go-llmcache/internal/embedding"
+	"github.com/hupe1980/go-llmcache/replay"
+)
+
+func main() {
+	if err := run(os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, "llmcache-replay:", err)
+		os.Exit(1)
+	}
+}
+
+// result is the replay report of a configuration.
+type result struct {
+	Engine    string         `json:"engine"`
+	MaxSize   int            `json:"maxSize"`
+	Metric    string         `json:"metric,omitempty"`
+	Threshold float32        `json:"threshold,omitempty"`
+	Report    *replay.Report `json:"report"`
+}
+
+func run(args []string, stdout io.Writer) error {
+	fs := flag.NewFlagSet("llmcache-replay", flag.ContinueOnError)
+
+	input := fs.String("input", "-", "JSONL log of requests, - for stdin")
+	engineName := fs.String("engine", "similarity", "engine: similarity or lru (exact matches only)")
+	embedderName := fs.String("embedder", "hash", "embedder: hash (offline) or openai")
+	model := fs.String("model", "text-embedding-3-small", "embedding model of the openai embedder")
+	baseURL := fs.String("base-url", "https://api.openai.com/v1", "base URL of the openai embedder")
+	dimension := fs.Int("dim", 256, "dimension of the hash embedder")
+	maxSizes := fs.String("max-size", "1000", "comma separated cache sizes")
+	metrics := fs.String("metric", "cosine", "comma separated distance functions")
+	thresholds := fs.String("threshold", "0.2", "comma separated thresholds")
+	asJSON := fs.Bool("json", false, "print the results as JSON")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	sizes, err := parseList(*maxSizes, strconv.Atoi)
+	if err != nil {
+		return fmt.Errorf("max-size: %w", err)
+	}
+
+	limits, err := parseList(*thresholds, func(s string) (float32, error) {
+		f, err := strconv.ParseFloat(s, 32)
+		return float32(f), err
+	})
+	if err != nil {
+		return fmt.Errorf("threshold: %w", err)
+	}
+
+	distances, err := parseList(*metrics, func(s string) (calibrate.Metric, error) {
+		for _, m := range calibrate.DefaultMetrics {
+			if m.Name == s {
+				return m, nil
+			}
+		}
+
+		return calibrate.Metric{}, fmt.Errorf("unknown distance function %q", s)
+	})
+	if err != nil {
+		return fmt.Errorf("metric: %w", err)
+	}
+
+	records, err := readRecords(*input)
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	var results []result
+
+	switch *engineName {
+	case "lru":
+		for _, size := range sizes {
+			engine, err := llmcache.NewLRUEngine[string](func(o *llmcache.LRUEngineOptions) {
+				o.MaxCacheSize = size
+			})
+			if err != nil {
+				return err
+			}
+
+			report, err := replay.Run(ctx, engine, records)
+			if err != nil {
+				return err
+			}
+
+			results = append(results, result{Engine: *engineName, MaxSize: size, Report: report})
+		}
+	case "similarity":
+		embedder, err := embedding.New(*embedderName, embedding.Options{
+			Model:     *model,
+			BaseURL:   *baseURL,
+			Dimension: *dimension,
+		})
+		if err != nil {
+			return err
+		}
+
+		// Each configuration embeds the same prompts
+		embedder = embedding.Memoize(embedder)
+
+		for _, size := range sizes {
+			for _, m := range distances {
+				for _, threshold := range limits {
+					engine, err := llmcache.NewLRUSimilarityEngine[string](embedder, func(o *llmcache.LRUSimilarityEngineOptions) {
+						o.MaxCacheSize = size
+						o.DistanceFunc = m.DistanceFunc
+						o.Threshold = threshold
+					})
+					if err != nil {
+						return err
+					}
+
+					report, err := replay.Run(ctx, engine, records)
+					if err != nil {
+						return err
+					}
+
+					results = append(results, result{Engine: *engineName, MaxSize: size, Metric: m.Name, Threshold: threshold, Report: report})
+				}
+			}
+		}
+	default:
+		return fmt.Errorf("unknown engine %q", *engineName)
+	}
+
+	if *asJSON {
+		enc := json.NewEncoder(stdout)
+		enc.SetIndent("", "  ")
+
+		return enc.Encode(results)
+	}
+
+	return writeTable(stdout, results)
+}
+
+// readRecords reads the log from the file, or from stdin if the name is "-".
+func readRecords(name string) ([]replay.Record, error) {
+	if name == "-" {
+		return replay.ReadRecords(os.Stdin)
+	}
+
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return replay.ReadRecords(f)
+}
+
+// writeTable writes the results as a table.
+func writeTable(w io.Writer, results []result) error {
+	if _, err := fmt.Fprintf(w, "%-10s %9s %-13s %9s %8s %8s %8s %12s %12s %9s\n",
+		"engine", "max-size", "metric", "threshold", "requests", "hit", "semantic", "cost", "saved", "false-hit"); err != nil {
+		return err
+	}
+
+	for _, r := range results {
+		falseHitRate := "-"
+		if r.Report.LabelledHits > 0 {
+			falseHitRate = fmt.Sprintf("%.3f", r.Report.FalseHitRate)
+		}
+
+		metric := r.Metric
+		if metric == "" {
+			metric = "-"
+		}
+
+		if _, err := fmt.Fprintf(w, "%-10s %9d %-13s %9.4g %8d %8.3f %8.3f %12.4g %12.4g %9s\n",
+			r.Engine, r.MaxSize, metric, r.Threshold, r.Report.Requests, r.Report.HitRate, r.Report.SemanticHitRate,
+			r.Report.TotalCost, r.Report.CostSaved, falseHitRate); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// parseList parses a comma separated list.
+func parseList[T any](s string, parse func(string) (T, error)) ([]T, error) {
+	var values []T
+
+	for _, field := range strings.Split(s, ",") {
+		v, err := parse(strings.TrimSpace(field))
+		if err != nil {
+			return nil, err
+		}
+
+		values = append(values, v)
+	}
+
+	return values, nil
+}
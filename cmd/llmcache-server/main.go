@@ -0,0 +1,95 @@
+// Command llmcache-server serves a cache engine over an HTTP+JSON API, so that several
+// services and replicas share one semantic cache. Clients use llmcache.RemoteEngine.
+//
+// Usage:
+//
+//	llmcache-server -addr :8081 -engine similarity -embedder openai -threshold 0.1
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/hupe1980/go-llmcache/internal/embedding"
+	"github.com/hupe1980/go-llmcache/server"
+)
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintln(os.Stderr, "llmcache-server:", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("llmcache-server", flag.ContinueOnError)
+
+	addr := fs.String("addr", ":8081", "listen address")
+	engineName := fs.String("engine", "similarity", "engine: similarity or lru (exact matches only)")
+	embedderName := fs.String("embedder", "hash", "embedder: hash (offline) or openai")
+	model := fs.String("model", "text-embedding-3-small", "embedding model of the openai embedder")
+	baseURL := fs.String("base-url", "https://api.openai.com/v1", "base URL of the openai embedder")
+	dimension := fs.Int("dim", 256, "dimension of the hash embedder")
+	threshold := fs.Float64("threshold", 0.1, "threshold of the similarity engine")
+	maxSize := fs.Int("max-size", 10000, "maximum number of cached entries")
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	var engine llmcache.Engine[string]
+
+	switch *engineName {
+	case "lru":
+		lru, err := llmcache.NewLRUEngine[string](func(o *llmcache.LRUEngineOptions) {
+			o.MaxCacheSize = *maxSize
+		})
+		if err != nil {
+			return err
+		}
+
+		engine = lru
+	case "similarity":
+		embedder, err := embedding.New(*embedderName, embedding.Options{
+			Model:     *model,
+			BaseURL:   *baseURL,
+			Dimension: *dimension,
+		})
+		if err != nil {
+			return err
+		}
+
+		similarity, err := llmcache.NewLRUSimilarityEngine[string](embedder, func(o *llmcache.LRUSimilarityEngineOptions) {
+			o.MaxCacheSize = *maxSize
+			o.Threshold = float32(*threshold)
+		})
+		if err != nil {
+			return err
+		}
+
+		engine = similarity
+	default:
+		return fmt.Errorf("unknown engine %q", *engineName)
+	}
+
+	s := server.New(engine, func(o *server.Options) {
+		o.APIKey = os.Getenv("LLMCACHE_API_KEY")
+	})
+
+	log.Printf("llmcache-server listening on %s", *addr)
+
+	srv := &http.Server{
+		Addr:              *addr,
+		Handler:           s,
+		ReadHeaderTimeout: 10 * time.Second,
+		ReadTimeout:       30 * time.Second,
+		IdleTimeout:       2 * time.Minute,
+	}
+
+	return srv.ListenAndServe()
+}
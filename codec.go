@@ -0,0 +1,308 @@
+package llmcache
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Compile time check to ensure JSONCodec satisfies the Codec interface.
+var _ Codec[any] = JSONCodec[any]{}
+
+// Compile time check to ensure GobCodec satisfies the Codec interface.
+var _ Codec[any] = GobCodec[any]{}
+
+// Compile time check to ensure RawCodec satisfies the Codec interface.
+var _ Codec[string] = RawCodec[string]{}
+
+// Compile time check to ensure CompressingCodec satisfies the Codec interface.
+var _ Codec[any] = (*CompressingCodec[any])(nil)
+
+// Compile time check to ensure VersionedCodec satisfies the Codec interface.
+var _ Codec[any] = (*VersionedCodec[any])(nil)
+
+// ErrInvalidEnvelope is returned when data does not start with a valid envelope.
+var ErrInvalidEnvelope = errors.New("invalid envelope")
+
+// Codec serializes results for engines which store them outside the process.
+type Codec[T any] interface {
+	// Encode serializes the value.
+	Encode(v T) ([]byte, error)
+	// Decode deserializes the value.
+	Decode(data []byte) (T, error)
+}
+
+// JSONCodec serializes values as JSON.
+type JSONCodec[T any] struct{}
+
+// Encode serializes the value as JSON.
+func (JSONCodec[T]) Encode(v T) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+// Decode deserializes the value from JSON.
+func (JSONCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := json.Unmarshal(data, &v)
+
+	return v, err
+}
+
+// GobCodec serializes values with encoding/gob. Each value carries its type information,
+// so the values can be decoded independently of each other.
+type GobCodec[T any] struct{}
+
+// Encode serializes the value with gob.
+func (GobCodec[T]) Encode(v T) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(&v); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decode deserializes the value with gob.
+func (GobCodec[T]) Decode(data []byte) (T, error) {
+	var v T
+	err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v)
+
+	return v, err
+}
+
+// RawCodec stores strings and byte slices as they are.
+type RawCodec[T ~string | ~[]byte] struct{}
+
+// Encode returns the bytes of the value.
+func (RawCodec[T]) Encode(v T) ([]byte, error) {
+	return []byte(v), nil
+}
+
+// Decode returns the value of the bytes.
+func (RawCodec[T]) Decode(data []byte) (T, error) {
+	return T(data), nil
+}
+
+// Compressor compresses encoded values, e.g. with zstd or snappy.
+type Compressor interface {
+	// Compress compresses the data.
+	Compress(data []byte) ([]byte, error)
+	// Decompress decompresses the data.
+	Decompress(data []byte) ([]byte, error)
+}
+
+// FlateCompressor compresses data with DEFLATE of the standard library.
+type FlateCompressor struct {
+	// Level is the compression level, see compress/flate. The zero value is flate.NoCompression,
+	// so use flate.DefaultCompression for the default level.
+	Level int
+}
+
+// Compress compresses the data with DEFLATE.
+func (c FlateCompressor) Compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, err := flate.NewWriter(&buf, c.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// Decompress decompresses DEFLATE data.
+func (c FlateCompressor) Decompress(data []byte) ([]byte, error) {
+	r := flate.NewReader(bytes.NewReader(data))
+	defer r.Close()
+
+	return io.ReadAll(r)
+}
+
+// CompressingCodecOptions contains options for configuring the CompressingCodec.
+type CompressingCodecOptions struct {
+	// Compressor compresses the encoded values. Default is a FlateCompressor with the default level.
+	Compressor Compressor
+	// MinSize is the size from which encoded values are compressed. Default is 256 bytes.
+	MinSize int
+}
+
+// CompressingCodec compresses the values encoded by another codec. Small values are stored
+// uncompressed, so each value starts with a flag byte indicating whether it is compressed.
+type CompressingCodec[T any] struct {
+	codec Codec[T]
+	opts  CompressingCodecOptions
+}
+
+// Flags of the CompressingCodec.
+const (
+	compressionNone byte = iota
+	compressionOn
+)
+
+// NewCompressingCodec creates a new CompressingCodec instance compressing the values of the codec.
+func NewCompressingCodec[T any](codec Codec[T], optFns ...func(o *CompressingCodecOptions)) *CompressingCodec[T] {
+	opts := CompressingCodecOptions{
+		Compressor: FlateCompressor{Level: flate.DefaultCompression},
+		MinSize:    256,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Compressor == nil {
+		opts.Compressor = FlateCompressor{Level: flate.DefaultCompression}
+	}
+
+	if opts.MinSize <= 0 {
+		opts.MinSize = 256
+	}
+
+	return &CompressingCodec[T]{
+		codec: codec,
+		opts:  opts,
+	}
+}
+
+// Encode encodes the value and compresses it if it is at least MinSize bytes
+// and compression makes it smaller.
+func (c *CompressingCodec[T]) Encode(v T) ([]byte, error) {
+	data, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(data) >= c.opts.MinSize {
+		compressed, err := c.opts.Compressor.Compress(data)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(compressed) < len(data) {
+			return append([]byte{compressionOn}, compressed...), nil
+		}
+	}
+
+	return append([]byte{compressionNone}, data...), nil
+}
+
+// Decode decompresses the data if necessary and decodes the value.
+func (c *CompressingCodec[T]) Decode(data []byte) (T, error) {
+	if len(data) == 0 {
+		return *new(T), fmt.Errorf("%w: missing compression flag", ErrInvalidEnvelope)
+	}
+
+	switch data[0] {
+	case compressionNone:
+		return c.codec.Decode(data[1:])
+	case compressionOn:
+		decompressed, err := c.opts.Compressor.Decompress(data[1:])
+		if err != nil {
+			return *new(T), err
+		}
+
+		return c.codec.Decode(decompressed)
+	default:
+		return *new(T), fmt.Errorf("%w: unknown compression flag %d", ErrInvalidEnvelope, data[0])
+	}
+}
+
+// Migration converts the encoded value of a version into the encoding of the next version.
+type Migration func(data []byte) ([]byte, error)
+
+// VersionedCodecOptions contains options for configuring the VersionedCodec.
+type VersionedCodecOptions struct {
+	// Migrations convert the values of a version, the key, into the next version. They are
+	// applied in order, so values of version 1 are decoded by a codec of version 3 with the
+	// migrations of version 1 and 2.
+	Migrations map[uint32]Migration
+}
+
+// VersionedCodec stores the values of another codec in a versioned envelope, so that
+// stored values can be migrated when the shape of T changes.
+type VersionedCodec[T any] struct {
+	codec   Codec[T]
+	version uint32
+	opts    VersionedCodecOptions
+}
+
+// envelopeMagic is the first byte of a versioned envelope.
+const envelopeMagic byte = 0xce
+
+// NewVersionedCodec creates a new VersionedCodec instance storing the values of the codec with the version.
+func NewVersionedCodec[T any](codec Codec[T], version uint32, optFns ...func(o *VersionedCodecOptions)) *VersionedCodec[T] {
+	opts := VersionedCodecOptions{}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	return &VersionedCodec[T]{
+		codec:   codec,
+		version: version,
+		opts:    opts,
+	}
+}
+
+// Encode encodes the value in an envelope of the current version.
+func (c *VersionedCodec[T]) Encode(v T) ([]byte, error) {
+	data, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	envelope := make([]byte, 0, 1+binary.MaxVarintLen32+len(data))
+	envelope = append(envelope, envelopeMagic)
+	envelope = binary.AppendUvarint(envelope, uint64(c.version))
+
+	return append(envelope, data...), nil
+}
+
+// Decode decodes the value of an envelope. Values of older versions are migrated first.
+// It returns an error if a migration is missing or the version is newer than the current one.
+func (c *VersionedCodec[T]) Decode(data []byte) (T, error) {
+	if len(data) == 0 || data[0] != envelopeMagic {
+		return *new(T), ErrInvalidEnvelope
+	}
+
+	version, n := binary.Uvarint(data[1:])
+	if n <= 0 || version > math.MaxUint32 {
+		return *new(T), fmt.Errorf("%w: invalid version", ErrInvalidEnvelope)
+	}
+
+	if version > uint64(c.version) {
+		return *new(T), fmt.Errorf("%w: version %d is newer than %d", ErrInvalidEnvelope, version, c.version)
+	}
+
+	data = data[1+n:]
+
+	for v := uint32(version); v < c.version; v++ {
+		migrate, ok := c.opts.Migrations[v]
+		if !ok {
+			return *new(T), fmt.Errorf("missing migration from version %d", v)
+		}
+
+		migrated, err := migrate(data)
+		if err != nil {
+			return *new(T), fmt.Errorf("migrate from version %d: %w", v, err)
+		}
+
+		data = migrated
+	}
+
+	return c.codec.Decode(data)
+}
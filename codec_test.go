@@ -0,0 +1,163 @@
+package llmcache
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestJSONCodec(t *testing.T) {
+	type answer struct {
+		Text   string
+		Tokens int
+	}
+
+	codec := JSONCodec[answer]{}
+
+	data, err := codec.Encode(answer{Text: "1879", Tokens: 2})
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"Text":"1879","Tokens":2}`, string(data))
+
+	v, err := codec.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, answer{Text: "1879", Tokens: 2}, v)
+
+	_, err = codec.Decode([]byte("{"))
+	assert.Error(t, err)
+}
+
+func TestGobCodec(t *testing.T) {
+	type answer struct {
+		Text   string
+		Tokens int
+	}
+
+	codec := GobCodec[answer]{}
+
+	data, err := codec.Encode(answer{Text: "1879", Tokens: 2})
+	assert.NoError(t, err)
+
+	v, err := codec.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, answer{Text: "1879", Tokens: 2}, v)
+
+	_, err = codec.Decode([]byte{0xff})
+	assert.Error(t, err)
+}
+
+func TestRawCodec(t *testing.T) {
+	data, err := RawCodec[string]{}.Encode("1879")
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1879"), data)
+
+	v, err := RawCodec[string]{}.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, "1879", v)
+
+	b, err := RawCodec[[]byte]{}.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("1879"), b)
+}
+
+func TestCompressingCodec(t *testing.T) {
+	codec := NewCompressingCodec[string](RawCodec[string]{}, func(o *CompressingCodecOptions) {
+		o.MinSize = 16
+	})
+
+	t.Run("Small", func(t *testing.T) {
+		data, err := codec.Encode("short")
+		assert.NoError(t, err)
+		assert.Equal(t, append([]byte{compressionNone}, "short"...), data)
+
+		v, err := codec.Decode(data)
+		assert.NoError(t, err)
+		assert.Equal(t, "short", v)
+	})
+
+	t.Run("Large", func(t *testing.T) {
+		text := strings.Repeat("Albert Einstein was born in 1879. ", 100)
+
+		data, err := codec.Encode(text)
+		assert.NoError(t, err)
+		assert.Equal(t, compressionOn, data[0])
+		assert.Less(t, len(data), len(text)/10)
+
+		v, err := codec.Decode(data)
+		assert.NoError(t, err)
+		assert.Equal(t, text, v)
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := codec.Decode(nil)
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+		_, err = codec.Decode([]byte{42})
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+		_, err = codec.Decode([]byte{compressionOn, 1, 2, 3})
+		assert.Error(t, err)
+	})
+}
+
+func TestVersionedCodec(t *testing.T) {
+	type answerV1 struct {
+		Text string
+	}
+
+	type answerV2 struct {
+		Text   string
+		Tokens int
+	}
+
+	v1 := NewVersionedCodec[answerV1](JSONCodec[answerV1]{}, 1)
+
+	data, err := v1.Encode(answerV1{Text: "1879"})
+	assert.NoError(t, err)
+
+	v, err := v1.Decode(data)
+	assert.NoError(t, err)
+	assert.Equal(t, answerV1{Text: "1879"}, v)
+
+	t.Run("Migration", func(t *testing.T) {
+		v2 := NewVersionedCodec[answerV2](JSONCodec[answerV2]{}, 2, func(o *VersionedCodecOptions) {
+			o.Migrations = map[uint32]Migration{
+				1: func(data []byte) ([]byte, error) {
+					var old answerV1
+					if err := json.Unmarshal(data, &old); err != nil {
+						return nil, err
+					}
+
+					return json.Marshal(answerV2{Text: old.Text, Tokens: -1})
+				},
+			}
+		})
+
+		v, err := v2.Decode(data)
+		assert.NoError(t, err)
+		assert.Equal(t, answerV2{Text: "1879", Tokens: -1}, v)
+	})
+
+	t.Run("Missing Migration", func(t *testing.T) {
+		v3 := NewVersionedCodec[answerV2](JSONCodec[answerV2]{}, 3)
+
+		_, err := v3.Decode(data)
+		assert.ErrorContains(t, err, "missing migration from version 1")
+	})
+
+	t.Run("Newer Version", func(t *testing.T) {
+		v0 := NewVersionedCodec[answerV1](JSONCodec[answerV1]{}, 0)
+
+		_, err := v0.Decode(data)
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+	})
+
+	t.Run("Invalid Envelope", func(t *testing.T) {
+		_, err := v1.Decode([]byte(`{"Text":"1879"}`))
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+		_, err = v1.Decode([]byte{envelopeMagic})
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+	})
+}
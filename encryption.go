@@ -0,0 +1,259 @@
+package llmcache
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Compile time check to ensure EncryptingCodec satisfies the Codec interface.
+var _ Codec[any] = (*EncryptingCodec[any])(nil)
+
+// Compile time check to ensure KeyRing satisfies the KeyProvider interface.
+var _ KeyProvider = (*KeyRing)(nil)
+
+// Compile time check to ensure HMACNormalizer satisfies the PromptNormalizer interface.
+var _ PromptNormalizer = (*HMACNormalizer)(nil)
+
+// ErrUnknownKey is returned when a key ID is not known to the key provider.
+var ErrUnknownKey = errors.New("unknown key")
+
+// KeyProvider provides the keys of an EncryptingCodec. Values are encrypted with the current key
+// and decrypted with the key whose ID is stored with them, so keys can be rotated while values
+// encrypted with older keys remain readable.
+type KeyProvider interface {
+	// CurrentKey returns the ID and the key new values are encrypted with.
+	CurrentKey() (string, []byte, error)
+	// Key returns the key with the ID. It returns ErrUnknownKey if the ID is not known.
+	Key(id string) ([]byte, error)
+}
+
+// KeyRing is a KeyProvider holding AES keys in memory.
+type KeyRing struct {
+	// mu guards the fields below.
+	mu sync.RWMutex
+	// current is the ID of the current key.
+	current string
+	// keys holds the keys by ID.
+	keys map[string][]byte
+}
+
+// NewKeyRing creates a new KeyRing instance with the key as current key.
+// The key must be 16, 24 or 32 bytes long to select AES-128, AES-192 or AES-256.
+func NewKeyRing(id string, key []byte) (*KeyRing, error) {
+	r := &KeyRing{
+		keys: make(map[string][]byte),
+	}
+
+	if err := r.Rotate(id, key); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Add adds a key, e.g. a retired key still needed to decrypt older values.
+func (r *KeyRing) Add(id string, key []byte) error {
+	if err := validateKey(id, key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id] = key
+
+	return nil
+}
+
+// Rotate adds a key and makes it the current key. Older keys are kept for decryption.
+func (r *KeyRing) Rotate(id string, key []byte) error {
+	if err := validateKey(id, key); err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.keys[id] = key
+	r.current = id
+
+	return nil
+}
+
+// Remove removes a key, so values encrypted with it can no longer be decrypted.
+// The current key cannot be removed.
+func (r *KeyRing) Remove(id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if id == r.current {
+		return errors.New("the current key cannot be removed")
+	}
+
+	delete(r.keys, id)
+
+	return nil
+}
+
+// CurrentKey returns the ID and the current key.
+func (r *KeyRing) CurrentKey() (string, []byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.current, r.keys[r.current], nil
+}
+
+// Key returns the key with the ID.
+func (r *KeyRing) Key(id string) ([]byte, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownKey, id)
+	}
+
+	return key, nil
+}
+
+// validateKey validates the ID and the length of an AES key.
+func validateKey(id string, key []byte) error {
+	if id == "" || len(id) > 255 {
+		return errors.New("key ID must be between 1 and 255 bytes long")
+	}
+
+	switch len(key) {
+	case 16, 24, 32:
+		return nil
+	default:
+		return fmt.Errorf("invalid AES key size %d", len(key))
+	}
+}
+
+// EncryptingCodec encrypts the values encoded by another codec with AES-GCM. Each value is stored
+// in an envelope with the ID of its key and a random nonce; the envelope header is authenticated,
+// so a value cannot be decrypted with another key ID.
+type EncryptingCodec[T any] struct {
+	codec Codec[T]
+	keys  KeyProvider
+}
+
+// encryptionMagic is the first byte of an encrypted envelope.
+const encryptionMagic byte = 0xe1
+
+// NewEncryptingCodec creates a new EncryptingCodec instance encrypting the values of the codec
+// with the keys of the provider.
+func NewEncryptingCodec[T any](codec Codec[T], keys KeyProvider) *EncryptingCodec[T] {
+	return &EncryptingCodec[T]{
+		codec: codec,
+		keys:  keys,
+	}
+}
+
+// Encode encodes the value and encrypts it with the current key.
+func (c *EncryptingCodec[T]) Encode(v T) ([]byte, error) {
+	data, err := c.codec.Encode(v)
+	if err != nil {
+		return nil, err
+	}
+
+	id, key, err := c.keys.CurrentKey()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateKey(id, key); err != nil {
+		return nil, err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, 2+len(id))
+	header = append(header, encryptionMagic, byte(len(id)))
+	header = append(header, id...)
+
+	envelope := make([]byte, len(header)+aead.NonceSize(), len(header)+aead.NonceSize()+len(data)+aead.Overhead())
+	copy(envelope, header)
+
+	nonce := envelope[len(header):]
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+
+	return aead.Seal(envelope, nonce, data, header), nil
+}
+
+// Decode decrypts the value with the key of its envelope and decodes it.
+func (c *EncryptingCodec[T]) Decode(data []byte) (T, error) {
+	if len(data) < 2 || data[0] != encryptionMagic || len(data) < 2+int(data[1]) {
+		return *new(T), fmt.Errorf("%w: missing encryption header", ErrInvalidEnvelope)
+	}
+
+	header := data[:2+int(data[1])]
+	id := string(header[2:])
+
+	key, err := c.keys.Key(id)
+	if err != nil {
+		return *new(T), err
+	}
+
+	aead, err := newGCM(key)
+	if err != nil {
+		return *new(T), err
+	}
+
+	rest := data[len(header):]
+	if len(rest) < aead.NonceSize()+aead.Overhead() {
+		return *new(T), fmt.Errorf("%w: truncated ciphertext", ErrInvalidEnvelope)
+	}
+
+	plaintext, err := aead.Open(nil, rest[:aead.NonceSize()], rest[aead.NonceSize():], header)
+	if err != nil {
+		return *new(T), fmt.Errorf("decrypt with key %q: %w", id, err)
+	}
+
+	return c.codec.Decode(plaintext)
+}
+
+// newGCM creates an AES-GCM cipher with the key.
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// HMACNormalizer replaces prompts with their HMAC-SHA256, so raw prompts are neither stored in the
+// cache nor sent to a remote backend. Equal prompts still share an entry, but the hashes carry no
+// meaning, so it is only suited for exact matching engines. Changing the key invalidates all entries.
+type HMACNormalizer struct {
+	// key is the HMAC key.
+	key []byte
+}
+
+// NewHMACNormalizer creates a new HMACNormalizer instance with the key, which should be at least 32 bytes long.
+func NewHMACNormalizer(key []byte) *HMACNormalizer {
+	return &HMACNormalizer{
+		key: key,
+	}
+}
+
+// Normalize returns the hex encoded HMAC-SHA256 of the prompt.
+func (n *HMACNormalizer) Normalize(prompt string) string {
+	mac := hmac.New(sha256.New, n.key)
+	mac.Write([]byte(prompt))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
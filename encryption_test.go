@@ -0,0 +1,152 @@
+package llmcache
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncryptingCodec(t *testing.T) {
+	type answer struct {
+		Text string
+	}
+
+	key1 := bytes.Repeat([]byte{1}, 32)
+	key2 := bytes.Repeat([]byte{2}, 16)
+
+	newCodec := func(t *testing.T) (*EncryptingCodec[answer], *KeyRing) {
+		t.Helper()
+
+		keys, err := NewKeyRing("k1", key1)
+		assert.NoError(t, err)
+
+		return NewEncryptingCodec[answer](JSONCodec[answer]{}, keys), keys
+	}
+
+	t.Run("Round Trip", func(t *testing.T) {
+		codec, _ := newCodec(t)
+
+		data, err := codec.Encode(answer{"secret customer data"})
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "secret")
+
+		// The nonce is random, so equal values are encrypted differently
+		again, err := codec.Encode(answer{"secret customer data"})
+		assert.NoError(t, err)
+		assert.NotEqual(t, data, again)
+
+		v, err := codec.Decode(data)
+		assert.NoError(t, err)
+		assert.Equal(t, answer{"secret customer data"}, v)
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		codec, keys := newCodec(t)
+
+		old, err := codec.Encode(answer{"old"})
+		assert.NoError(t, err)
+
+		assert.NoError(t, keys.Rotate("k2", key2))
+
+		current, err := codec.Encode(answer{"new"})
+		assert.NoError(t, err)
+		assert.Equal(t, "k2", string(current[2:4]))
+
+		// Values encrypted with the retired key remain readable
+		v, err := codec.Decode(old)
+		assert.NoError(t, err)
+		assert.Equal(t, answer{"old"}, v)
+
+		assert.ErrorContains(t, keys.Remove("k2"), "current key")
+		assert.NoError(t, keys.Remove("k1"))
+
+		_, err = codec.Decode(old)
+		assert.ErrorIs(t, err, ErrUnknownKey)
+
+		v, err = codec.Decode(current)
+		assert.NoError(t, err)
+		assert.Equal(t, answer{"new"}, v)
+	})
+
+	t.Run("Tampering", func(t *testing.T) {
+		codec, keys := newCodec(t)
+		assert.NoError(t, keys.Add("k3", key1))
+
+		data, err := codec.Encode(answer{"value"})
+		assert.NoError(t, err)
+
+		tampered := bytes.Clone(data)
+		tampered[len(tampered)-1] ^= 0xff
+
+		_, err = codec.Decode(tampered)
+		assert.Error(t, err)
+
+		// The key ID is authenticated, even if another ID refers to the same key
+		swapped := bytes.Clone(data)
+		swapped[3] = '3'
+
+		_, err = codec.Decode(swapped)
+		assert.ErrorContains(t, err, "decrypt")
+
+		_, err = codec.Decode(data[:10])
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+
+		_, err = codec.Decode([]byte(`{"Text":"plain"}`))
+		assert.ErrorIs(t, err, ErrInvalidEnvelope)
+	})
+
+	t.Run("Compression", func(t *testing.T) {
+		keys, err := NewKeyRing("k1", key1)
+		assert.NoError(t, err)
+
+		// Values are compressed before they are encrypted
+		codec := NewEncryptingCodec[answer](NewCompressingCodec[answer](JSONCodec[answer]{}), keys)
+
+		data, err := codec.Encode(answer{string(bytes.Repeat([]byte("a"), 1000))})
+		assert.NoError(t, err)
+		assert.Less(t, len(data), 200)
+
+		v, err := codec.Decode(data)
+		assert.NoError(t, err)
+		assert.Len(t, v.Text, 1000)
+	})
+}
+
+func TestKeyRing(t *testing.T) {
+	_, err := NewKeyRing("k1", []byte("short"))
+	assert.ErrorContains(t, err, "invalid AES key size")
+
+	_, err = NewKeyRing("", make([]byte, 32))
+	assert.ErrorContains(t, err, "key ID")
+
+	keys, err := NewKeyRing("k1", make([]byte, 32))
+	assert.NoError(t, err)
+
+	_, err = keys.Key("k2")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+func TestHMACNormalizer(t *testing.T) {
+	ctx := context.TODO()
+	normalizer := NewHMACNormalizer([]byte("0123456789abcdef0123456789abcdef"))
+
+	hash := normalizer.Normalize("What is my balance?")
+	assert.Len(t, hash, 64)
+	assert.Equal(t, hash, normalizer.Normalize("What is my balance?"))
+	assert.NotEqual(t, hash, NewHMACNormalizer([]byte("another key")).Normalize("What is my balance?"))
+
+	engine := &mockEngine[string]{cache: map[string]string{}}
+	cache := New[string](engine, func(o *LLMCacheOptions) {
+		o.Normalizer = normalizer
+		o.DiscardOriginal = true
+	})
+
+	assert.NoError(t, cache.Update(ctx, "What is my balance?", "42"))
+	assert.Equal(t, map[string]string{hash: "42"}, engine.cache)
+
+	match, ok := cache.LookupMatch(ctx, "What is my balance?")
+	assert.True(t, ok)
+	assert.Equal(t, hash, match.Prompt)
+}
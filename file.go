@@ -0,0 +1,823 @@
+package llmcache
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Compile time check to ensure FileEngine satisfies the Engine interface.
+var _ Engine[any] = (*FileEngine[any])(nil)
+
+// Compile time check to ensure FileEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*FileEngine[any])(nil)
+
+// Compile time check to ensure FileEngine satisfies the Deleter interface.
+var _ Deleter = (*FileEngine[any])(nil)
+
+// Compile time check to ensure FileEngine satisfies the Namespacer interface.
+var _ Namespacer = (*FileEngine[any])(nil)
+
+// ErrCorruptLog is returned when a log segment other than the last one contains an invalid record.
+var ErrCorruptLog = errors.New("corrupt log")
+
+// ErrRecordTooLarge is returned when an update exceeds the maximum size of a log record.
+var ErrRecordTooLarge = errors.New("record too large")
+
+// SyncPolicy defines when the log of a FileEngine is synced to disk.
+type SyncPolicy int
+
+const (
+	// SyncInterval syncs the log periodically, so that a crash loses at most the updates of one interval.
+	SyncInterval SyncPolicy = iota
+	// SyncAlways syncs the log after each update.
+	SyncAlways
+	// SyncNever leaves syncing to the operating system.
+	SyncNever
+)
+
+// FileEngineOptions contains options for configuring the FileEngine.
+type FileEngineOptions[T any] struct {
+	// Inherits options from LRUSimilarityEngine, which are used if an embedder is given.
+	// Otherwise, only MaxCacheSize is used.
+	LRUSimilarityEngineOptions
+	// Codec serializes the results. Default is JSONCodec.
+	Codec Codec[T]
+	// Sync is the policy for syncing the log to disk. Default is SyncInterval.
+	Sync SyncPolicy
+	// SyncInterval is the interval of SyncInterval. Default is 1 second.
+	SyncInterval time.Duration
+	// SegmentSize is the size at which a new log segment is started. Default is 64 MiB.
+	SegmentSize int64
+	// CompactionInterval is the interval in which the log is compacted if it holds more than
+	// twice as many records as entries are cached. Default is 10 minutes.
+	CompactionInterval time.Duration
+}
+
+// memoryEngine is the in-memory engine of a FileEngine.
+type memoryEngine[T comparable] interface {
+	Engine[T]
+	Matcher[T]
+	Deleter
+	Namespacer
+	// Len returns the number of cached entries.
+	Len() int
+	// embedding returns the embedding which put stores for the prompt, which is nil for exact engines.
+	embedding(ctx context.Context, prompt string) ([]float32, error)
+	// put updates the cache with the given embedding, or embeds the prompt if it is nil.
+	// It returns the embedding of the entry, which is nil for exact engines.
+	put(ctx context.Context, prompt string, embedding []float32, result T) ([]float32, error)
+	// entries returns the cached entries from the least to the most recently used.
+	entries() []memoryEntry[T]
+}
+
+// memoryEntry is an entry of a memoryEngine.
+type memoryEntry[T comparable] struct {
+	prompt    string
+	embedding []float32
+	result    T
+}
+
+// FileEngine is a cache engine implementation which keeps its entries in memory and persists
+// them in an append-only log of segment files, so that the cache survives restarts. The embeddings
+// are stored in the log, so the prompts are not embedded again when the log is loaded.
+type FileEngine[T comparable] struct {
+	// dir is the directory of the log segments.
+	dir string
+	// opts contains options for configuring the FileEngine.
+	opts FileEngineOptions[T]
+	// memory is the in-memory engine serving the lookups.
+	memory memoryEngine[T]
+	// mu guards the log and orders the writes to the log and the in-memory engine.
+	mu sync.Mutex
+	// segments are the ids of the log segments in order, the last one is active.
+	segments []uint64
+	// active is the segment file updates are appended to.
+	active *os.File
+	// activeSize is the size of the active segment.
+	activeSize int64
+	// records is the number of records in the log.
+	records int
+	// dirty indicates records which are not synced yet.
+	dirty  bool
+	closed bool
+	// stop stops the background worker, which closes done when it stopped.
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewFileEngine creates a new FileEngine instance storing its log in the directory, which is
+// created if necessary, and loads the existing log. If the embedder is nil, only exact matches
+// are served. A torn record at the end of the log, e.g. after a crash, is truncated.
+func NewFileEngine[T comparable](dir string, embedder Embedder, optFns ...func(o *FileEngineOptions[T])) (*FileEngine[T], error) {
+	opts := FileEngineOptions[T]{
+		LRUSimilarityEngineOptions: defaultLRUSimilarityEngineOptions(),
+		Codec:                      JSONCodec[T]{},
+		Sync:                       SyncInterval,
+		SyncInterval:               time.Second,
+		SegmentSize:                64 << 20,
+		CompactionInterval:         10 * time.Minute,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Codec == nil {
+		return nil, errors.New("codec is required")
+	}
+
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+
+	if opts.SegmentSize <= 0 {
+		opts.SegmentSize = 64 << 20
+	}
+
+	if opts.CompactionInterval <= 0 {
+		opts.CompactionInterval = 10 * time.Minute
+	}
+
+	var (
+		memory memoryEngine[T]
+		err    error
+	)
+
+	if embedder == nil {
+		memory, err = NewLRUEngine[T](func(o *LRUEngineOptions) {
+			*o = opts.LRUEngineOptions
+		})
+	} else {
+		memory, err = NewLRUSimilarityEngine[T](embedder, func(o *LRUSimilarityEngineOptions) {
+			*o = opts.LRUSimilarityEngineOptions
+		})
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	e := &FileEngine[T]{
+		dir:    dir,
+		opts:   opts,
+		memory: memory,
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	if err := e.load(context.Background()); err != nil {
+		if e.active != nil {
+			_ = e.active.Close()
+		}
+
+		return nil, err
+	}
+
+	go e.background()
+
+	return e, nil
+}
+
+// Lookup retrieves the cached result associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (e *FileEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	return e.memory.Lookup(ctx, prompt)
+}
+
+// LookupMatch retrieves the cached entry matching the given prompt.
+// It returns the match and a boolean indicating whether a match was found.
+func (e *FileEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	return e.memory.LookupMatch(ctx, prompt)
+}
+
+// Update appends the provided prompt and result to the log and updates the cache.
+// It returns an error if the result cannot be encoded or the log cannot be written,
+// in which case the cache is left unchanged.
+func (e *FileEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	data, err := e.opts.Codec.Encode(result)
+	if err != nil {
+		return fmt.Errorf("encode result: %w", err)
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	embedding, err := e.memory.embedding(ctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	// The log stores the prompts qualified with their namespace
+	if err := e.append(encodeRecord(recordPut, namespaceKey(NamespaceFromContext(ctx), prompt), data, embedding)); err != nil {
+		return err
+	}
+
+	_, err = e.memory.put(ctx, prompt, embedding, result)
+
+	return err
+}
+
+// Delete appends the delete to the log and removes the entry of the given prompt.
+func (e *FileEngine[T]) Delete(ctx context.Context, prompt string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	if err := e.append(encodeRecord(recordDelete, namespaceKey(NamespaceFromContext(ctx), prompt), nil, nil)); err != nil {
+		return err
+	}
+
+	return e.memory.Delete(ctx, prompt)
+}
+
+// ClearNamespace removes all entries of the namespace and appends the deletes to the log.
+func (e *FileEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	for _, entry := range e.memory.entries() {
+		if ns, prompt := splitNamespaceKey(entry.prompt); ns == namespace {
+			if err := e.append(encodeRecord(recordDelete, entry.prompt, nil, nil)); err != nil {
+				return err
+			}
+
+			if err := e.memory.Delete(WithNamespace(ctx, ns), prompt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// NamespaceStats returns the usage statistics of the namespace.
+func (e *FileEngine[T]) NamespaceStats(namespace string) Stats {
+	return e.memory.NamespaceStats(namespace)
+}
+
+// Namespaces returns the names of the namespaces with entries or statistics.
+func (e *FileEngine[T]) Namespaces() []string {
+	return e.memory.Namespaces()
+}
+
+// Clear clears the cache, removing all entries, and starts a new log.
+// It returns an error if the clear operation fails.
+func (e *FileEngine[T]) Clear(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	if err := e.memory.Clear(ctx); err != nil {
+		return err
+	}
+
+	return e.rebase(nil)
+}
+
+// Compact rewrites the log with the cached entries only, removing deleted, overwritten and evicted entries.
+func (e *FileEngine[T]) Compact(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	return e.rebase(e.memory.entries())
+}
+
+// Sync syncs the log to disk.
+func (e *FileEngine[T]) Sync() error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if e.closed {
+		return ErrClosed
+	}
+
+	return e.sync()
+}
+
+// Len returns the number of cached entries.
+func (e *FileEngine[T]) Len() int {
+	return e.memory.Len()
+}
+
+// Close syncs and closes the log. The engine cannot be used afterwards.
+func (e *FileEngine[T]) Close() error {
+	e.mu.Lock()
+
+	if e.closed {
+		e.mu.Unlock()
+		return nil
+	}
+
+	e.closed = true
+	close(e.stop)
+
+	err := errors.Join(e.sync(), e.active.Close())
+
+	e.mu.Unlock()
+
+	<-e.done
+
+	return err
+}
+
+// Record types of the log.
+const (
+	// recordPut stores the result and the embedding of a prompt.
+	recordPut byte = iota + 1
+	// recordDelete removes a prompt.
+	recordDelete
+	// recordBase starts a segment which replaces all previous segments.
+	recordBase
+)
+
+// maxRecordSize is the maximum size of the payload of a record. Larger records are rejected
+// on write, so that a larger size on read indicates a torn record.
+var maxRecordSize = 1 << 30
+
+// errTornRecord is returned for a truncated record or a record with an invalid checksum.
+var errTornRecord = errors.New("torn record")
+
+// encodeRecord encodes a record as checksum, length and payload. The payload
+// holds the record type, the prompt, the encoded result and the embedding.
+func encodeRecord(typ byte, prompt string, result []byte, embedding []float32) []byte {
+	payload := make([]byte, 0, 1+3*binary.MaxVarintLen64+len(prompt)+len(result)+4*len(embedding))
+	payload = append(payload, typ)
+	payload = binary.AppendUvarint(payload, uint64(len(prompt)))
+	payload = append(payload, prompt...)
+	payload = binary.AppendUvarint(payload, uint64(len(result)))
+	payload = append(payload, result...)
+	payload = binary.AppendUvarint(payload, uint64(len(embedding)))
+
+	for _, v := range embedding {
+		payload = binary.LittleEndian.AppendUint32(payload, math.Float32bits(v))
+	}
+
+	record := make([]byte, 8, 8+len(payload))
+	binary.LittleEndian.PutUint32(record[0:4], crc32.ChecksumIEEE(payload))
+	binary.LittleEndian.PutUint32(record[4:8], uint32(len(payload)))
+
+	return append(record, payload...)
+}
+
+// logRecord is a decoded record.
+type logRecord struct {
+	typ       byte
+	prompt    string
+	result    []byte
+	embedding []float32
+}
+
+// readRecord reads the payload of the next record. It returns io.EOF at the end
+// of the segment and errTornRecord for an incomplete or invalid record.
+func readRecord(r io.Reader) ([]byte, error) {
+	var header [8]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornRecord
+		}
+
+		return nil, err
+	}
+
+	size := binary.LittleEndian.Uint32(header[4:8])
+	if size == 0 || int64(size) > int64(maxRecordSize) {
+		return nil, errTornRecord
+	}
+
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, errTornRecord
+		}
+
+		return nil, err
+	}
+
+	if crc32.ChecksumIEEE(payload) != binary.LittleEndian.Uint32(header[0:4]) {
+		return nil, errTornRecord
+	}
+
+	return payload, nil
+}
+
+// decodeRecord decodes the payload of a record.
+func decodeRecord(payload []byte) (logRecord, error) {
+	r := bytes.NewReader(payload)
+
+	typ, err := r.ReadByte()
+	if err != nil {
+		return logRecord{}, err
+	}
+
+	readBytes := func() ([]byte, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, err
+		}
+
+		if n > uint64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+
+		b := make([]byte, n)
+		_, err = io.ReadFull(r, b)
+
+		return b, err
+	}
+
+	prompt, err := readBytes()
+	if err != nil {
+		return logRecord{}, err
+	}
+
+	result, err := readBytes()
+	if err != nil {
+		return logRecord{}, err
+	}
+
+	dim, err := binary.ReadUvarint(r)
+	if err != nil {
+		return logRecord{}, err
+	}
+
+	if dim*4 != uint64(r.Len()) {
+		return logRecord{}, io.ErrUnexpectedEOF
+	}
+
+	var embedding []float32
+
+	if dim > 0 {
+		rest := payload[len(payload)-r.Len():]
+
+		embedding = make([]float32, dim)
+		for i := range embedding {
+			embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(rest[4*i:]))
+		}
+	}
+
+	return logRecord{typ: typ, prompt: string(prompt), result: result, embedding: embedding}, nil
+}
+
+// segmentPath returns the path of a segment.
+func (e *FileEngine[T]) segmentPath(id uint64) string {
+	return filepath.Join(e.dir, fmt.Sprintf("%020d.seg", id))
+}
+
+// load replays the log segments in order, removes the segments replaced by a base segment
+// and opens the last segment for appending.
+func (e *FileEngine[T]) load(ctx context.Context) error {
+	dirEntries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range dirEntries {
+		name := entry.Name()
+
+		// Remove the leftovers of an interrupted compaction
+		if strings.HasSuffix(name, ".seg.tmp") {
+			if err := os.Remove(filepath.Join(e.dir, name)); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if id, err := strconv.ParseUint(strings.TrimSuffix(name, ".seg"), 10, 64); err == nil && strings.HasSuffix(name, ".seg") {
+			e.segments = append(e.segments, id)
+		}
+	}
+
+	slices.Sort(e.segments)
+
+	base := 0
+
+	for i, id := range e.segments {
+		isBase, err := e.replay(ctx, id, i == len(e.segments)-1)
+		if err != nil {
+			return err
+		}
+
+		if isBase {
+			base = i
+		}
+	}
+
+	for _, id := range e.segments[:base] {
+		if err := os.Remove(e.segmentPath(id)); err != nil {
+			return err
+		}
+	}
+
+	e.segments = e.segments[base:]
+
+	if len(e.segments) == 0 {
+		e.segments = []uint64{1}
+	}
+
+	active, err := os.OpenFile(e.segmentPath(e.segments[len(e.segments)-1]), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := active.Stat()
+	if err != nil {
+		_ = active.Close()
+		return err
+	}
+
+	e.active = active
+	e.activeSize = info.Size()
+
+	return nil
+}
+
+// replay applies the records of a segment to the in-memory engine. A torn record in the last
+// segment is truncated, in other segments it is reported as ErrCorruptLog. It reports whether
+// the segment is a base segment.
+func (e *FileEngine[T]) replay(ctx context.Context, id uint64, last bool) (bool, error) {
+	f, err := os.Open(e.segmentPath(id))
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	r := bufio.NewReader(f)
+
+	var (
+		offset int64
+		isBase bool
+	)
+
+	for first := true; ; first = false {
+		payload, err := readRecord(r)
+		if errors.Is(err, io.EOF) {
+			return isBase, nil
+		}
+
+		if errors.Is(err, errTornRecord) {
+			if !last {
+				return false, fmt.Errorf("%w: segment %d at offset %d", ErrCorruptLog, id, offset)
+			}
+
+			return isBase, os.Truncate(e.segmentPath(id), offset)
+		}
+
+		if err != nil {
+			return false, err
+		}
+
+		record, err := decodeRecord(payload)
+		if err != nil {
+			return false, fmt.Errorf("%w: segment %d at offset %d: %v", ErrCorruptLog, id, offset, err)
+		}
+
+		offset += int64(8 + len(payload))
+
+		switch record.typ {
+		case recordBase:
+			if !first {
+				return false, fmt.Errorf("%w: segment %d: base record at offset %d", ErrCorruptLog, id, offset)
+			}
+
+			if err := e.memory.Clear(ctx); err != nil {
+				return false, err
+			}
+
+			isBase = true
+			e.records = 0
+		case recordPut:
+			result, err := e.opts.Codec.Decode(record.result)
+			if err != nil {
+				return false, fmt.Errorf("decode result: %w", err)
+			}
+
+			// The log stores the prompts qualified with their namespace
+			namespace, prompt := splitNamespaceKey(record.prompt)
+
+			if _, err := e.memory.put(WithNamespace(ctx, namespace), prompt, record.embedding, result); err != nil {
+				return false, err
+			}
+
+			e.records++
+		case recordDelete:
+			namespace, prompt := splitNamespaceKey(record.prompt)
+
+			if err := e.memory.Delete(WithNamespace(ctx, namespace), prompt); err != nil {
+				return false, err
+			}
+
+			e.records++
+		default:
+			return false, fmt.Errorf("%w: segment %d: unknown record type %d", ErrCorruptLog, id, record.typ)
+		}
+	}
+}
+
+// append appends a record to the active segment and starts a new segment if it is full.
+func (e *FileEngine[T]) append(record []byte) error {
+	if size := len(record) - 8; size > maxRecordSize {
+		return fmt.Errorf("%w: %d bytes exceed the maximum of %d bytes", ErrRecordTooLarge, size, maxRecordSize)
+	}
+
+	if _, err := e.active.Write(record); err != nil {
+		// Remove a partially written record
+		_ = e.active.Truncate(e.activeSize)
+		return err
+	}
+
+	e.activeSize += int64(len(record))
+	e.records++
+	e.dirty = true
+
+	if e.opts.Sync == SyncAlways {
+		if err := e.sync(); err != nil {
+			return err
+		}
+	}
+
+	if e.activeSize < e.opts.SegmentSize {
+		return nil
+	}
+
+	if err := errors.Join(e.sync(), e.active.Close()); err != nil {
+		return err
+	}
+
+	id := e.segments[len(e.segments)-1] + 1
+
+	active, err := os.OpenFile(e.segmentPath(id), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	e.active = active
+	e.activeSize = 0
+	e.segments = append(e.segments, id)
+
+	return nil
+}
+
+// rebase writes a base segment with the given entries, which replaces all previous segments.
+// The segment is written to a temporary file first, so that a crash leaves the log intact.
+func (e *FileEngine[T]) rebase(entries []memoryEntry[T]) error {
+	id := e.segments[len(e.segments)-1] + 1
+	path := e.segmentPath(id)
+
+	f, err := os.OpenFile(path+".tmp", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(f)
+
+	err = func() error {
+		if _, err := w.Write(encodeRecord(recordBase, "", nil, nil)); err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			data, err := e.opts.Codec.Encode(entry.result)
+			if err != nil {
+				return fmt.Errorf("encode result: %w", err)
+			}
+
+			if _, err := w.Write(encodeRecord(recordPut, entry.prompt, data, entry.embedding)); err != nil {
+				return err
+			}
+		}
+
+		return errors.Join(w.Flush(), f.Sync())
+	}()
+
+	if err = errors.Join(err, f.Close()); err != nil {
+		_ = os.Remove(path + ".tmp")
+		return err
+	}
+
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return err
+	}
+
+	syncDir(e.dir)
+
+	active, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	info, err := active.Stat()
+	if err != nil {
+		_ = active.Close()
+		return err
+	}
+
+	// The previous segments are obsolete, even if they cannot be removed now
+	_ = e.active.Close()
+
+	for _, old := range e.segments {
+		_ = os.Remove(e.segmentPath(old))
+	}
+
+	e.active = active
+	e.activeSize = info.Size()
+	e.segments = []uint64{id}
+	e.records = len(entries)
+	e.dirty = false
+
+	return nil
+}
+
+// sync syncs the active segment if it has unsynced records.
+func (e *FileEngine[T]) sync() error {
+	if !e.dirty {
+		return nil
+	}
+
+	if err := e.active.Sync(); err != nil {
+		return err
+	}
+
+	e.dirty = false
+
+	return nil
+}
+
+// background syncs the log periodically and compacts it when it holds more than
+// twice as many records as entries are cached.
+func (e *FileEngine[T]) background() {
+	defer close(e.done)
+
+	syncTicker := time.NewTicker(e.opts.SyncInterval)
+	defer syncTicker.Stop()
+
+	compactionTicker := time.NewTicker(e.opts.CompactionInterval)
+	defer compactionTicker.Stop()
+
+	for {
+		select {
+		case <-e.stop:
+			return
+		case <-syncTicker.C:
+			if e.opts.Sync != SyncInterval {
+				continue
+			}
+
+			e.mu.Lock()
+			if !e.closed {
+				_ = e.sync()
+			}
+			e.mu.Unlock()
+		case <-compactionTicker.C:
+			e.mu.Lock()
+			if !e.closed && e.records > 2*e.memory.Len() {
+				_ = e.rebase(e.memory.entries())
+			}
+			e.mu.Unlock()
+		}
+	}
+}
+
+// syncDir syncs a directory, so that renamed files are persisted. It is not supported on all platforms.
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		_ = d.Sync()
+		_ = d.Close()
+	}
+}
@@ -0,0 +1,311 @@
+package llmcache
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// countingEmbedder counts the embedded texts.
+type countingEmbedder struct {
+	Embedder
+	calls atomic.Int32
+}
+
+func (e *countingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	e.calls.Add(1)
+	return e.Embedder.EmbedText(ctx, text)
+}
+
+// segmentFiles returns the segment files of the directory.
+func segmentFiles(t *testing.T, dir string) []string {
+	t.Helper()
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.seg"))
+	assert.NoError(t, err)
+
+	return files
+}
+
+func TestFileEngine(t *testing.T) {
+	ctx := context.TODO()
+
+	type answer struct {
+		Text string
+	}
+
+	open := func(t *testing.T, dir string, embedder Embedder, optFns ...func(o *FileEngineOptions[answer])) *FileEngine[answer] {
+		t.Helper()
+
+		engine, err := NewFileEngine[answer](dir, embedder, optFns...)
+		assert.NoError(t, err)
+
+		return engine
+	}
+
+	t.Run("Persistence", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		assert.NoError(t, engine.Update(ctx, "a", answer{"3"}))
+		assert.NoError(t, engine.Delete(ctx, "b"))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, dir, nil)
+		defer engine.Close()
+
+		result, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"3"}, result)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+		assert.Equal(t, 1, engine.Len())
+	})
+
+	t.Run("Embeddings Are Persisted", func(t *testing.T) {
+		dir := t.TempDir()
+		embedder := &countingEmbedder{Embedder: NewHashEmbedder()}
+		threshold := func(o *FileEngineOptions[answer]) {
+			o.Threshold = 0.5
+		}
+
+		engine := open(t, dir, embedder, threshold)
+		assert.NoError(t, engine.Update(ctx, "What year was Albert Einstein born?", answer{"1879"}))
+		assert.NoError(t, engine.Close())
+		assert.Equal(t, int32(1), embedder.calls.Load())
+
+		engine = open(t, dir, embedder, threshold)
+		defer engine.Close()
+
+		// Only the looked up prompt is embedded
+		assert.Equal(t, int32(1), embedder.calls.Load())
+
+		match, ok := engine.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"1879"}, match.Result)
+		assert.False(t, match.Exact)
+		assert.Equal(t, int32(2), embedder.calls.Load())
+	})
+
+	t.Run("Torn Write", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		assert.NoError(t, engine.Close())
+
+		files := segmentFiles(t, dir)
+		assert.Len(t, files, 1)
+
+		info, err := os.Stat(files[0])
+		assert.NoError(t, err)
+
+		// Simulate a crash during the write of the second record
+		assert.NoError(t, os.Truncate(files[0], info.Size()-3))
+
+		engine = open(t, dir, nil)
+
+		_, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+
+		// New records are appended after the valid records
+		assert.NoError(t, engine.Update(ctx, "c", answer{"3"}))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, dir, nil)
+		defer engine.Close()
+
+		_, ok = engine.Lookup(ctx, "c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, engine.Len())
+	})
+
+	t.Run("Corrupt Segment", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil, func(o *FileEngineOptions[answer]) {
+			o.SegmentSize = 1
+		})
+
+		for i := 0; i < 3; i++ {
+			assert.NoError(t, engine.Update(ctx, fmt.Sprintf("prompt%d", i), answer{"result"}))
+		}
+
+		assert.NoError(t, engine.Close())
+
+		files := segmentFiles(t, dir)
+		assert.Len(t, files, 4)
+
+		data, err := os.ReadFile(files[0])
+		assert.NoError(t, err)
+
+		data[len(data)-1] ^= 0xff
+		assert.NoError(t, os.WriteFile(files[0], data, 0o644))
+
+		_, err = NewFileEngine[answer](dir, nil)
+		assert.ErrorIs(t, err, ErrCorruptLog)
+	})
+
+	t.Run("Compaction", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil, func(o *FileEngineOptions[answer]) {
+			o.MaxCacheSize = 2
+			o.SegmentSize = 64
+		})
+
+		for i := 0; i < 10; i++ {
+			assert.NoError(t, engine.Update(ctx, fmt.Sprintf("prompt%d", i), answer{fmt.Sprint(i)}))
+		}
+
+		assert.Greater(t, len(segmentFiles(t, dir)), 1)
+
+		assert.NoError(t, engine.Compact(ctx))
+		assert.Len(t, segmentFiles(t, dir), 1)
+
+		assert.NoError(t, engine.Update(ctx, "prompt10", answer{"10"}))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, dir, nil, func(o *FileEngineOptions[answer]) {
+			o.MaxCacheSize = 2
+		})
+		defer engine.Close()
+
+		assert.Equal(t, []memoryEntry[answer]{
+			{prompt: namespaceKey("", "prompt9"), result: answer{"9"}},
+			{prompt: namespaceKey("", "prompt10"), result: answer{"10"}},
+		}, engine.memory.entries())
+	})
+
+	t.Run("Interrupted Compaction", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Close())
+
+		// A temporary segment is ignored
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, fmt.Sprintf("%020d.seg.tmp", 2)), []byte("partial"), 0o644))
+
+		engine = open(t, dir, nil)
+		defer engine.Close()
+
+		_, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+
+		_, err := os.Stat(filepath.Join(dir, fmt.Sprintf("%020d.seg.tmp", 2)))
+		assert.True(t, os.IsNotExist(err))
+	})
+
+	t.Run("Clear", func(t *testing.T) {
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil, func(o *FileEngineOptions[answer]) {
+			o.Sync = SyncAlways
+		})
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.NoError(t, engine.Clear(ctx))
+		assert.NoError(t, engine.Update(ctx, "b", answer{"2"}))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, dir, nil)
+		defer engine.Close()
+
+		_, ok := engine.Lookup(ctx, "a")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.True(t, ok)
+	})
+
+	t.Run("Record Too Large", func(t *testing.T) {
+		maxSize := maxRecordSize
+		maxRecordSize = 64
+
+		defer func() { maxRecordSize = maxSize }()
+
+		dir := t.TempDir()
+
+		engine := open(t, dir, nil)
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+		assert.ErrorIs(t, engine.Update(ctx, "b", answer{strings.Repeat("x", 64)}), ErrRecordTooLarge)
+		assert.ErrorIs(t, engine.Update(ctx, "a", answer{strings.Repeat("x", 64)}), ErrRecordTooLarge)
+
+		// The cache is left unchanged
+		_, ok := engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+
+		result, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"1"}, result)
+
+		// Later records are not lost on reload
+		assert.NoError(t, engine.Update(ctx, "c", answer{"3"}))
+		assert.NoError(t, engine.Close())
+
+		engine = open(t, dir, nil)
+		defer engine.Close()
+
+		_, ok = engine.Lookup(ctx, "c")
+		assert.True(t, ok)
+		assert.Equal(t, 2, engine.Len())
+	})
+
+	t.Run("Failed Append", func(t *testing.T) {
+		engine := open(t, t.TempDir(), nil, func(o *FileEngineOptions[answer]) {
+			o.Sync = SyncNever
+		})
+		assert.NoError(t, engine.Update(ctx, "a", answer{"1"}))
+
+		// Simulate a failing write of the log
+		assert.NoError(t, engine.active.Close())
+
+		assert.Error(t, engine.Update(ctx, "a", answer{"2"}))
+		assert.Error(t, engine.Update(ctx, "b", answer{"3"}))
+		assert.Error(t, engine.Delete(ctx, "a"))
+
+		// The cache matches the log
+		result, ok := engine.Lookup(ctx, "a")
+		assert.True(t, ok)
+		assert.Equal(t, answer{"1"}, result)
+
+		_, ok = engine.Lookup(ctx, "b")
+		assert.False(t, ok)
+
+		_ = engine.Close()
+	})
+
+	t.Run("Closed", func(t *testing.T) {
+		engine := open(t, t.TempDir(), nil)
+		assert.NoError(t, engine.Close())
+		assert.NoError(t, engine.Close())
+
+		assert.ErrorIs(t, engine.Update(ctx, "a", answer{"1"}), ErrClosed)
+		assert.ErrorIs(t, engine.Delete(ctx, "a"), ErrClosed)
+		assert.ErrorIs(t, engine.Sync(), ErrClosed)
+	})
+}
+
+func TestRecordEncoding(t *testing.T) {
+	record := encodeRecord(recordPut, "prompt", []byte("result"), []float32{0.5, -1})
+
+	decoded, err := decodeRecord(record[8:])
+	assert.NoError(t, err)
+	assert.Equal(t, logRecord{typ: recordPut, prompt: "prompt", result: []byte("result"), embedding: []float32{0.5, -1}}, decoded)
+
+	_, err = decodeRecord(record[8 : len(record)-1])
+	assert.Error(t, err)
+}
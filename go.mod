@@ -7,7 +7,9 @@ toolchain go1.22.1
 require (
 	github.com/hashicorp/golang-lru/v2 v2.0.7
 	github.com/stretchr/testify v1.9.0
+	go.etcd.io/bbolt v1.3.10
 	golang.org/x/sys v0.18.0
+	golang.org/x/text v0.14.0
 )
 
 require (
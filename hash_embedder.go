@@ -0,0 +1,76 @@
+package llmcache
+
+import (
+	"context"
+	"hash/fnv"
+)
+
+// Compile time check to ensure HashEmbedder satisfies the Embedder interface.
+var _ Embedder = (*HashEmbedder)(nil)
+
+// HashEmbedderOptions contains options for configuring the HashEmbedder.
+type HashEmbedderOptions struct {
+	// Dimension is the dimension of the embeddings. Default is 256.
+	Dimension int
+	// NGram is the length of the character n-grams of each word. Default is 3.
+	NGram int
+}
+
+// HashEmbedder is an offline Embedder based on feature hashing. It hashes the words and the character
+// n-grams of a text into a fixed number of dimensions. The embeddings capture the lexical similarity
+// of texts only, but need no model or network access, e.g. for tests and calibration baselines.
+type HashEmbedder struct {
+	opts HashEmbedderOptions
+}
+
+// NewHashEmbedder creates a new HashEmbedder instance with the provided options.
+func NewHashEmbedder(optFns ...func(o *HashEmbedderOptions)) *HashEmbedder {
+	opts := HashEmbedderOptions{
+		Dimension: 256,
+		NGram:     3,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	return &HashEmbedder{
+		opts: opts,
+	}
+}
+
+// EmbedText embeds the given text and returns the L2-normalized embedding vector.
+func (e *HashEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	embedding := make([]float32, e.opts.Dimension)
+
+	for _, word := range tokenize(text) {
+		e.add(embedding, "w:"+word)
+
+		runes := []rune("<" + word + ">")
+		for i := 0; i+e.opts.NGram <= len(runes); i++ {
+			e.add(embedding, "n:"+string(runes[i:i+e.opts.NGram]))
+		}
+	}
+
+	if norm := Magnitude(embedding); norm > 0 {
+		for i := range embedding {
+			embedding[i] /= norm
+		}
+	}
+
+	return embedding, nil
+}
+
+// add adds the signed hash of the feature to the embedding.
+func (e *HashEmbedder) add(embedding []float32, feature string) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(feature))
+	sum := h.Sum64()
+
+	// The sign is taken from a separate bit, so that collisions cancel out on average
+	if sum>>63 == 0 {
+		embedding[sum%uint64(len(embedding))]++
+	} else {
+		embedding[sum%uint64(len(embedding))]--
+	}
+}
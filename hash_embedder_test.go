@@ -0,0 +1,45 @@
+package llmcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashEmbedder(t *testing.T) {
+	embedder := NewHashEmbedder(func(o *HashEmbedderOptions) {
+		o.Dimension = 128
+	})
+
+	ctx := context.TODO()
+
+	a, err := embedder.EmbedText(ctx, "What year was Albert Einstein born?")
+	assert.NoError(t, err)
+	assert.Len(t, a, 128)
+	assert.InDelta(t, 1, Magnitude(a), 1e-5)
+
+	b, err := embedder.EmbedText(ctx, "what year was albert einstein born")
+	assert.NoError(t, err)
+	assert.Equal(t, a, b, "case and punctuation are ignored")
+
+	c, err := embedder.EmbedText(ctx, "In what year was Einstein born?")
+	assert.NoError(t, err)
+
+	d, err := embedder.EmbedText(ctx, "How do I bake bread?")
+	assert.NoError(t, err)
+
+	similar, err := CosineDistance(a, c)
+	assert.NoError(t, err)
+
+	different, err := CosineDistance(a, d)
+	assert.NoError(t, err)
+
+	assert.Less(t, similar, different)
+
+	t.Run("Empty", func(t *testing.T) {
+		e, err := embedder.EmbedText(ctx, "")
+		assert.NoError(t, err)
+		assert.Equal(t, float32(0), Magnitude(e))
+	})
+}
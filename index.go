@@ -0,0 +1,17 @@
+package llmcache
+
+// index narrows down the cache entries compared during a similarity lookup.
+type index interface {
+	// add adds the embedding of the prompt to the index, replacing an existing one.
+	add(prompt string, embedding []float32)
+
+	// remove removes the prompt from the index.
+	remove(prompt string)
+
+	// search returns the prompts of the candidates for the query embedding.
+	// It returns false if the index cannot narrow down the candidates, so all entries have to be compared.
+	search(embedding []float32) ([]string, bool)
+
+	// reset removes all entries from the index.
+	reset()
+}
@@ -0,0 +1,170 @@
+// Package embedding provides the embedders of the llmcache commands.
+package embedding
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hupe1980/go-llmcache"
+)
+
+// Options contains options for configuring an embedder.
+type Options struct {
+	// Model is the embedding model of the OpenAI embedder.
+	Model string
+	// BaseURL is the base URL of the OpenAI API.
+	BaseURL string
+	// APIKey is the key of the OpenAI API. Default is the OPENAI_API_KEY environment variable.
+	APIKey string
+	// Dimension is the dimension of the hash embedder.
+	Dimension int
+}
+
+// New creates the embedder with the given name, either "hash" or "openai".
+func New(name string, opts Options) (llmcache.Embedder, error) {
+	switch name {
+	case "hash":
+		return llmcache.NewHashEmbedder(func(o *llmcache.HashEmbedderOptions) {
+			if opts.Dimension > 0 {
+				o.Dimension = opts.Dimension
+			}
+		}), nil
+	case "openai":
+		apiKey := opts.APIKey
+		if apiKey == "" {
+			apiKey = os.Getenv("OPENAI_API_KEY")
+		}
+
+		if apiKey == "" {
+			return nil, errors.New("OPENAI_API_KEY is not set")
+		}
+
+		return NewOpenAI(apiKey, opts.Model, opts.BaseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown embedder %q", name)
+	}
+}
+
+// Compile time check to ensure OpenAI satisfies the Embedder interface.
+var _ llmcache.Embedder = (*OpenAI)(nil)
+
+// OpenAI embeds texts with the OpenAI embeddings API.
+type OpenAI struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+	model   string
+}
+
+// NewOpenAI creates a new OpenAI embedder.
+func NewOpenAI(apiKey, model, baseURL string) *OpenAI {
+	if model == "" {
+		model = "text-embedding-3-small"
+	}
+
+	if baseURL == "" {
+		baseURL = "https://api.openai.com/v1"
+	}
+
+	return &OpenAI{
+		client:  &http.Client{Timeout: 30 * time.Second},
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		apiKey:  apiKey,
+		model:   model,
+	}
+}
+
+// EmbedText embeds the given text and returns the embedding vector.
+func (e *OpenAI) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	body, err := json.Marshal(map[string]any{
+		"model": e.model,
+		"input": text,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.baseURL+"/embeddings", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+e.apiKey)
+
+	res, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		b, _ := io.ReadAll(io.LimitReader(res.Body, 1024))
+		return nil, fmt.Errorf("embeddings request failed with status %d: %s", res.StatusCode, bytes.TrimSpace(b))
+	}
+
+	var out struct {
+		Data []struct {
+			Embedding []float32 `json:"embedding"`
+		} `json:"data"`
+	}
+
+	if err := json.NewDecoder(res.Body).Decode(&out); err != nil {
+		return nil, err
+	}
+
+	if len(out.Data) == 0 {
+		return nil, errors.New("embeddings response contains no data")
+	}
+
+	return out.Data[0].Embedding, nil
+}
+
+// Compile time check to ensure Memoized satisfies the Embedder interface.
+var _ llmcache.Embedder = (*Memoized)(nil)
+
+// Memoized is an embedder which embeds each text only once.
+type Memoized struct {
+	mu         sync.RWMutex
+	embedder   llmcache.Embedder
+	embeddings map[string][]float32
+}
+
+// Memoize returns an embedder which embeds each text only once with the given embedder.
+func Memoize(embedder llmcache.Embedder) *Memoized {
+	return &Memoized{
+		embedder:   embedder,
+		embeddings: make(map[string][]float32),
+	}
+}
+
+// EmbedText embeds the given text and returns the embedding vector.
+func (e *Memoized) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	e.mu.RLock()
+	embedding, ok := e.embeddings[text]
+	e.mu.RUnlock()
+
+	if ok {
+		return embedding, nil
+	}
+
+	embedding, err := e.embedder.EmbedText(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	e.mu.Lock()
+	e.embeddings[text] = embedding
+	e.mu.Unlock()
+
+	return embedding, nil
+}
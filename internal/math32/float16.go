@@ -0,0 +1,150 @@
+package math32
+
+import "math"
+
+// Float16 converts a float32 to IEEE 754 half precision, rounding to nearest even.
+func Float16(f float32) uint16 {
+	b := math.Float32bits(f)
+	sign := uint16(b>>16) & 0x8000
+	exp := int32(b>>23) & 0xff
+	mant := b & 0x7fffff
+
+	switch {
+	case exp == 0xff:
+		// Inf and NaN, keep NaN payloads non-zero
+		if mant != 0 {
+			return sign | 0x7e00
+		}
+
+		return sign | 0x7c00
+	case exp > 142:
+		// Overflow to Inf
+		return sign | 0x7c00
+	case exp < 102:
+		// Underflow to zero
+		return sign
+	case exp < 113:
+		// Subnormal half precision value
+		mant |= 0x800000
+		shift := uint32(126 - exp)
+		h := mant >> shift
+		rem := mant & (1<<shift - 1)
+		half := uint32(1) << (shift - 1)
+
+		if rem > half || (rem == half && h&1 != 0) {
+			h++
+		}
+
+		return sign | uint16(h)
+	default:
+		h := uint32(exp-112)<<10 | mant>>13
+		rem := mant & 0x1fff
+
+		// A carry into the exponent is the correct rounding, including the overflow to Inf
+		if rem > 0x1000 || (rem == 0x1000 && h&1 != 0) {
+			h++
+		}
+
+		return sign | uint16(h)
+	}
+}
+
+// Float16ToFloat32 converts an IEEE 754 half precision value to float32.
+func Float16ToFloat32(h uint16) float32 {
+	sign := uint32(h&0x8000) << 16
+	exp := uint32(h>>10) & 0x1f
+	mant := uint32(h & 0x3ff)
+
+	switch {
+	case exp == 0x1f:
+		return math.Float32frombits(sign | 0x7f800000 | mant<<13)
+	case exp == 0 && mant == 0:
+		return math.Float32frombits(sign)
+	case exp == 0:
+		// Normalize the subnormal value
+		e := uint32(113)
+		for mant&0x400 == 0 {
+			mant <<= 1
+			e--
+		}
+
+		return math.Float32frombits(sign | e<<23 | (mant&0x3ff)<<13)
+	default:
+		return math.Float32frombits(sign | (exp+112)<<23 | mant<<13)
+	}
+}
+
+// BFloat16 converts a float32 to bfloat16, rounding to nearest even.
+func BFloat16(f float32) uint16 {
+	b := math.Float32bits(f)
+
+	// Keep NaN a NaN, the rounding could turn it into Inf
+	if b&0x7fffffff > 0x7f800000 {
+		return uint16(b>>16) | 0x40
+	}
+
+	return uint16((b + 0x7fff + (b>>16)&1) >> 16)
+}
+
+// BFloat16ToFloat32 converts a bfloat16 value to float32.
+func BFloat16ToFloat32(h uint16) float32 {
+	return math.Float32frombits(uint32(h) << 16)
+}
+
+// DotFloat16 calculates the dot product of a float32 vector and a half precision vector.
+func DotFloat16(a []float32, b []uint16) float32 {
+	return dotFloat16(a, b)
+}
+
+func dotFloat16Generic(a []float32, b []uint16) float32 {
+	var ret float32
+	for i := range a {
+		ret += a[i] * Float16ToFloat32(b[i])
+	}
+
+	return ret
+}
+
+// SquaredL2Float16 calculates the squared L2 distance between a float32 vector and a half precision vector.
+func SquaredL2Float16(a []float32, b []uint16) float32 {
+	return squaredL2Float16(a, b)
+}
+
+func squaredL2Float16Generic(a []float32, b []uint16) float32 {
+	var distance float32
+	for i := range a {
+		d := a[i] - Float16ToFloat32(b[i])
+		distance += d * d
+	}
+
+	return distance
+}
+
+// DotBFloat16 calculates the dot product of a float32 vector and a bfloat16 vector.
+func DotBFloat16(a []float32, b []uint16) float32 {
+	return dotBFloat16(a, b)
+}
+
+func dotBFloat16Generic(a []float32, b []uint16) float32 {
+	var ret float32
+	for i := range a {
+		ret += a[i] * BFloat16ToFloat32(b[i])
+	}
+
+	return ret
+}
+
+// SquaredL2BFloat16 calculates the squared L2 distance between a float32 vector and a bfloat16 vector.
+func SquaredL2BFloat16(a []float32, b []uint16) float32 {
+	return squaredL2BFloat16(a, b)
+}
+
+func squaredL2BFloat16Generic(a []float32, b []uint16) float32 {
+	var distance float32
+	for i := range a {
+		d := a[i] - BFloat16ToFloat32(b[i])
+		distance += d * d
+	}
+
+	return distance
+}
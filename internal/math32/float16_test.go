@@ -0,0 +1,162 @@
+package math32
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFloat16(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        float32
+		expected uint16
+	}{
+		{"Zero", 0, 0x0000},
+		{"Negative Zero", float32(math.Copysign(0, -1)), 0x8000},
+		{"One", 1, 0x3c00},
+		{"Minus Two", -2, 0xc000},
+		{"Max", 65504, 0x7bff},
+		{"Overflow", 65520, 0x7c00},
+		{"Smallest Normal", 6.1035156e-05, 0x0400},
+		{"Smallest Subnormal", 5.9604645e-08, 0x0001},
+		{"Round Up To Smallest Subnormal", 3.0e-08, 0x0001},
+		{"Underflow", 2.0e-08, 0x0000},
+		{"Round To Even", 1 + 1.0/2048, 0x3c00},
+		{"Round Up", 1 + 3.0/2048, 0x3c02},
+		{"Inf", float32(math.Inf(1)), 0x7c00},
+		{"Negative Inf", float32(math.Inf(-1)), 0xfc00},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, Float16(tc.f))
+		})
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		assert.True(t, math.IsNaN(float64(Float16ToFloat32(Float16(float32(math.NaN()))))))
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		for i := 0; i <= math.MaxUint16; i++ {
+			h := uint16(i)
+			if h&0x7c00 == 0x7c00 && h&0x3ff != 0 {
+				continue // NaN
+			}
+
+			assert.Equal(t, h, Float16(Float16ToFloat32(h)), "0x%04x", h)
+		}
+	})
+}
+
+func TestBFloat16(t *testing.T) {
+	tests := []struct {
+		name     string
+		f        float32
+		expected uint16
+	}{
+		{"Zero", 0, 0x0000},
+		{"One", 1, 0x3f80},
+		{"Minus Two", -2, 0xc000},
+		{"Round To Even", math.Float32frombits(0x3f808000), 0x3f80},
+		{"Round Up", math.Float32frombits(0x3f808001), 0x3f81},
+		{"Inf", float32(math.Inf(1)), 0x7f80},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, BFloat16(tc.f))
+		})
+	}
+
+	t.Run("NaN", func(t *testing.T) {
+		assert.True(t, math.IsNaN(float64(BFloat16ToFloat32(BFloat16(math.Float32frombits(0x7fffffff))))))
+	})
+
+	t.Run("Round Trip", func(t *testing.T) {
+		for i := 0; i <= math.MaxUint16; i++ {
+			h := uint16(i)
+			if h&0x7f80 == 0x7f80 && h&0x7f != 0 {
+				continue // NaN
+			}
+
+			assert.Equal(t, h, BFloat16(BFloat16ToFloat32(h)), "0x%04x", h)
+		}
+	})
+}
+
+func TestHalfPrecisionKernels(t *testing.T) {
+	kernels := []struct {
+		name      string
+		convert   func(float32) uint16
+		kernel    func([]float32, []uint16) float32
+		generic   func([]float32, []uint16) float32
+		reference func([]float32, []float32) float32
+		// tolerance is the maximum relative error compared to the float32 result.
+		tolerance float64
+	}{
+		{"DotFloat16", Float16, DotFloat16, dotFloat16Generic, dotGeneric, 1e-3},
+		{"SquaredL2Float16", Float16, SquaredL2Float16, squaredL2Float16Generic, squaredL2Generic, 1e-3},
+		{"DotBFloat16", BFloat16, DotBFloat16, dotBFloat16Generic, dotGeneric, 1e-2},
+		{"SquaredL2BFloat16", BFloat16, SquaredL2BFloat16, squaredL2BFloat16Generic, squaredL2Generic, 1e-2},
+	}
+
+	for _, k := range kernels {
+		t.Run(k.name, func(t *testing.T) {
+			for _, dim := range []int{0, 1, 7, 8, 9, 15, 16, 17, 33, 384, 1536} {
+				a, b := randomMatrix(dim, 1)
+				h := make([]uint16, dim)
+
+				for i := range b {
+					h[i] = k.convert(b[i])
+				}
+
+				result := k.kernel(a, h)
+
+				generic := k.generic(a, h)
+				assert.InDelta(t, generic, result, 1e-4*math.Max(1, math.Abs(float64(generic)))+1e-6*float64(dim), "dim %d", dim)
+
+				// Compare the accuracy with the float32 kernel
+				expected := k.reference(a, b)
+				assert.InDelta(t, expected, result, k.tolerance*math.Max(1, math.Sqrt(float64(dim))), "dim %d", dim)
+			}
+		})
+	}
+}
+
+func BenchmarkDotFloat16(b *testing.B) {
+	for _, dim := range benchmarkDims {
+		va, vb := randomMatrix(dim, 1)
+		h := make([]uint16, dim)
+
+		for i := range vb {
+			h[i] = Float16(vb[i])
+		}
+
+		b.Run(fmt.Sprintf("dim=%d", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = DotFloat16(va, h)
+			}
+		})
+	}
+}
+
+func BenchmarkDotBFloat16(b *testing.B) {
+	for _, dim := range benchmarkDims {
+		va, vb := randomMatrix(dim, 1)
+		h := make([]uint16, dim)
+
+		for i := range vb {
+			h[i] = BFloat16(vb[i])
+		}
+
+		b.Run(fmt.Sprintf("dim=%d", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = DotBFloat16(va, h)
+			}
+		})
+	}
+}
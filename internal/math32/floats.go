@@ -1,9 +1,13 @@
 package math32
 
+import "math/bits"
+
 var (
 	useAVX    bool // nolint unused
 	useAVX512 bool // nolint unused
 	useNEON   bool // nolint unused
+	useAVX2   bool // nolint unused
+	useF16C   bool // nolint unused
 )
 
 // Dot two vectors.
@@ -32,3 +36,81 @@ func squaredL2Generic(a, b []float32) float32 {
 
 	return distance
 }
+
+// L1 calculates the L1 (Manhattan) distance between two vectors.
+func L1(a, b []float32) float32 {
+	return l1(a, b)
+}
+
+func l1Generic(a, b []float32) float32 {
+	var distance float32
+	for i := range a {
+		d := a[i] - b[i]
+		if d < 0 {
+			d = -d
+		}
+
+		distance += d
+	}
+
+	return distance
+}
+
+// DotInt8 calculates the dot product of two int8 vectors.
+func DotInt8(a, b []int8) int32 {
+	return dotInt8(a, b)
+}
+
+func dotInt8Generic(a, b []int8) int32 {
+	var ret int32
+	for i := range a {
+		ret += int32(a[i]) * int32(b[i])
+	}
+
+	return ret
+}
+
+// Hamming calculates the number of differing bits between two bit vectors.
+func Hamming(a, b []uint64) int {
+	var distance int
+	for i := range a {
+		distance += bits.OnesCount64(a[i] ^ b[i])
+	}
+
+	return distance
+}
+
+// DotBatch computes the dot product of q with every row of the row-major
+// matrix m and stores the results in out. m must hold len(out) rows of len(q).
+func DotBatch(q, m, out []float32) {
+	checkBatch(q, m, out)
+	dotBatch(q, m, out)
+}
+
+func dotBatchGeneric(q, m, out []float32) {
+	dim := len(q)
+	for i := range out {
+		out[i] = dot(q, m[i*dim:(i+1)*dim])
+	}
+}
+
+// SquaredL2Batch computes the squared L2 distance between q and every row of
+// the row-major matrix m and stores the results in out. m must hold len(out)
+// rows of len(q).
+func SquaredL2Batch(q, m, out []float32) {
+	checkBatch(q, m, out)
+	squaredL2Batch(q, m, out)
+}
+
+func squaredL2BatchGeneric(q, m, out []float32) {
+	dim := len(q)
+	for i := range out {
+		out[i] = squaredL2(q, m[i*dim:(i+1)*dim])
+	}
+}
+
+func checkBatch(q, m, out []float32) {
+	if len(q)*len(out) != len(m) {
+		panic("math32: matrix size does not match query and output length")
+	}
+}
@@ -11,8 +11,16 @@ import (
 func init() {
 	useAVX = cpu.X86.HasAVX
 	useAVX = cpu.X86.HasAVX512
+	useAVX2 = cpu.X86.HasAVX2 && cpu.X86.HasFMA
+
+	// x/sys/cpu does not report F16C, which is bit 29 of ECX of CPUID leaf 1
+	_, _, ecx, _ := cpuid(1, 0)
+	useF16C = useAVX2 && ecx&(1<<29) != 0
 }
 
+//go:noescape
+func cpuid(eaxArg, ecxArg uint32) (eax, ebx, ecx, edx uint32)
+
 //go:noescape
 func _dot_product_avx(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
 
@@ -25,6 +33,30 @@ func _squared_l2_avx(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
 //go:noescape
 func _squared_l2_avx512(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
 
+//go:noescape
+func _l1_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _dot_int8_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _dot_f16_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _squared_l2_f16_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _dot_bf16_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _squared_l2_bf16_avx2(a, b unsafe.Pointer, n uintptr, result unsafe.Pointer)
+
+//go:noescape
+func _dot_batch_avx2(q, m unsafe.Pointer, dim, rows uintptr, out unsafe.Pointer)
+
+//go:noescape
+func _squared_l2_batch_avx2(q, m unsafe.Pointer, dim, rows uintptr, out unsafe.Pointer)
+
 func dot(a, b []float32) float32 {
 	switch {
 	case useAVX512:
@@ -70,3 +102,111 @@ func squaredL2(a, b []float32) float32 {
 		return squaredL2Generic(a, b)
 	}
 }
+
+func l1(a, b []float32) float32 {
+	switch {
+	case useAVX2:
+		var ret float32
+
+		if len(a) > 0 {
+			_l1_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return l1Generic(a, b)
+	}
+}
+
+func dotInt8(a, b []int8) int32 {
+	switch {
+	case useAVX2:
+		var ret int32
+
+		if len(a) > 0 {
+			_dot_int8_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return dotInt8Generic(a, b)
+	}
+}
+
+func dotFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useF16C:
+		var ret float32
+
+		if len(a) > 0 {
+			_dot_f16_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return dotFloat16Generic(a, b)
+	}
+}
+
+func squaredL2Float16(a []float32, b []uint16) float32 {
+	switch {
+	case useF16C:
+		var ret float32
+
+		if len(a) > 0 {
+			_squared_l2_f16_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return squaredL2Float16Generic(a, b)
+	}
+}
+
+func dotBFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useAVX2:
+		var ret float32
+
+		if len(a) > 0 {
+			_dot_bf16_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return dotBFloat16Generic(a, b)
+	}
+}
+
+func squaredL2BFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useAVX2:
+		var ret float32
+
+		if len(a) > 0 {
+			_squared_l2_bf16_avx2(unsafe.Pointer(&a[0]), unsafe.Pointer(&b[0]), uintptr(len(a)), unsafe.Pointer(&ret))
+		}
+
+		return ret
+	default:
+		return squaredL2BFloat16Generic(a, b)
+	}
+}
+
+func dotBatch(q, m, out []float32) {
+	switch {
+	case useAVX2 && len(q) > 0 && len(out) > 0:
+		_dot_batch_avx2(unsafe.Pointer(&q[0]), unsafe.Pointer(&m[0]), uintptr(len(q)), uintptr(len(out)), unsafe.Pointer(&out[0]))
+	default:
+		dotBatchGeneric(q, m, out)
+	}
+}
+
+func squaredL2Batch(q, m, out []float32) {
+	switch {
+	case useAVX2 && len(q) > 0 && len(out) > 0:
+		_squared_l2_batch_avx2(unsafe.Pointer(&q[0]), unsafe.Pointer(&m[0]), uintptr(len(q)), uintptr(len(out)), unsafe.Pointer(&out[0]))
+	default:
+		squaredL2BatchGeneric(q, m, out)
+	}
+}
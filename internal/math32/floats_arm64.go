@@ -8,6 +8,10 @@ import (
 	"golang.org/x/sys/cpu"
 )
 
+// halfBlock is the number of half precision values which are converted to float32 at once,
+// so that the blocks are computed with the NEON kernels of float32 vectors.
+const halfBlock = 64
+
 func init() {
 	useNEON = cpu.ARM64.HasASIMD
 }
@@ -47,3 +51,131 @@ func squaredL2(a, b []float32) float32 {
 		return squaredL2Generic(a, b)
 	}
 }
+
+func l1(a, b []float32) float32 {
+	return l1Generic(a, b)
+}
+
+func dotInt8(a, b []int8) int32 {
+	return dotInt8Generic(a, b)
+}
+
+func dotFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useNEON:
+		var (
+			ret   float32
+			block [halfBlock]float32
+		)
+
+		for len(a) > 0 {
+			n := min(len(a), halfBlock)
+			for i := range block[:n] {
+				block[i] = Float16ToFloat32(b[i])
+			}
+
+			ret += dot(a[:n], block[:n])
+			a, b = a[n:], b[n:]
+		}
+
+		return ret
+	default:
+		return dotFloat16Generic(a, b)
+	}
+}
+
+func squaredL2Float16(a []float32, b []uint16) float32 {
+	switch {
+	case useNEON:
+		var (
+			ret   float32
+			block [halfBlock]float32
+		)
+
+		for len(a) > 0 {
+			n := min(len(a), halfBlock)
+			for i := range block[:n] {
+				block[i] = Float16ToFloat32(b[i])
+			}
+
+			ret += squaredL2(a[:n], block[:n])
+			a, b = a[n:], b[n:]
+		}
+
+		return ret
+	default:
+		return squaredL2Float16Generic(a, b)
+	}
+}
+
+func dotBFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useNEON:
+		var (
+			ret   float32
+			block [halfBlock]float32
+		)
+
+		for len(a) > 0 {
+			n := min(len(a), halfBlock)
+			for i := range block[:n] {
+				block[i] = BFloat16ToFloat32(b[i])
+			}
+
+			ret += dot(a[:n], block[:n])
+			a, b = a[n:], b[n:]
+		}
+
+		return ret
+	default:
+		return dotBFloat16Generic(a, b)
+	}
+}
+
+func squaredL2BFloat16(a []float32, b []uint16) float32 {
+	switch {
+	case useNEON:
+		var (
+			ret   float32
+			block [halfBlock]float32
+		)
+
+		for len(a) > 0 {
+			n := min(len(a), halfBlock)
+			for i := range block[:n] {
+				block[i] = BFloat16ToFloat32(b[i])
+			}
+
+			ret += squaredL2(a[:n], block[:n])
+			a, b = a[n:], b[n:]
+		}
+
+		return ret
+	default:
+		return squaredL2BFloat16Generic(a, b)
+	}
+}
+
+func dotBatch(q, m, out []float32) {
+	switch {
+	case useNEON && len(q) > 0:
+		// The rows are scored one by one with the kernel of the dot product
+		for i := range out {
+			_dot_product_neon(unsafe.Pointer(&q[0]), unsafe.Pointer(&m[i*len(q)]), uintptr(len(q)), unsafe.Pointer(&out[i]))
+		}
+	default:
+		dotBatchGeneric(q, m, out)
+	}
+}
+
+func squaredL2Batch(q, m, out []float32) {
+	switch {
+	case useNEON && len(q) > 0:
+		// The rows are scored one by one with the kernel of the squared L2 distance
+		for i := range out {
+			_squared_l2_neon(unsafe.Pointer(&q[0]), unsafe.Pointer(&m[i*len(q)]), uintptr(len(q)), unsafe.Pointer(&out[i]))
+		}
+	default:
+		squaredL2BatchGeneric(q, m, out)
+	}
+}
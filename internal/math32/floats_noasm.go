@@ -9,3 +9,35 @@ func dot(a, b []float32) float32 {
 func squaredL2(a, b []float32) float32 {
 	return squaredL2Generic(a, b)
 }
+
+func l1(a, b []float32) float32 {
+	return l1Generic(a, b)
+}
+
+func dotInt8(a, b []int8) int32 {
+	return dotInt8Generic(a, b)
+}
+
+func dotFloat16(a []float32, b []uint16) float32 {
+	return dotFloat16Generic(a, b)
+}
+
+func squaredL2Float16(a []float32, b []uint16) float32 {
+	return squaredL2Float16Generic(a, b)
+}
+
+func dotBFloat16(a []float32, b []uint16) float32 {
+	return dotBFloat16Generic(a, b)
+}
+
+func squaredL2BFloat16(a []float32, b []uint16) float32 {
+	return squaredL2BFloat16Generic(a, b)
+}
+
+func dotBatch(q, m, out []float32) {
+	dotBatchGeneric(q, m, out)
+}
+
+func squaredL2Batch(q, m, out []float32) {
+	squaredL2BatchGeneric(q, m, out)
+}
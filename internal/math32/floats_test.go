@@ -1,6 +1,7 @@
 package math32
 
 import (
+	"fmt"
 	"math/rand"
 	"testing"
 
@@ -89,3 +90,196 @@ func BenchmarkSquaredL2(b *testing.B) {
 		_ = SquaredL2(va, vb)
 	}
 }
+
+func TestL1(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []float32
+		expected float32
+	}{
+		{"Positive values", []float32{1, 2, 3}, []float32{4, 5, 6}, 9.0},
+		{"Negative values", []float32{-1, -2, -3}, []float32{-4, -5, -6}, 9.0},
+		{"Mixed values", []float32{1, -2, 3}, []float32{-4, 5, -6}, 21.0},
+		{"Zero values", []float32{0, 0, 0}, []float32{0, 0, 0}, 0.0},
+		{"Empty", []float32{}, []float32{}, 0.0},
+		{"Size 17", []float32{1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15, 16, 17}, []float32{-1, -2, -3, -4, -5, -6, -7, -8, -9, -10, -11, -12, -13, -14, -15, -16, -17}, 306.0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			result := L1(tc.a, tc.b)
+			assert.Equal(t, tc.expected, result)
+		})
+	}
+
+	t.Run("Random", func(t *testing.T) {
+		for _, dim := range []int{1, 7, 8, 15, 16, 31, 33, 384} {
+			q, m := randomMatrix(dim, 1)
+			assert.InDelta(t, l1Generic(q, m), L1(q, m), 1e-3, "dim %d", dim)
+		}
+	})
+}
+
+func BenchmarkL1(b *testing.B) {
+	for _, dim := range benchmarkDims {
+		va, vb := randomMatrix(dim, 1)
+
+		b.Run(fmt.Sprintf("dim=%d", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = L1(va, vb)
+			}
+		})
+	}
+}
+
+func TestDotInt8(t *testing.T) {
+	tests := []struct {
+		name     string
+		a, b     []int8
+		expected int32
+	}{
+		{"Empty", []int8{}, []int8{}, 0},
+		{"Positive values", []int8{1, 2, 3}, []int8{4, 5, 6}, 32},
+		{"Mixed values", []int8{1, -2, 3}, []int8{-4, 5, -6}, -32},
+		{"Extreme values", []int8{-128, -128, 127}, []int8{-128, 127, 127}, 16384 - 16256 + 16129},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, DotInt8(tc.a, tc.b))
+		})
+	}
+
+	t.Run("Random", func(t *testing.T) {
+		for _, dim := range []int{1, 15, 16, 17, 31, 32, 33, 63, 384, 3072} {
+			a := make([]int8, dim)
+			b := make([]int8, dim)
+
+			for i := range a {
+				a[i] = int8(rand.Intn(256) - 128) // nolint gosec
+				b[i] = int8(rand.Intn(256) - 128) // nolint gosec
+			}
+
+			assert.Equal(t, dotInt8Generic(a, b), DotInt8(a, b), "dim %d", dim)
+		}
+	})
+}
+
+func BenchmarkDotInt8(b *testing.B) {
+	for _, dim := range benchmarkDims {
+		va := make([]int8, dim)
+		vb := make([]int8, dim)
+
+		for i := range va {
+			va[i] = int8(rand.Intn(256) - 128) // nolint gosec
+			vb[i] = int8(rand.Intn(256) - 128) // nolint gosec
+		}
+
+		b.Run(fmt.Sprintf("dim=%d", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				_ = DotInt8(va, vb)
+			}
+		})
+	}
+}
+
+func TestHamming(t *testing.T) {
+	assert.Equal(t, 0, Hamming([]uint64{}, []uint64{}))
+	assert.Equal(t, 0, Hamming([]uint64{0xff, 1}, []uint64{0xff, 1}))
+	assert.Equal(t, 65, Hamming([]uint64{0, 1}, []uint64{^uint64(0), 0}))
+}
+
+func TestDotBatch(t *testing.T) {
+	for _, dim := range []int{0, 1, 3, 7, 8, 9, 15, 16, 17, 33, 384} {
+		q, m := randomMatrix(dim, 5)
+		out := make([]float32, 5)
+
+		DotBatch(q, m, out)
+
+		for i := range out {
+			assert.InDelta(t, dotGeneric(q, m[i*dim:(i+1)*dim]), out[i], 1e-3, "dim %d row %d", dim, i)
+		}
+	}
+
+	assert.Panics(t, func() { DotBatch(make([]float32, 3), make([]float32, 5), make([]float32, 2)) })
+}
+
+func TestSquaredL2Batch(t *testing.T) {
+	for _, dim := range []int{0, 1, 3, 7, 8, 9, 15, 16, 17, 33, 384} {
+		q, m := randomMatrix(dim, 5)
+		out := make([]float32, 5)
+
+		SquaredL2Batch(q, m, out)
+
+		for i := range out {
+			assert.InDelta(t, squaredL2Generic(q, m[i*dim:(i+1)*dim]), out[i], 1e-3, "dim %d row %d", dim, i)
+		}
+	}
+
+	assert.Panics(t, func() { SquaredL2Batch(make([]float32, 3), make([]float32, 5), make([]float32, 2)) })
+}
+
+// benchmarkDims are the embedding sizes of commonly used embedding models.
+var benchmarkDims = []int{384, 768, 1536, 3072}
+
+func BenchmarkDotBatch(b *testing.B) {
+	const rows = 1000
+
+	for _, dim := range benchmarkDims {
+		q, m := randomMatrix(dim, rows)
+		out := make([]float32, rows)
+
+		b.Run(fmt.Sprintf("dim=%d/batch", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				DotBatch(q, m, out)
+			}
+		})
+
+		b.Run(fmt.Sprintf("dim=%d/single", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for r := range out {
+					out[r] = Dot(q, m[r*dim:(r+1)*dim])
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkSquaredL2Batch(b *testing.B) {
+	const rows = 1000
+
+	for _, dim := range benchmarkDims {
+		q, m := randomMatrix(dim, rows)
+		out := make([]float32, rows)
+
+		b.Run(fmt.Sprintf("dim=%d/batch", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				SquaredL2Batch(q, m, out)
+			}
+		})
+
+		b.Run(fmt.Sprintf("dim=%d/single", dim), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				for r := range out {
+					out[r] = SquaredL2(q, m[r*dim:(r+1)*dim])
+				}
+			}
+		})
+	}
+}
+
+// randomMatrix returns a random query of size dim and a random row-major
+// matrix with the given number of rows.
+func randomMatrix(dim, rows int) ([]float32, []float32) {
+	q := make([]float32, dim)
+	for i := range q {
+		q[i] = rand.Float32()*2 - 1 // nolint gosec
+	}
+
+	m := make([]float32, dim*rows)
+	for i := range m {
+		m[i] = rand.Float32()*2 - 1 // nolint gosec
+	}
+
+	return q, m
+}
@@ -0,0 +1,300 @@
+#include <immintrin.h> // AVX2, FMA and F16C intrinsics
+
+// horizontal sum of the 8 lanes of a vector
+static inline float _hsum_avx2(__m256 v)
+{
+    __m128 sum = _mm_add_ps(_mm256_castps256_ps128(v), _mm256_extractf128_ps(v, 1));
+    sum = _mm_add_ps(sum, _mm_movehl_ps(sum, sum));
+    sum = _mm_add_ss(sum, _mm_movehdup_ps(sum));
+    return _mm_cvtss_f32(sum);
+}
+
+void _dot_batch_avx2(float *q, float *m, long dim, long rows, float *out)
+{
+    for (long r = 0; r < rows; r++, m += dim)
+    {
+        __m256 sum1 = _mm256_setzero_ps();
+        __m256 sum2 = _mm256_setzero_ps();
+        long i = 0;
+
+        // iterate over the row in chunks of 16 with two accumulators
+        for (; i + 16 <= dim; i += 16)
+        {
+            sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(q + i), _mm256_loadu_ps(m + i), sum1);
+            sum2 = _mm256_fmadd_ps(_mm256_loadu_ps(q + i + 8), _mm256_loadu_ps(m + i + 8), sum2);
+        }
+
+        if (i + 8 <= dim)
+        {
+            sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(q + i), _mm256_loadu_ps(m + i), sum1);
+            i += 8;
+        }
+
+        float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+        // handle leftovers if dim is not a multiple of 8
+        for (; i < dim; i++)
+        {
+            sum += q[i] * m[i];
+        }
+
+        out[r] = sum;
+    }
+}
+
+void _squared_l2_batch_avx2(float *q, float *m, long dim, long rows, float *out)
+{
+    for (long r = 0; r < rows; r++, m += dim)
+    {
+        __m256 sum1 = _mm256_setzero_ps();
+        __m256 sum2 = _mm256_setzero_ps();
+        long i = 0;
+
+        // iterate over the row in chunks of 16 with two accumulators
+        for (; i + 16 <= dim; i += 16)
+        {
+            __m256 diff1 = _mm256_sub_ps(_mm256_loadu_ps(q + i), _mm256_loadu_ps(m + i));
+            __m256 diff2 = _mm256_sub_ps(_mm256_loadu_ps(q + i + 8), _mm256_loadu_ps(m + i + 8));
+            sum1 = _mm256_fmadd_ps(diff1, diff1, sum1);
+            sum2 = _mm256_fmadd_ps(diff2, diff2, sum2);
+        }
+
+        if (i + 8 <= dim)
+        {
+            __m256 diff = _mm256_sub_ps(_mm256_loadu_ps(q + i), _mm256_loadu_ps(m + i));
+            sum1 = _mm256_fmadd_ps(diff, diff, sum1);
+            i += 8;
+        }
+
+        float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+        // handle leftovers if dim is not a multiple of 8
+        for (; i < dim; i++)
+        {
+            float diff = q[i] - m[i];
+            sum += diff * diff;
+        }
+
+        out[r] = sum;
+    }
+}
+
+void _l1_avx2(float *a, float *b, long n, float *result)
+{
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    long i = 0;
+
+    // the absolute difference is the maximum of both differences
+    for (; i + 16 <= n; i += 16)
+    {
+        __m256 a1 = _mm256_loadu_ps(a + i);
+        __m256 b1 = _mm256_loadu_ps(b + i);
+        __m256 a2 = _mm256_loadu_ps(a + i + 8);
+        __m256 b2 = _mm256_loadu_ps(b + i + 8);
+        sum1 = _mm256_add_ps(sum1, _mm256_max_ps(_mm256_sub_ps(a1, b1), _mm256_sub_ps(b1, a1)));
+        sum2 = _mm256_add_ps(sum2, _mm256_max_ps(_mm256_sub_ps(a2, b2), _mm256_sub_ps(b2, a2)));
+    }
+
+    if (i + 8 <= n)
+    {
+        __m256 a1 = _mm256_loadu_ps(a + i);
+        __m256 b1 = _mm256_loadu_ps(b + i);
+        sum1 = _mm256_add_ps(sum1, _mm256_max_ps(_mm256_sub_ps(a1, b1), _mm256_sub_ps(b1, a1)));
+        i += 8;
+    }
+
+    float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+    // handle leftovers if n is not a multiple of 8
+    for (; i < n; i++)
+    {
+        sum += a[i] > b[i] ? a[i] - b[i] : b[i] - a[i];
+    }
+
+    *result = sum;
+}
+
+void _dot_int8_avx2(signed char *a, signed char *b, long n, int *result)
+{
+    __m256i sum1 = _mm256_setzero_si256();
+    __m256i sum2 = _mm256_setzero_si256();
+    long i = 0;
+
+    // sign extend to 16 bits and multiply and add adjacent pairs to 32 bits
+    for (; i + 32 <= n; i += 32)
+    {
+        __m256i a1 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(a + i)));
+        __m256i b1 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(b + i)));
+        __m256i a2 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(a + i + 16)));
+        __m256i b2 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(b + i + 16)));
+        sum1 = _mm256_add_epi32(sum1, _mm256_madd_epi16(a1, b1));
+        sum2 = _mm256_add_epi32(sum2, _mm256_madd_epi16(a2, b2));
+    }
+
+    if (i + 16 <= n)
+    {
+        __m256i a1 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(a + i)));
+        __m256i b1 = _mm256_cvtepi8_epi16(_mm_loadu_si128((__m128i *)(b + i)));
+        sum1 = _mm256_add_epi32(sum1, _mm256_madd_epi16(a1, b1));
+        i += 16;
+    }
+
+    // horizontal sum of the 8 lanes
+    sum1 = _mm256_add_epi32(sum1, sum2);
+    __m128i sum = _mm_add_epi32(_mm256_castsi256_si128(sum1), _mm256_extracti128_si256(sum1, 1));
+    sum = _mm_add_epi32(sum, _mm_shuffle_epi32(sum, 0x4e));
+    sum = _mm_add_epi32(sum, _mm_shuffle_epi32(sum, 0xb1));
+    int ret = _mm_cvtsi128_si32(sum);
+
+    // handle leftovers if n is not a multiple of 16
+    for (; i < n; i++)
+    {
+        ret += a[i] * b[i];
+    }
+
+    *result = ret;
+}
+
+// converts 8 bfloat16 values to float32 by shifting them into the upper half
+static inline __m256 _cvtbf16_avx2(unsigned short *b)
+{
+    return _mm256_castsi256_ps(_mm256_slli_epi32(_mm256_cvtepu16_epi32(_mm_loadu_si128((__m128i *)b)), 16));
+}
+
+// converts a bfloat16 value to float32
+static inline float _cvtbf16_ss(unsigned short b)
+{
+    union
+    {
+        unsigned int u;
+        float f;
+    } v = {(unsigned int)b << 16};
+    return v.f;
+}
+
+void _dot_f16_avx2(float *a, unsigned short *b, long n, float *result)
+{
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    long i = 0;
+
+    // convert the half precision values with F16C
+    for (; i + 16 <= n; i += 16)
+    {
+        sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i))), sum1);
+        sum2 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i + 8), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i + 8))), sum2);
+    }
+
+    if (i + 8 <= n)
+    {
+        sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i))), sum1);
+        i += 8;
+    }
+
+    float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+    // handle leftovers if n is not a multiple of 8
+    for (; i < n; i++)
+    {
+        sum += a[i] * _cvtsh_ss(b[i]);
+    }
+
+    *result = sum;
+}
+
+void _squared_l2_f16_avx2(float *a, unsigned short *b, long n, float *result)
+{
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    long i = 0;
+
+    // convert the half precision values with F16C
+    for (; i + 16 <= n; i += 16)
+    {
+        __m256 diff1 = _mm256_sub_ps(_mm256_loadu_ps(a + i), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i))));
+        __m256 diff2 = _mm256_sub_ps(_mm256_loadu_ps(a + i + 8), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i + 8))));
+        sum1 = _mm256_fmadd_ps(diff1, diff1, sum1);
+        sum2 = _mm256_fmadd_ps(diff2, diff2, sum2);
+    }
+
+    if (i + 8 <= n)
+    {
+        __m256 diff = _mm256_sub_ps(_mm256_loadu_ps(a + i), _mm256_cvtph_ps(_mm_loadu_si128((__m128i *)(b + i))));
+        sum1 = _mm256_fmadd_ps(diff, diff, sum1);
+        i += 8;
+    }
+
+    float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+    // handle leftovers if n is not a multiple of 8
+    for (; i < n; i++)
+    {
+        float diff = a[i] - _cvtsh_ss(b[i]);
+        sum += diff * diff;
+    }
+
+    *result = sum;
+}
+
+void _dot_bf16_avx2(float *a, unsigned short *b, long n, float *result)
+{
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    long i = 0;
+
+    for (; i + 16 <= n; i += 16)
+    {
+        sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i), _cvtbf16_avx2(b + i), sum1);
+        sum2 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i + 8), _cvtbf16_avx2(b + i + 8), sum2);
+    }
+
+    if (i + 8 <= n)
+    {
+        sum1 = _mm256_fmadd_ps(_mm256_loadu_ps(a + i), _cvtbf16_avx2(b + i), sum1);
+        i += 8;
+    }
+
+    float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+    // handle leftovers if n is not a multiple of 8
+    for (; i < n; i++)
+    {
+        sum += a[i] * _cvtbf16_ss(b[i]);
+    }
+
+    *result = sum;
+}
+
+void _squared_l2_bf16_avx2(float *a, unsigned short *b, long n, float *result)
+{
+    __m256 sum1 = _mm256_setzero_ps();
+    __m256 sum2 = _mm256_setzero_ps();
+    long i = 0;
+
+    for (; i + 16 <= n; i += 16)
+    {
+        __m256 diff1 = _mm256_sub_ps(_mm256_loadu_ps(a + i), _cvtbf16_avx2(b + i));
+        __m256 diff2 = _mm256_sub_ps(_mm256_loadu_ps(a + i + 8), _cvtbf16_avx2(b + i + 8));
+        sum1 = _mm256_fmadd_ps(diff1, diff1, sum1);
+        sum2 = _mm256_fmadd_ps(diff2, diff2, sum2);
+    }
+
+    if (i + 8 <= n)
+    {
+        __m256 diff = _mm256_sub_ps(_mm256_loadu_ps(a + i), _cvtbf16_avx2(b + i));
+        sum1 = _mm256_fmadd_ps(diff, diff, sum1);
+        i += 8;
+    }
+
+    float sum = _hsum_avx2(_mm256_add_ps(sum1, sum2));
+
+    // handle leftovers if n is not a multiple of 8
+    for (; i < n; i++)
+    {
+        float diff = a[i] - _cvtbf16_ss(b[i]);
+        sum += diff * diff;
+    }
+
+    *result = sum;
+}
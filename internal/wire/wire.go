@@ -0,0 +1,42 @@
+// Package wire defines the HTTP+JSON protocol between the cache server and the remote engine.
+package wire
+
+// Paths of the API.
+const (
+	PathLookup = "/v1/lookup"
+	PathUpdate = "/v1/update"
+	PathDelete = "/v1/delete"
+	PathClear  = "/v1/clear"
+	PathStats  = "/v1/stats"
+)
+
+// HeaderNamespace is the header carrying the namespace of a request. Without it, the
+// request applies to the default namespace, a clear to all namespaces.
+const HeaderNamespace = "X-Llmcache-Namespace"
+
+// PromptRequest is the request of a lookup or delete.
+type PromptRequest struct {
+	Prompt string `json:"prompt"`
+}
+
+// LookupResponse is the response of a lookup.
+type LookupResponse struct {
+	Found bool `json:"found"`
+	// Result is the encoded result.
+	Result   []byte  `json:"result,omitempty"`
+	Prompt   string  `json:"prompt,omitempty"`
+	Distance float32 `json:"distance,omitempty"`
+	Exact    bool    `json:"exact,omitempty"`
+}
+
+// UpdateRequest is the request of an update.
+type UpdateRequest struct {
+	Prompt string `json:"prompt"`
+	// Result is the encoded result.
+	Result []byte `json:"result"`
+}
+
+// ErrorResponse is the response of a failed request.
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
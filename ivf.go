@@ -0,0 +1,496 @@
+package llmcache
+
+import (
+	"math"
+	"math/rand"
+	"sync"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+)
+
+// IVFOptions contains options for configuring the inverted file index of the LRUSimilarityEngine.
+// The index clusters the embeddings with k-means and only compares the query with the
+// entries of the closest clusters, so the lookup cost no longer grows linearly with the cache size.
+type IVFOptions struct {
+	// Lists is the number of inverted lists (k-means clusters). Default is 100.
+	Lists int
+	// Probes is the number of lists searched during lookup. Default is 8.
+	Probes int
+	// TrainingSize is the number of entries required to train the index. Until the index
+	// is trained, all entries are compared. Default is 10 entries per list.
+	TrainingSize int
+	// MaxTrainingSamples is the maximum number of embeddings used to train the index. Default is 256 per list.
+	MaxTrainingSamples int
+	// Iterations is the number of k-means iterations. Default is 20.
+	Iterations int
+	// RetrainRatio is the fraction of entries added or removed since the last training
+	// after which the index is retrained in the background. Default is 0.5.
+	RetrainRatio float64
+	// Seed is the seed of the random number generator used for training.
+	Seed int64
+}
+
+// ivfIndex is an inverted file index with k-means clustering.
+type ivfIndex struct {
+	mu   sync.RWMutex
+	opts IVFOptions
+	// vector returns the embedding of a cached prompt.
+	vector func(prompt string) ([]float32, bool)
+	// dim is the dimension of the centroids.
+	dim int
+	// centroids holds the centroids of the lists in a row-major matrix.
+	centroids []float32
+	// previous holds the centroids of the lists before the index was reset, with their dimension.
+	previous    []float32
+	previousDim int
+	// lists holds the prompts assigned to each list.
+	lists []map[string]struct{}
+	// assignments maps each prompt to its list, or to -1 if it is not assigned to a list.
+	assignments map[string]int
+	// unassigned holds the prompts which are not assigned to a list.
+	unassigned map[string]struct{}
+	// changes is the number of entries added or removed since the last training.
+	changes int
+	// training indicates that the index is trained in the background.
+	training bool
+	// pending records the changes made while the index is trained.
+	pending []ivfChange
+	// generation is incremented when the index is reset.
+	generation int
+	// rand is the random number generator used for training.
+	rand *rand.Rand
+	// onTrain is called without holding the lock after the lists were replaced by a training.
+	// lists maps each previous list to the new list with the closest centroid. It is nil if the
+	// index was not trained before or the dimension of the embeddings changed.
+	onTrain func(lists []int)
+}
+
+// ivfChange is an addition or removal made while the index is trained.
+type ivfChange struct {
+	prompt    string
+	embedding []float32
+}
+
+// newIVFIndex creates a new ivfIndex instance. vector is used to read the
+// embeddings of the cached prompts when the index is trained.
+func newIVFIndex(opts IVFOptions, vector func(prompt string) ([]float32, bool)) *ivfIndex {
+	if opts.Lists <= 0 {
+		opts.Lists = 100
+	}
+
+	if opts.Probes <= 0 {
+		opts.Probes = 8
+	}
+
+	if opts.TrainingSize <= 0 {
+		opts.TrainingSize = 10 * opts.Lists
+	}
+
+	if opts.MaxTrainingSamples <= 0 {
+		opts.MaxTrainingSamples = 256 * opts.Lists
+	}
+
+	if opts.Iterations <= 0 {
+		opts.Iterations = 20
+	}
+
+	if opts.RetrainRatio <= 0 {
+		opts.RetrainRatio = 0.5
+	}
+
+	return &ivfIndex{
+		opts:        opts,
+		vector:      vector,
+		assignments: make(map[string]int),
+		unassigned:  make(map[string]struct{}),
+		rand:        rand.New(rand.NewSource(opts.Seed)), // nolint gosec
+	}
+}
+
+func (idx *ivfIndex) add(prompt string, embedding []float32) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(prompt)
+	idx.assign(prompt, embedding)
+	idx.changes++
+
+	if idx.training {
+		idx.pending = append(idx.pending, ivfChange{prompt: prompt, embedding: embedding})
+		return
+	}
+
+	if idx.needsTraining() {
+		idx.training = true
+		go idx.train()
+	}
+}
+
+func (idx *ivfIndex) remove(prompt string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	if _, ok := idx.assignments[prompt]; !ok {
+		return
+	}
+
+	idx.removeLocked(prompt)
+	idx.changes++
+
+	if idx.training {
+		idx.pending = append(idx.pending, ivfChange{prompt: prompt})
+	}
+}
+
+// assign assigns the prompt to the list with the closest centroid. The caller must hold the lock.
+func (idx *ivfIndex) assign(prompt string, embedding []float32) {
+	if idx.centroids == nil || len(embedding) != idx.dim {
+		idx.assignments[prompt] = -1
+		idx.unassigned[prompt] = struct{}{}
+
+		return
+	}
+
+	list := idx.nearest(embedding, 1)[0]
+	idx.lists[list][prompt] = struct{}{}
+	idx.assignments[prompt] = list
+}
+
+// removeLocked removes the prompt from its list. The caller must hold the lock.
+func (idx *ivfIndex) removeLocked(prompt string) {
+	list, ok := idx.assignments[prompt]
+	if !ok {
+		return
+	}
+
+	if list >= 0 {
+		delete(idx.lists[list], prompt)
+	} else {
+		delete(idx.unassigned, prompt)
+	}
+
+	delete(idx.assignments, prompt)
+}
+
+func (idx *ivfIndex) search(embedding []float32) ([]string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.centroids == nil || len(embedding) != idx.dim {
+		return nil, false
+	}
+
+	var prompts []string
+
+	for _, list := range idx.nearest(embedding, idx.opts.Probes) {
+		for prompt := range idx.lists[list] {
+			prompts = append(prompts, prompt)
+		}
+	}
+
+	for prompt := range idx.unassigned {
+		prompts = append(prompts, prompt)
+	}
+
+	return prompts, true
+}
+
+// list returns the list the prompt is assigned to.
+func (idx *ivfIndex) list(prompt string) (int, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	list, ok := idx.assignments[prompt]
+
+	return list, ok && list >= 0
+}
+
+func (idx *ivfIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	// Keep the centroids, so that the lists of the next training can be mapped to them
+	if idx.centroids != nil {
+		idx.previous, idx.previousDim = idx.centroids, idx.dim
+	}
+
+	idx.centroids = nil
+	idx.lists = nil
+	idx.dim = 0
+	idx.assignments = make(map[string]int)
+	idx.unassigned = make(map[string]struct{})
+	idx.changes = 0
+	idx.pending = nil
+	idx.generation++
+}
+
+// needsTraining reports whether the index has to be (re)trained. The caller must hold the lock.
+func (idx *ivfIndex) needsTraining() bool {
+	size := len(idx.assignments)
+	if size < idx.opts.TrainingSize {
+		return false
+	}
+
+	return idx.centroids == nil || float64(idx.changes) >= idx.opts.RetrainRatio*float64(size)
+}
+
+// train trains the centroids with k-means on a sample of the indexed embeddings
+// and reassigns all entries to the new lists. Changes made during the training
+// are recorded and replayed once the new lists are in place.
+func (idx *ivfIndex) train() {
+	idx.mu.Lock()
+	idx.training = true
+	idx.pending = nil
+	generation := idx.generation
+
+	prompts := make([]string, 0, len(idx.assignments))
+	for prompt := range idx.assignments {
+		prompts = append(prompts, prompt)
+	}
+
+	r := rand.New(rand.NewSource(idx.rand.Int63())) // nolint gosec
+	idx.mu.Unlock()
+
+	// Read the embeddings without holding the lock, the engine calls into the
+	// index while holding the lock of the LRU cache.
+	n := min(len(prompts), idx.opts.MaxTrainingSamples)
+	samples := make([][]float32, 0, n)
+
+	for i := 0; i < n; i++ {
+		j := i + r.Intn(len(prompts)-i)
+		prompts[i], prompts[j] = prompts[j], prompts[i]
+
+		// All samples must have the same dimension
+		if v, ok := idx.vector(prompts[i]); ok && (len(samples) == 0 || len(v) == len(samples[0])) {
+			samples = append(samples, v)
+		}
+	}
+
+	centroids, dim := kmeans(samples, min(idx.opts.Lists, len(samples)), idx.opts.Iterations, r)
+	if centroids == nil {
+		idx.mu.Lock()
+		idx.training = false
+		idx.pending = nil
+		idx.mu.Unlock()
+
+		return
+	}
+
+	assignments := make(map[string]int, len(prompts))
+
+	for _, prompt := range prompts {
+		if v, ok := idx.vector(prompt); ok && len(v) == dim {
+			assignments[prompt] = nearestCentroids(centroids, v, 1)[0]
+		}
+	}
+
+	previous, previousDim, ok := idx.replace(generation, centroids, dim, assignments)
+	if !ok || idx.onTrain == nil {
+		return
+	}
+
+	var lists []int
+
+	if previous != nil && previousDim == dim {
+		lists = make([]int, len(previous)/dim)
+		for i := range lists {
+			lists[i] = nearestCentroids(centroids, previous[i*dim:(i+1)*dim], 1)[0]
+		}
+	}
+
+	idx.onTrain(lists)
+}
+
+// replace replaces the lists with the trained centroids and assignments and replays the changes
+// made during the training. It returns the replaced centroids with their dimension, or the centroids
+// before the last reset if the index was not trained since, and false if the index was reset during
+// the training.
+func (idx *ivfIndex) replace(generation int, centroids []float32, dim int, assignments map[string]int) ([]float32, int, bool) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.training = false
+
+	pending := idx.pending
+	idx.pending = nil
+
+	// The index was reset during the training
+	if generation != idx.generation {
+		return nil, 0, false
+	}
+
+	previous, previousDim := idx.centroids, idx.dim
+	if previous == nil {
+		previous, previousDim = idx.previous, idx.previousDim
+	}
+
+	current := idx.assignments
+
+	idx.centroids = centroids
+	idx.dim = dim
+	idx.lists = make([]map[string]struct{}, len(centroids)/dim)
+	idx.assignments = make(map[string]int, len(current))
+	idx.unassigned = make(map[string]struct{})
+	idx.changes = 0
+
+	for i := range idx.lists {
+		idx.lists[i] = make(map[string]struct{})
+	}
+
+	for prompt, list := range assignments {
+		idx.lists[list][prompt] = struct{}{}
+		idx.assignments[prompt] = list
+	}
+
+	// Replay the changes made during the training
+	for _, change := range pending {
+		idx.removeLocked(change.prompt)
+
+		if change.embedding != nil {
+			idx.assign(change.prompt, change.embedding)
+		}
+	}
+
+	// Keep the entries the training could not assign, e.g. with a different dimension
+	for prompt := range current {
+		if _, ok := idx.assignments[prompt]; !ok {
+			idx.assignments[prompt] = -1
+			idx.unassigned[prompt] = struct{}{}
+		}
+	}
+
+	return previous, previousDim, true
+}
+
+// nearest returns the n lists with the closest centroids. The caller must hold the lock.
+func (idx *ivfIndex) nearest(embedding []float32, n int) []int {
+	return nearestCentroids(idx.centroids, embedding, n)
+}
+
+// nearestCentroids returns the indices of the n centroids closest to the embedding.
+func nearestCentroids(centroids, embedding []float32, n int) []int {
+	distances := make([]float32, len(centroids)/len(embedding))
+	math32.SquaredL2Batch(embedding, centroids, distances)
+
+	n = min(n, len(distances))
+	nearest := make([]int, 0, n)
+
+	// Selection of the n smallest distances, n is usually small
+	for len(nearest) < n {
+		best := -1
+
+		for i, d := range distances {
+			if d >= 0 && (best < 0 || d < distances[best]) {
+				best = i
+			}
+		}
+
+		nearest = append(nearest, best)
+		distances[best] = -1
+	}
+
+	return nearest
+}
+
+// kmeans clusters the samples into k clusters with Lloyd's algorithm and returns
+// the centroids as a row-major matrix together with their dimension.
+func kmeans(samples [][]float32, k, iterations int, r *rand.Rand) ([]float32, int) {
+	if k <= 0 || len(samples) == 0 {
+		return nil, 0
+	}
+
+	dim := len(samples[0])
+	centroids := make([]float32, k*dim)
+
+	// Initialize the centroids with k-means++, which samples each new centroid
+	// with a probability proportional to its squared distance to the closest centroid.
+	copy(centroids, samples[r.Intn(len(samples))])
+
+	closest := make([]float64, len(samples))
+	for i := range closest {
+		closest[i] = math.MaxFloat64
+	}
+
+	for c := 1; c < k; c++ {
+		prev := centroids[(c-1)*dim : c*dim]
+
+		var total float64
+
+		for i, v := range samples {
+			closest[i] = math.Min(closest[i], float64(math32.SquaredL2(v, prev)))
+			total += closest[i]
+		}
+
+		next := r.Intn(len(samples))
+
+		if total > 0 {
+			target := r.Float64() * total
+			for i, d := range closest {
+				target -= d
+				if target <= 0 && d > 0 {
+					next = i
+					break
+				}
+			}
+		}
+
+		copy(centroids[c*dim:], samples[next])
+	}
+
+	assignments := make([]int, len(samples))
+	counts := make([]int, k)
+	distances := make([]float32, k)
+
+	for iter := 0; iter < iterations; iter++ {
+		changed := false
+
+		for i, v := range samples {
+			math32.SquaredL2Batch(v, centroids, distances)
+
+			best, bestDistance := 0, float32(math.MaxFloat32)
+			for c, d := range distances {
+				if d < bestDistance {
+					best, bestDistance = c, d
+				}
+			}
+
+			if iter == 0 || assignments[i] != best {
+				assignments[i] = best
+				changed = true
+			}
+		}
+
+		if !changed {
+			break
+		}
+
+		clear(centroids)
+		clear(counts)
+
+		for i, v := range samples {
+			c := assignments[i]
+			counts[c]++
+
+			row := centroids[c*dim : (c+1)*dim]
+			for j, x := range v {
+				row[j] += x
+			}
+		}
+
+		for c := 0; c < k; c++ {
+			row := centroids[c*dim : (c+1)*dim]
+
+			// Reseed empty clusters with a random sample
+			if counts[c] == 0 {
+				copy(row, samples[r.Intn(len(samples))])
+				continue
+			}
+
+			for j := range row {
+				row[j] /= float32(counts[c])
+			}
+		}
+	}
+
+	return centroids, dim
+}
@@ -0,0 +1,173 @@
+package llmcache
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKMeans(t *testing.T) {
+	r := rand.New(rand.NewSource(1)) // nolint gosec
+
+	// Two well separated clusters
+	samples := make([][]float32, 0, 100)
+	for i := 0; i < 50; i++ {
+		samples = append(samples, []float32{10 + r.Float32(), 10 + r.Float32()})
+		samples = append(samples, []float32{-10 - r.Float32(), -10 - r.Float32()})
+	}
+
+	centroids, dim := kmeans(samples, 2, 20, r)
+	assert.Equal(t, 2, dim)
+	assert.Len(t, centroids, 4)
+
+	a := nearestCentroids(centroids, []float32{10, 10}, 1)[0]
+	b := nearestCentroids(centroids, []float32{-10, -10}, 1)[0]
+	assert.NotEqual(t, a, b)
+	assert.InDelta(t, 10.5, centroids[a*dim], 0.5)
+	assert.InDelta(t, -10.5, centroids[b*dim], 0.5)
+
+	t.Run("No Samples", func(t *testing.T) {
+		centroids, _ := kmeans(nil, 2, 20, r)
+		assert.Nil(t, centroids)
+	})
+}
+
+func TestIVFIndex(t *testing.T) {
+	embeddings := make(map[string][]float32)
+	vector := func(prompt string) ([]float32, bool) {
+		v, ok := embeddings[prompt]
+		return v, ok
+	}
+
+	idx := newIVFIndex(IVFOptions{Lists: 2, Probes: 1, TrainingSize: 1000}, vector)
+
+	for i := 0; i < 20; i++ {
+		embeddings[fmt.Sprintf("a%d", i)] = []float32{1, float32(i) / 100}
+		embeddings[fmt.Sprintf("b%d", i)] = []float32{-1, float32(i) / 100}
+	}
+
+	for prompt, v := range embeddings {
+		idx.add(prompt, v)
+	}
+
+	_, ok := idx.search([]float32{1, 0})
+	assert.False(t, ok, "untrained index")
+
+	idx.train()
+
+	prompts, ok := idx.search([]float32{1, 0})
+	assert.True(t, ok)
+	assert.Len(t, prompts, 20)
+
+	for _, prompt := range prompts {
+		assert.Equal(t, byte('a'), prompt[0])
+	}
+
+	t.Run("Add", func(t *testing.T) {
+		idx.add("a20", []float32{1, 0.2})
+
+		prompts, _ := idx.search([]float32{1, 0})
+		assert.Contains(t, prompts, "a20")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		idx.remove("a0")
+
+		prompts, _ := idx.search([]float32{1, 0})
+		assert.NotContains(t, prompts, "a0")
+		assert.Len(t, prompts, 20)
+	})
+
+	t.Run("Unassigned", func(t *testing.T) {
+		idx.add("c", []float32{1, 2, 3})
+
+		prompts, _ := idx.search([]float32{-1, 0})
+		assert.Contains(t, prompts, "c")
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		idx.reset()
+
+		_, ok := idx.search([]float32{1, 0})
+		assert.False(t, ok)
+		assert.Empty(t, idx.assignments)
+	})
+}
+
+func TestLRUSimilarityEngine_IVF(t *testing.T) {
+	r := rand.New(rand.NewSource(1)) // nolint gosec
+	embedder := &mockEmbedder{embeddings: make(map[string][]float32)}
+
+	for i := 0; i < 200; i++ {
+		embedding := make([]float32, 16)
+		embedding[i%8] = 1
+
+		for j := range embedding {
+			embedding[j] += r.Float32() * 0.05
+		}
+
+		embedder.embeddings[fmt.Sprintf("prompt%d", i)] = embedding
+	}
+
+	cache, err := NewLRUSimilarityEngine[string](embedder, func(o *LRUSimilarityEngineOptions) {
+		o.MaxCacheSize = 150
+		o.Threshold = 0.05
+		o.IVF = &IVFOptions{
+			Lists:        8,
+			Probes:       1,
+			TrainingSize: 50,
+		}
+	})
+	assert.NoError(t, err)
+
+	ctx := context.TODO()
+
+	for i := 0; i < 100; i++ {
+		err = cache.Update(ctx, fmt.Sprintf("prompt%d", i), fmt.Sprintf("result%d", i%8))
+		assert.NoError(t, err)
+	}
+
+	idx := cache.index.(*ivfIndex)
+
+	assert.Eventually(t, func() bool {
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+
+		return idx.centroids != nil && !idx.training
+	}, time.Second, time.Millisecond)
+
+	// Similar prompts are found in the probed list
+	for i := 100; i < 108; i++ {
+		result, ok := cache.Lookup(ctx, fmt.Sprintf("prompt%d", i))
+		assert.True(t, ok)
+		assert.Equal(t, fmt.Sprintf("result%d", i%8), result)
+	}
+
+	// Evicted entries are removed from the lists
+	for i := 108; i < 200; i++ {
+		err = cache.Update(ctx, fmt.Sprintf("prompt%d", i), fmt.Sprintf("result%d", i%8))
+		assert.NoError(t, err)
+	}
+
+	assert.Eventually(t, func() bool {
+		idx.mu.RLock()
+		defer idx.mu.RUnlock()
+
+		return !idx.training
+	}, time.Second, time.Millisecond)
+
+	idx.mu.RLock()
+	assert.Len(t, idx.assignments, cache.cache.Len())
+	idx.mu.RUnlock()
+
+	err = cache.Clear(ctx)
+	assert.NoError(t, err)
+
+	idx.mu.RLock()
+	assert.Empty(t, idx.assignments)
+	idx.mu.RUnlock()
+}
@@ -0,0 +1,352 @@
+package llmcache
+
+import (
+	"math"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// LexicalScorer is the function used to score the lexical similarity of two prompts.
+type LexicalScorer int
+
+const (
+	// LexicalJaccard scores the Jaccard similarity of the token sets of the prompts.
+	LexicalJaccard LexicalScorer = iota
+	// LexicalBM25 scores the prompts with Okapi BM25, weighting rare tokens of the cached
+	// prompts higher than frequent ones. The score is normalized by the score of the query itself.
+	LexicalBM25
+)
+
+// String returns the name of the lexical scorer.
+func (s LexicalScorer) String() string {
+	switch s {
+	case LexicalJaccard:
+		return "jaccard"
+	case LexicalBM25:
+		return "bm25"
+	default:
+		return "unknown"
+	}
+}
+
+// Extractor extracts the parts of a prompt which must match exactly, e.g. numbers or names.
+type Extractor func(text string) []string
+
+// LexicalOptions contains options for configuring the lexical guard of the LRUSimilarityEngine.
+// Prompts which differ only by a number, a name or a negation often have very close embeddings.
+// The guard adds the weighted lexical distance to the embedding distance of each candidate
+// and rejects candidates whose extracted values differ from the query.
+type LexicalOptions struct {
+	// Scorer is the lexical similarity function. Default is LexicalJaccard.
+	Scorer LexicalScorer
+	// Weight is the weight of the lexical distance (1 - similarity) added to the embedding distance
+	// before it is compared with the threshold. Default is 0.1.
+	Weight float32
+	// MinSimilarity is the minimum lexical similarity of a match. Default is 0.
+	MinSimilarity float32
+	// MustMatch are the extractors whose values must be equal for the query and a match.
+	MustMatch []Extractor
+}
+
+// RegexExtractor returns an extractor which extracts all matches of the regular expression.
+func RegexExtractor(re *regexp.Regexp) Extractor {
+	return func(text string) []string {
+		return re.FindAllString(text, -1)
+	}
+}
+
+const (
+	monthPattern = `(?:jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)\.?`
+	dayPattern   = `\d{1,2}(?:st|nd|rd|th)?`
+)
+
+var (
+	numberRegex = regexp.MustCompile(`[-+]?\d+(?:[.,]\d+)*`)
+	// Month names are only matched together with a day or a year, e.g. not in "May I ask"
+	dateRegex = regexp.MustCompile(`(?i)\b(?:\d{4}-\d{1,2}-\d{1,2}|\d{1,2}[./]\d{1,2}[./]\d{2,4}|` +
+		dayPattern + `\s+` + monthPattern + `(?:,?\s+\d{4})?|` +
+		monthPattern + `\s+` + dayPattern + `(?:,?\s+\d{4})?|` +
+		monthPattern + `,?\s+\d{4})\b`)
+	negationRegex = regexp.MustCompile(`(?i)\b(?:not|no|never|none|nobody|nothing|neither|nor|without)\b|n't\b`)
+)
+
+// NumberExtractor extracts all numbers of a prompt.
+func NumberExtractor(text string) []string {
+	return numberRegex.FindAllString(text, -1)
+}
+
+// DateExtractor extracts all dates of a prompt, e.g. "2024-01-31", "31.01.2024" or "January 31, 2024".
+// Month names are lower-cased.
+func DateExtractor(text string) []string {
+	dates := dateRegex.FindAllString(text, -1)
+	for i, d := range dates {
+		dates[i] = strings.ToLower(d)
+	}
+
+	return dates
+}
+
+// EntityExtractor extracts the capitalized words of a prompt, which are not at the
+// beginning of a sentence, as a simple approximation of named entities.
+func EntityExtractor(text string) []string {
+	var entities []string
+
+	sentenceStart := true
+
+	for _, field := range strings.Fields(text) {
+		word := strings.TrimFunc(field, func(r rune) bool {
+			return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+		})
+
+		if word != "" && !sentenceStart && word != "I" {
+			if r := []rune(word)[0]; unicode.IsUpper(r) {
+				entities = append(entities, word)
+			}
+		}
+
+		sentenceStart = strings.ContainsAny(field[len(field)-1:], ".!?:")
+	}
+
+	return entities
+}
+
+// NegationExtractor extracts a marker if a prompt contains a negation, e.g. "not", "never" or "don't".
+func NegationExtractor(text string) []string {
+	if negationRegex.MatchString(text) {
+		return []string{"not"}
+	}
+
+	return nil
+}
+
+// lexicalMatcher scores the lexical similarity of a query and the cached prompts.
+type lexicalMatcher struct {
+	mu   sync.RWMutex
+	opts LexicalOptions
+	// prompts holds the indexed prompts.
+	prompts map[string]struct{}
+	// length is the total number of tokens of the indexed prompts.
+	length int
+	// frequencies is the number of indexed prompts containing each token.
+	frequencies map[string]int
+}
+
+// newLexicalMatcher creates a new lexicalMatcher instance.
+func newLexicalMatcher(opts LexicalOptions) *lexicalMatcher {
+	if opts.Weight <= 0 {
+		opts.Weight = 0.1
+	}
+
+	return &lexicalMatcher{
+		opts:        opts,
+		prompts:     make(map[string]struct{}),
+		frequencies: make(map[string]int),
+	}
+}
+
+// lexicalQuery holds the tokens and extracted values of a query.
+type lexicalQuery struct {
+	tokens []string
+	values [][]string
+}
+
+// query tokenizes the text and extracts the values which must match.
+func (m *lexicalMatcher) query(text string) *lexicalQuery {
+	q := &lexicalQuery{
+		tokens: tokenize(text),
+		values: make([][]string, len(m.opts.MustMatch)),
+	}
+
+	for i, extract := range m.opts.MustMatch {
+		q.values[i] = valueSet(extract(text))
+	}
+
+	return q
+}
+
+// add adds the tokens of the prompt to the BM25 statistics.
+func (m *lexicalMatcher) add(prompt string) {
+	if m.opts.Scorer != LexicalBM25 {
+		return
+	}
+
+	// The prompt is a cache key, which may be qualified with a namespace
+	_, text := splitNamespaceKey(prompt)
+	tokens := tokenize(text)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.prompts[prompt]; ok {
+		return
+	}
+
+	m.prompts[prompt] = struct{}{}
+	m.length += len(tokens)
+
+	for _, t := range uniqueTokens(tokens) {
+		m.frequencies[t]++
+	}
+}
+
+// remove removes the tokens of the prompt from the BM25 statistics.
+func (m *lexicalMatcher) remove(prompt string) {
+	if m.opts.Scorer != LexicalBM25 {
+		return
+	}
+
+	_, text := splitNamespaceKey(prompt)
+	tokens := tokenize(text)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.prompts[prompt]; !ok {
+		return
+	}
+
+	delete(m.prompts, prompt)
+	m.length -= len(tokens)
+
+	for _, t := range uniqueTokens(tokens) {
+		if m.frequencies[t]--; m.frequencies[t] <= 0 {
+			delete(m.frequencies, t)
+		}
+	}
+}
+
+// reset removes all prompts from the BM25 statistics.
+func (m *lexicalMatcher) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.prompts = make(map[string]struct{})
+	m.length = 0
+	m.frequencies = make(map[string]int)
+}
+
+// score returns the combined distance of a candidate with the given embedding distance.
+// It returns false if the candidate is rejected by an extractor or the minimum similarity.
+func (m *lexicalMatcher) score(q *lexicalQuery, prompt string, distance float32) (float32, bool) {
+	for i, extract := range m.opts.MustMatch {
+		if !slices.Equal(q.values[i], valueSet(extract(prompt))) {
+			return 0, false
+		}
+	}
+
+	var similarity float32
+
+	switch m.opts.Scorer {
+	case LexicalBM25:
+		similarity = m.bm25(q.tokens, tokenize(prompt))
+	default:
+		similarity = jaccard(q.tokens, tokenize(prompt))
+	}
+
+	if similarity < m.opts.MinSimilarity {
+		return 0, false
+	}
+
+	return distance + m.opts.Weight*(1-similarity), true
+}
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// bm25 returns the BM25 score of the document for the query, normalized by the score
+// of the query itself, so that the result is between 0 and 1.
+func (m *lexicalMatcher) bm25(query, doc []string) float32 {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	avgLength := float64(len(query))
+	if len(m.prompts) > 0 {
+		avgLength = float64(m.length) / float64(len(m.prompts))
+	}
+
+	// Terms of the query which are not indexed occur in no document
+	docs := max(len(m.prompts), 1)
+
+	termFrequencies := func(tokens []string) map[string]int {
+		tf := make(map[string]int, len(tokens))
+		for _, t := range tokens {
+			tf[t]++
+		}
+
+		return tf
+	}
+
+	score := func(tf map[string]int, length int) float64 {
+		var s float64
+
+		for _, t := range uniqueTokens(query) {
+			f := float64(tf[t])
+			if f == 0 {
+				continue
+			}
+
+			df := float64(m.frequencies[t])
+			idf := math.Log(1 + (float64(docs)-df+0.5)/(df+0.5))
+			s += idf * f * (bm25K1 + 1) / (f + bm25K1*(1-bm25B+bm25B*float64(length)/max(avgLength, 1)))
+		}
+
+		return s
+	}
+
+	maxScore := score(termFrequencies(query), len(query))
+	if maxScore == 0 {
+		return 0
+	}
+
+	return float32(min(score(termFrequencies(doc), len(doc))/maxScore, 1))
+}
+
+// jaccard returns the Jaccard similarity of the token sets.
+func jaccard(a, b []string) float32 {
+	setA := uniqueTokens(a)
+	setB := uniqueTokens(b)
+
+	if len(setA) == 0 && len(setB) == 0 {
+		return 1
+	}
+
+	intersection := 0
+
+	for _, t := range setA {
+		if _, ok := slices.BinarySearch(setB, t); ok {
+			intersection++
+		}
+	}
+
+	return float32(intersection) / float32(len(setA)+len(setB)-intersection)
+}
+
+// tokenize splits the text into lower-cased words.
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+
+// uniqueTokens returns the sorted set of the tokens.
+func uniqueTokens(tokens []string) []string {
+	set := slices.Clone(tokens)
+	slices.Sort(set)
+
+	return slices.Compact(set)
+}
+
+// valueSet returns the sorted set of the extracted values.
+func valueSet(values []string) []string {
+	if len(values) == 0 {
+		return nil
+	}
+
+	return uniqueTokens(values)
+}
@@ -0,0 +1,170 @@
+package llmcache
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExtractors(t *testing.T) {
+	tests := []struct {
+		name      string
+		extractor Extractor
+		text      string
+		expected  []string
+	}{
+		{"Number", NumberExtractor, "What is 12 times -3.5?", []string{"12", "-3.5"}},
+		{"Number None", NumberExtractor, "What is the capital of France?", nil},
+		{"Date ISO", DateExtractor, "What happened on 2024-01-31?", []string{"2024-01-31"}},
+		{"Date Dotted", DateExtractor, "What happened on 31.01.2024?", []string{"31.01.2024"}},
+		{"Date Month Name", DateExtractor, "What happened on January 31, 2024?", []string{"january 31, 2024"}},
+		{"Date Month Without Day", DateExtractor, "May I ask what happened?", nil},
+		{"Entity", EntityExtractor, "What year was Albert Einstein born? Return only the year!", []string{"Albert", "Einstein"}},
+		{"Entity Sentence Start", EntityExtractor, "Who is the CEO? I want to know.", []string{"CEO"}},
+		{"Negation", NegationExtractor, "Which planets don't have moons?", []string{"not"}},
+		{"Negation None", NegationExtractor, "Which planets have moons?", nil},
+		{"Regex", RegexExtractor(regexp.MustCompile(`#\w+`)), "Show issue #42 and #7", []string{"#42", "#7"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, tt.extractor(tt.text))
+		})
+	}
+}
+
+func TestJaccard(t *testing.T) {
+	assert.Equal(t, float32(1), jaccard(tokenize("What is Go?"), tokenize("what is go")))
+	assert.Equal(t, float32(0.5), jaccard(tokenize("a b c"), tokenize("a b d")))
+	assert.Equal(t, float32(0), jaccard(tokenize("a b"), tokenize("c d")))
+	assert.Equal(t, float32(1), jaccard(nil, nil))
+}
+
+func TestLexicalMatcher(t *testing.T) {
+	t.Run("Jaccard", func(t *testing.T) {
+		m := newLexicalMatcher(LexicalOptions{Weight: 0.2})
+		q := m.query("a b c")
+
+		score, ok := m.score(q, "a b d", 0.1)
+		assert.True(t, ok)
+		assert.InDelta(t, 0.2, score, 1e-6)
+	})
+
+	t.Run("BM25", func(t *testing.T) {
+		m := newLexicalMatcher(LexicalOptions{Scorer: LexicalBM25})
+
+		for _, prompt := range []string{"the capital of france", "the capital of spain", "the capital of italy"} {
+			m.add(prompt)
+		}
+
+		q := tokenize("the capital of france")
+
+		assert.InDelta(t, 1, m.bm25(q, tokenize("the capital of france")), 1e-6)
+		// The rare token "france" weighs more than the frequent tokens
+		assert.Less(t, m.bm25(q, tokenize("the capital of spain")), m.bm25(q, tokenize("france")))
+
+		m.add("the capital of france")
+		m.remove("the capital of spain")
+		m.remove("the capital of spain")
+		assert.Len(t, m.prompts, 2)
+		assert.Equal(t, 1, m.frequencies["france"])
+		assert.NotContains(t, m.frequencies, "spain")
+
+		m.reset()
+		assert.Empty(t, m.frequencies)
+		assert.Zero(t, m.length)
+	})
+
+	t.Run("Must Match", func(t *testing.T) {
+		m := newLexicalMatcher(LexicalOptions{MustMatch: []Extractor{NumberExtractor}})
+		q := m.query("What is 2 plus 2?")
+
+		_, ok := m.score(q, "What is 2 plus 3?", 0)
+		assert.False(t, ok)
+
+		_, ok = m.score(q, "What's 2 plus 2?", 0)
+		assert.True(t, ok)
+	})
+
+	t.Run("Min Similarity", func(t *testing.T) {
+		m := newLexicalMatcher(LexicalOptions{MinSimilarity: 0.5})
+		q := m.query("a b c d")
+
+		_, ok := m.score(q, "a e f g", 0)
+		assert.False(t, ok)
+	})
+}
+
+func TestLRUSimilarityEngine_Lexical(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"What year was Albert Einstein born?": {0.1, 0.2, 0.3, 0.4},
+			"What year was Alan Turing born?":     {0.1, 0.2, 0.3, 0.41},
+			"In what year was Einstein born?":     {0.1, 0.2, 0.31, 0.4},
+			"What is 2 plus 2?":                   {0.5, 0.2, 0.3, 0.4},
+			"What is 2 plus 3?":                   {0.5, 0.2, 0.3, 0.41},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		lexical  *LexicalOptions
+		prompt   string
+		expected bool
+	}{
+		{"Without Guard", nil, "What year was Alan Turing born?", true},
+		{"Entity", &LexicalOptions{MustMatch: []Extractor{EntityExtractor}}, "What year was Alan Turing born?", false},
+		{"Number", &LexicalOptions{MustMatch: []Extractor{NumberExtractor}}, "What is 2 plus 3?", false},
+		{"Weight", &LexicalOptions{Weight: 1}, "What year was Alan Turing born?", false},
+		{"Similar", &LexicalOptions{Scorer: LexicalBM25, MustMatch: []Extractor{NumberExtractor}}, "In what year was Einstein born?", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+				o.Lexical = tt.lexical
+			})
+			assert.NoError(t, err)
+
+			ctx := context.TODO()
+
+			err = cache.Update(ctx, "What year was Albert Einstein born?", "1879")
+			assert.NoError(t, err)
+
+			err = cache.Update(ctx, "What is 2 plus 2?", "4")
+			assert.NoError(t, err)
+
+			_, ok := cache.Lookup(ctx, tt.prompt)
+			assert.Equal(t, tt.expected, ok)
+		})
+	}
+
+	t.Run("BM25 Statistics", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Lexical = &LexicalOptions{Scorer: LexicalBM25}
+			o.Threshold = 0.0001
+		})
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		assert.NoError(t, cache.Update(ctx, "What year was Albert Einstein born?", "1879"))
+
+		// The embedding of a lookup is cached, but only cached results count
+		_, ok := cache.Lookup(ctx, "What year was Alan Turing born?")
+		assert.False(t, ok)
+		assert.Equal(t, 2, cache.Len())
+		assert.Len(t, cache.lexical.prompts, 1)
+		assert.NotContains(t, cache.lexical.frequencies, "turing")
+
+		assert.NoError(t, cache.Update(ctx, "What year was Alan Turing born?", "1912"))
+		assert.Len(t, cache.lexical.prompts, 2)
+		assert.Equal(t, 1, cache.lexical.frequencies["turing"])
+		assert.Equal(t, 2, cache.lexical.frequencies["year"])
+
+		assert.NoError(t, cache.Delete(ctx, "What year was Alan Turing born?"))
+		assert.NotContains(t, cache.lexical.frequencies, "turing")
+	})
+}
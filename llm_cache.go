@@ -19,12 +19,60 @@ type Engine[T comparable] interface {
 	Clear(ctx context.Context) error
 }
 
+// Match describes the cache entry matched by a lookup.
+type Match[T comparable] struct {
+	// Result is the cached result.
+	Result T
+	// Prompt is the cached prompt of the matched entry.
+	Prompt string
+	// Distance is the distance between the looked up prompt and the cached prompt.
+	Distance float32
+	// Exact indicates that the cached prompt is equal to the looked up prompt.
+	Exact bool
+}
+
+// Matcher is implemented by engines which describe the entry matched by a lookup.
+type Matcher[T comparable] interface {
+	// LookupMatch retrieves the cached entry matching the given prompt.
+	// It returns the match and a boolean indicating whether a match was found.
+	LookupMatch(ctx context.Context, prompt string) (Match[T], bool)
+}
+
+// Deleter is implemented by engines which remove single entries.
+type Deleter interface {
+	// Delete removes the entry of the given prompt. Deleting a prompt which is not cached is not an error.
+	Delete(ctx context.Context, prompt string) error
+}
+
+// Stats contains the usage statistics of a cache.
+type Stats struct {
+	// Lookups is the number of lookups.
+	Lookups int64 `json:"lookups"`
+	// Hits is the number of lookups which found a result.
+	Hits int64 `json:"hits"`
+	// Misses is the number of lookups which found no result.
+	Misses int64 `json:"misses"`
+	// Updates is the number of updates.
+	Updates int64 `json:"updates"`
+	// Deletes is the number of deletes.
+	Deletes int64 `json:"deletes"`
+	// Entries is the number of cached entries.
+	Entries int `json:"entries"`
+}
+
 // CacheEntry represents an entry in the cache.
 type CacheEntry[T comparable] struct {
 	// Embedding is the vector representation of the text.
+	// It is nil if the embedding is stored quantized.
 	Embedding []float32
 	// Result is the cached result associated with the text.
 	Result T
+	// norm is the cached L2 norm of the embedding.
+	norm float32
+	// embedding is the embedding in the configured storage format.
+	embedding storedEmbedding
+	// namespace is the namespace of the entry.
+	namespace string
 }
 
 // Embedder is an interface for embedding queries.
@@ -34,27 +82,223 @@ type Embedder interface {
 	EmbedText(ctx context.Context, text string) ([]float32, error)
 }
 
+// LLMCacheOptions contains options for configuring the LLMCache.
+type LLMCacheOptions struct {
+	// Normalizer normalizes the prompts of lookups and updates before they reach the engine,
+	// so the engine only stores and embeds normalized prompts. If nil, prompts are used verbatim.
+	Normalizer PromptNormalizer
+	// DiscardOriginal indicates whether the original prompt is discarded after normalization.
+	// If false, exact matches report the prompt passed to the lookup; if true, they report the
+	// normalized prompt, so the original prompt is not retained beyond the call.
+	DiscardOriginal bool
+	// NegativeCache enables the caching of failures of GetOrCompute, so failing prompts are not
+	// recomputed until the failure expires. If nil, failures are not cached.
+	NegativeCache *NegativeCacheOptions
+	// Refresh enables the expiration of entries and the background refresh of stale entries,
+	// see SetRefreshFunc. If nil, entries do not expire.
+	Refresh *RefreshOptions
+}
+
 // LLMCache is a cache implementation that utilizes an Engine.
 type LLMCache[T comparable] struct {
 	// engine is the underlying engine used for lookup and update operations.
 	engine Engine[T]
+	// opts contains options for configuring the LLMCache.
+	opts LLMCacheOptions
+	// failures caches the failures of GetOrCompute. It is nil if failures are not cached.
+	failures *negativeCache
+	// refresher expires and refreshes entries. It is nil if entries do not expire.
+	refresher *refresher[T]
 }
 
-// New creates a new LLMCache instance with the provided engine.
-func New[T comparable](engine Engine[T]) *LLMCache[T] {
-	return &LLMCache[T]{
+// New creates a new LLMCache instance with the provided engine and options.
+func New[T comparable](engine Engine[T], optFns ...func(o *LLMCacheOptions)) *LLMCache[T] {
+	opts := LLMCacheOptions{}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	c := &LLMCache[T]{
 		engine: engine,
+		opts:   opts,
+	}
+
+	if opts.NegativeCache != nil {
+		c.failures = newNegativeCache(*opts.NegativeCache)
+	}
+
+	if opts.Refresh != nil {
+		c.refresher = newRefresher[T](*opts.Refresh)
 	}
+
+	return c
 }
 
 // Lookup retrieves the cached result associated with the given prompt.
 // It returns the result and a boolean indicating whether the result was found.
 func (c *LLMCache[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
-	return c.engine.Lookup(ctx, prompt)
+	if c.refresher == nil {
+		return c.engine.Lookup(ctx, c.normalize(prompt))
+	}
+
+	match, ok := c.lookup(ctx, prompt, c.normalize(prompt))
+
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the cached entry matching the given prompt. If the engine does not
+// implement Matcher, a found result is reported as an exact match of the prompt.
+// It returns the match and a boolean indicating whether a match was found.
+func (c *LLMCache[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	match, ok := c.lookup(ctx, prompt, c.normalize(prompt))
+	if ok && match.Exact && !c.opts.DiscardOriginal {
+		match.Prompt = prompt
+	}
+
+	return match, ok
 }
 
 // Update updates the cache with the provided prompt and result.
 // It returns an error if the update operation fails.
 func (c *LLMCache[T]) Update(ctx context.Context, prompt string, result T) error {
-	return c.engine.Update(ctx, prompt, result)
+	return c.update(ctx, c.normalize(prompt), result)
+}
+
+// GetOrCompute retrieves the cached result of the prompt, or computes and caches it on a miss.
+// If failures are cached, a cacheable error of compute is cached as well and returned as
+// CachedError, without calling compute, until it expires. The computed result is returned
+// even if updating the cache fails.
+func (c *LLMCache[T]) GetOrCompute(ctx context.Context, prompt string, compute ComputeFunc[T]) (T, error) {
+	normalized := c.normalize(prompt)
+
+	if c.refresher == nil {
+		if result, ok := c.engine.Lookup(ctx, normalized); ok {
+			return result, nil
+		}
+	} else if match, ok := c.lookup(ctx, prompt, normalized); ok {
+		return match.Result, nil
+	}
+
+	key := namespaceKey(NamespaceFromContext(ctx), normalized)
+
+	if c.failures != nil {
+		if failure := c.failures.get(key); failure != nil {
+			return *new(T), failure
+		}
+	}
+
+	result, err := compute(ctx, prompt)
+	if err != nil {
+		if c.failures != nil {
+			c.failures.add(key, err)
+		}
+
+		return *new(T), err
+	}
+
+	if err := c.update(ctx, normalized, result); err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// lookup retrieves the entry matching the normalized prompt. Expired entries are misses
+// and stale entries hit exactly are refreshed in the background with the prompt.
+func (c *LLMCache[T]) lookup(ctx context.Context, prompt, normalized string) (Match[T], bool) {
+	match, ok := lookupMatch(ctx, c.engine, normalized)
+	if !ok || c.refresher == nil {
+		return match, ok
+	}
+
+	if !c.fresh(ctx, prompt, match) {
+		return Match[T]{}, false
+	}
+
+	return match, true
+}
+
+// update updates the entry of the normalized prompt, replaces its cached failure and
+// records its write time.
+func (c *LLMCache[T]) update(ctx context.Context, normalized string, result T) error {
+	key := namespaceKey(NamespaceFromContext(ctx), normalized)
+
+	if c.failures != nil {
+		c.failures.remove(key)
+	}
+
+	if err := c.engine.Update(ctx, normalized, result); err != nil {
+		return err
+	}
+
+	if c.refresher != nil {
+		c.refresher.touch(key)
+	}
+
+	return nil
+}
+
+// normalize returns the normalized prompt, or the prompt if no normalizer is configured.
+func (c *LLMCache[T]) normalize(prompt string) string {
+	if c.opts.Normalizer == nil {
+		return prompt
+	}
+
+	return c.opts.Normalizer.Normalize(prompt)
+}
+
+// Namespace returns a view of the cache whose operations apply to the namespace.
+func (c *LLMCache[T]) Namespace(namespace string) *Namespace[T] {
+	return &Namespace[T]{
+		cache: c,
+		name:  namespace,
+	}
+}
+
+// ClearNamespace removes all entries of the namespace.
+// It returns ErrNamespacesNotSupported if the engine cannot clear a namespace.
+func (c *LLMCache[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	clearer, ok := c.engine.(interface {
+		ClearNamespace(ctx context.Context, namespace string) error
+	})
+	if !ok {
+		return ErrNamespacesNotSupported
+	}
+
+	if c.failures != nil {
+		c.failures.clearNamespace(namespace)
+	}
+
+	if c.refresher != nil {
+		c.refresher.clearNamespace(namespace)
+	}
+
+	return clearer.ClearNamespace(ctx, namespace)
+}
+
+// NamespaceStats returns the usage statistics of the namespace.
+// It returns ErrNamespacesNotSupported if the engine does not implement Namespacer.
+func (c *LLMCache[T]) NamespaceStats(namespace string) (Stats, error) {
+	n, ok := c.engine.(Namespacer)
+	if !ok {
+		return Stats{}, ErrNamespacesNotSupported
+	}
+
+	return n.NamespaceStats(namespace), nil
+}
+
+// lookupMatch retrieves the entry matching the prompt from the engine. If the engine does not
+// implement Matcher, a found result is reported as an exact match of the prompt.
+func lookupMatch[T comparable](ctx context.Context, engine Engine[T], prompt string) (Match[T], bool) {
+	if m, ok := engine.(Matcher[T]); ok {
+		return m.LookupMatch(ctx, prompt)
+	}
+
+	result, ok := engine.Lookup(ctx, prompt)
+	if !ok {
+		return Match[T]{}, false
+	}
+
+	return Match[T]{Result: result, Prompt: prompt, Exact: true}, true
 }
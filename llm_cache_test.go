@@ -52,6 +52,111 @@ func TestLLMCache_LookupAndUpdate(t *testing.T) {
 	}
 }
 
+func TestLLMCache_LookupMatch(t *testing.T) {
+	t.Run("Engine", func(t *testing.T) {
+		cache := New[string](&mockEngine[string]{
+			cache: map[string]string{
+				"prompt1": "result1",
+			},
+		})
+
+		match, ok := cache.LookupMatch(context.Background(), "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, Match[string]{Result: "result1", Prompt: "prompt1", Exact: true}, match)
+
+		_, ok = cache.LookupMatch(context.Background(), "prompt2")
+		assert.False(t, ok)
+	})
+
+	t.Run("Matcher", func(t *testing.T) {
+		engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{
+			embeddings: map[string][]float32{
+				"prompt1": {0.1, 0.2, 0.3, 0.4},
+				"prompt2": {0.1, 0.2, 0.3, 0.41},
+			},
+		})
+		assert.NoError(t, err)
+
+		cache := New[string](engine)
+
+		err = cache.Update(context.Background(), "prompt1", "result1")
+		assert.NoError(t, err)
+
+		match, ok := cache.LookupMatch(context.Background(), "prompt2")
+		assert.True(t, ok)
+		assert.Equal(t, "prompt1", match.Prompt)
+		assert.False(t, match.Exact)
+	})
+}
+
+func TestLLMCache_Normalizer(t *testing.T) {
+	ctx := context.Background()
+	normalizer := NormalizerPipeline(WhitespaceNormalizer{}, CaseFoldNormalizer{}, NewPIIMasker())
+
+	t.Run("Lookup And Update", func(t *testing.T) {
+		engine := &mockEngine[string]{cache: map[string]string{}}
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.Normalizer = normalizer
+		})
+
+		err := cache.Update(ctx, "  Reset the password of  jane@example.com ", "done")
+		assert.NoError(t, err)
+
+		// Only the normalized prompt reaches the engine
+		assert.Equal(t, map[string]string{"reset the password of <EMAIL>": "done"}, engine.cache)
+
+		result, ok := cache.Lookup(ctx, "RESET the password of john@example.org")
+		assert.True(t, ok)
+		assert.Equal(t, "done", result)
+
+		match, ok := cache.LookupMatch(ctx, "Reset the password of john@example.org")
+		assert.True(t, ok)
+		assert.Equal(t, Match[string]{Result: "done", Prompt: "Reset the password of john@example.org", Exact: true}, match)
+	})
+
+	t.Run("Discard Original", func(t *testing.T) {
+		cache := New[string](&mockEngine[string]{cache: map[string]string{}}, func(o *LLMCacheOptions) {
+			o.Normalizer = normalizer
+			o.DiscardOriginal = true
+		})
+
+		assert.NoError(t, cache.Update(ctx, "Call me at 555-123-4567", "ok"))
+
+		match, ok := cache.LookupMatch(ctx, "call me at (555) 987-6543")
+		assert.True(t, ok)
+		assert.Equal(t, "call me at <PHONE>", match.Prompt)
+	})
+
+	t.Run("Embedder", func(t *testing.T) {
+		embedder := &recordingEmbedder{Embedder: NewHashEmbedder()}
+
+		engine, err := NewLRUSimilarityEngine[string](embedder)
+		assert.NoError(t, err)
+
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.Normalizer = normalizer
+		})
+
+		assert.NoError(t, cache.Update(ctx, "Ticket for jane@example.com", "ok"))
+
+		_, ok := cache.Lookup(ctx, "ticket for  john@example.org")
+		assert.True(t, ok)
+
+		assert.Equal(t, []string{"ticket for <EMAIL>"}, embedder.texts)
+	})
+}
+
+// recordingEmbedder records the embedded texts.
+type recordingEmbedder struct {
+	Embedder
+	texts []string
+}
+
+func (e *recordingEmbedder) EmbedText(ctx context.Context, text string) ([]float32, error) {
+	e.texts = append(e.texts, text)
+	return e.Embedder.EmbedText(ctx, text)
+}
+
 // mockEngine is a mock implementation of the Engine interface for testing.
 type mockEngine[T any] struct {
 	cache map[string]T
@@ -9,16 +9,30 @@ import (
 // Compile time check to ensure LRUEngine satisfies the Engine interface.
 var _ Engine[any] = (*LRUEngine[any])(nil)
 
+// Compile time check to ensure LRUEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*LRUEngine[any])(nil)
+
+// Compile time check to ensure LRUEngine satisfies the Deleter interface.
+var _ Deleter = (*LRUEngine[any])(nil)
+
+// Compile time check to ensure LRUEngine satisfies the Namespacer interface.
+var _ Namespacer = (*LRUEngine[any])(nil)
+
 // LRUEngineOptions contains options for configuring the LRUEngine.
 type LRUEngineOptions struct {
 	// MaxCacheSize is the maximum number of entries to be stored in the cache.
 	MaxCacheSize int
+	// Namespaces contains the quotas of the namespaces, which share the capacity of the cache.
+	Namespaces NamespaceOptions
 }
 
 // LRUEngine is a cache engine implementation based on LRU (Least Recently Used) strategy.
+// The entries are partitioned by the namespace of the context, see WithNamespace.
 type LRUEngine[T comparable] struct {
-	// cache is the underlying LRU cache.
+	// cache is the underlying LRU cache, keyed by namespace and prompt.
 	cache *lru.Cache[string, T]
+	// namespaces tracks the entries and statistics of the namespaces.
+	namespaces *namespaceTracker
 }
 
 // NewLRUEngine creates a new LRUEngine instance with the provided options.
@@ -32,32 +46,121 @@ func NewLRUEngine[T comparable](optFns ...func(o *LRUEngineOptions)) (*LRUEngine
 		fn(&opts)
 	}
 
-	cache, err := lru.New[string, T](opts.MaxCacheSize)
+	e := &LRUEngine[T]{
+		namespaces: newNamespaceTracker(opts.Namespaces),
+	}
+
+	cache, err := lru.NewWithEvict[string, T](opts.MaxCacheSize, func(key string, _ T) {
+		e.namespaces.remove(key)
+	})
 	if err != nil {
 		return nil, err
 	}
 
-	return &LRUEngine[T]{
-		cache: cache,
-	}, nil
+	e.cache = cache
+
+	return e, nil
 }
 
 // Lookup retrieves the cached result associated with the given prompt.
 // It returns the result and a boolean indicating whether the result was found.
 func (e *LRUEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
-	return e.cache.Get(prompt)
+	match, ok := e.LookupMatch(ctx, prompt)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the cached entry of the given prompt. All matches are exact.
+func (e *LRUEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	namespace := NamespaceFromContext(ctx)
+
+	result, ok := e.cache.Get(namespaceKey(namespace, prompt))
+	e.namespaces.lookup(namespace, ok)
+
+	if !ok {
+		return Match[T]{}, false
+	}
+
+	return Match[T]{Result: result, Prompt: prompt, Exact: true}, true
 }
 
 // Update updates the cache with the provided prompt and result.
 // It returns an error if the update operation fails.
 func (e *LRUEngine[T]) Update(ctx context.Context, prompt string, result T) error {
-	e.cache.Add(prompt, result)
-	return nil
+	_, err := e.put(ctx, prompt, nil, result)
+	return err
 }
 
 // Clear clears the cache, removing all entries.
 // It returns an error if the clear operation fails.
 func (e *LRUEngine[T]) Clear(ctx context.Context) error {
 	e.cache.Purge()
+	e.namespaces.reset()
+
+	return nil
+}
+
+// Delete removes the entry of the given prompt.
+func (e *LRUEngine[T]) Delete(ctx context.Context, prompt string) error {
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+	namespace, _ := splitNamespaceKey(key)
+
+	e.cache.Remove(key)
+	e.namespaces.delete(namespace)
+
 	return nil
 }
+
+// ClearNamespace removes all entries of the namespace.
+func (e *LRUEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	clearNamespace(namespace, e.cache.Keys(), func(key string) { e.cache.Remove(key) })
+	return nil
+}
+
+// NamespaceStats returns the usage statistics of the namespace.
+func (e *LRUEngine[T]) NamespaceStats(namespace string) Stats {
+	return e.namespaces.stats(namespace)
+}
+
+// Namespaces returns the names of the namespaces with entries or statistics.
+func (e *LRUEngine[T]) Namespaces() []string {
+	return e.namespaces.names()
+}
+
+// Len returns the number of cached entries.
+func (e *LRUEngine[T]) Len() int {
+	return e.cache.Len()
+}
+
+// embedding returns nil, as the entries have no embeddings.
+func (e *LRUEngine[T]) embedding(context.Context, string) ([]float32, error) {
+	return nil, nil
+}
+
+// put updates the cache with the provided prompt and result in the namespace of the context.
+// The embedding is ignored.
+func (e *LRUEngine[T]) put(ctx context.Context, prompt string, _ []float32, result T) ([]float32, error) {
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+	namespace, _ := splitNamespaceKey(key)
+
+	e.namespaces.add(key, result)
+	e.cache.Add(key, result)
+	e.namespaces.update(namespace)
+	e.namespaces.enforce(key, e.cache.Keys, func(key string) { e.cache.Remove(key) })
+
+	return nil, nil
+}
+
+// entries returns the cached entries from the least to the most recently used.
+// The prompts of the entries are qualified with their namespace.
+func (e *LRUEngine[T]) entries() []memoryEntry[T] {
+	keys := e.cache.Keys()
+	entries := make([]memoryEntry[T], 0, len(keys))
+
+	for _, prompt := range keys {
+		if result, ok := e.cache.Peek(prompt); ok {
+			entries = append(entries, memoryEntry[T]{prompt: prompt, result: result})
+		}
+	}
+
+	return entries
+}
@@ -2,14 +2,38 @@ package llmcache
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hupe1980/go-llmcache/internal/math32"
 )
 
 // Compile time check to ensure LRUSimilarityEngine satisfies the Engine interface.
 var _ Engine[any] = (*LRUSimilarityEngine[any])(nil)
 
+// Compile time check to ensure LRUSimilarityEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*LRUSimilarityEngine[any])(nil)
+
+// Compile time check to ensure LRUSimilarityEngine satisfies the Deleter interface.
+var _ Deleter = (*LRUSimilarityEngine[any])(nil)
+
+// Compile time check to ensure LRUSimilarityEngine satisfies the Namespacer interface.
+var _ Namespacer = (*LRUSimilarityEngine[any])(nil)
+
+// ErrInvalidEmbedding is returned when an embedding contains NaN or Inf values,
+// or cannot be normalized because it is empty or a zero vector.
+var ErrInvalidEmbedding = errors.New("invalid embedding")
+
+// ErrNotCached is returned when a prompt is not cached.
+var ErrNotCached = errors.New("prompt is not cached")
+
 // DistanceFunc represents a function for calculating the distance between two vectors
 type DistanceFunc func(v1, v2 []float32) (float32, error)
 
@@ -24,134 +48,938 @@ type LRUSimilarityEngineOptions struct {
 	// ReturnFirst is a boolean flag indicating whether to return the first match found during lookup.
 	// If set to true, the engine will return the first match found within the threshold distance.
 	ReturnFirst bool
+	// Quantization is the format in which the embeddings are stored. It cannot be combined with the
+	// Hamming distance.
+	Quantization Quantization
+	// RescoreStore keeps the full precision embeddings of quantized entries.
+	// If set, the closest candidates are rescored with the full precision embeddings.
+	RescoreStore VectorStore
+	// RescoreCandidates is the number of candidates rescored with the full precision embeddings.
+	RescoreCandidates int
+	// Normalize is a boolean flag indicating whether embeddings are L2-normalized before they are stored
+	// or compared. For normalized embeddings the cosine distance reduces to a single dot product.
+	// It cannot be combined with the Hamming distance.
+	Normalize bool
+	// IVF enables an inverted file index, so that a lookup only compares the entries
+	// of the clusters closest to the query. If nil, all entries are compared.
+	IVF *IVFOptions
+	// LSH enables a locality-sensitive hashing pre-filter, so that a lookup only compares
+	// the entries sharing a bucket with the query. If nil, all entries are compared.
+	// It cannot be combined with IVF.
+	LSH *LSHOptions
+	// Lexical enables a lexical guard, which combines the embedding distance with the lexical
+	// similarity of the prompts and rejects matches with differing numbers, dates or names.
+	// If nil, only the embedding distance is used.
+	Lexical *LexicalOptions
+	// Rerank enables the re-ranking of candidates whose distance falls in a grey zone,
+	// e.g. with a cross-encoder or an LLM judge. If nil, the threshold decides.
+	Rerank *RerankOptions
+	// AdaptiveThreshold enables thresholds per region of the embedding space, which are
+	// learned from the feedback reported with ReportBadHit and ReportGoodHit.
+	// If nil, the threshold is used for all regions.
+	AdaptiveThreshold *AdaptiveThresholdOptions
+}
+
+// defaultLRUSimilarityEngineOptions returns the default options of the LRUSimilarityEngine.
+func defaultLRUSimilarityEngineOptions() LRUSimilarityEngineOptions {
+	return LRUSimilarityEngineOptions{
+		LRUEngineOptions: LRUEngineOptions{
+			MaxCacheSize: 1000,
+		},
+		DistanceFunc:      CosineDistance,
+		Threshold:         float32(0.2),
+		ReturnFirst:       false,
+		Quantization:      QuantizationNone,
+		RescoreCandidates: 10,
+	}
 }
 
 // LRUSimilarityEngine is a cache engine implementation based on LRU (Least Recently Used) strategy
-// with cosine similarity matching capability.
+// with cosine similarity matching capability. The entries are partitioned by the namespace of the
+// context, see WithNamespace; a lookup only matches entries of its namespace.
 type LRUSimilarityEngine[T comparable] struct {
 	// embedder is the embedding functionality used for similarity calculations.
 	embedder Embedder
-	// cache is the underlying LRU cache for storing prompt embeddings and results.
+	// cache is the underlying LRU cache for storing prompt embeddings and results, keyed by namespace and prompt.
 	cache *lru.Cache[string, *CacheEntry[T]]
+	// namespaces tracks the entries and statistics of the namespaces.
+	namespaces *namespaceTracker
 	// opts contains options for configuring the LRUSimilarityEngine
 	opts LRUSimilarityEngineOptions
+	// metric is the kind of the configured distance function.
+	metric metric
+	// dim is the dimension of the stored embeddings, which is set by the first insert.
+	dim atomic.Int64
+	// index narrows down the entries compared during lookup, or is nil if all entries are compared.
+	index index
+	// matrix holds the embeddings scored by the brute-force scan, or is nil if an index narrows down
+	// the entries, the embeddings are quantized or the distance function has no batch kernel.
+	matrix *embeddingMatrix
+	// lexical is the lexical guard, or nil if only the embedding distance is used.
+	lexical *lexicalMatcher
+	// reranking contains the re-ranking options with defaults applied, or nil if re-ranking is disabled.
+	reranking *RerankOptions
+	// thresholds holds the adaptive thresholds, or nil if the threshold is used for all regions.
+	thresholds *adaptiveThresholds
 }
 
 // NewLRUSimilarityEngine creates a new LRUSimilarityEngine instance with the provided embedder and options.
 // It returns an error if the cache creation fails.
 func NewLRUSimilarityEngine[T comparable](embedder Embedder, optFns ...func(o *LRUSimilarityEngineOptions)) (*LRUSimilarityEngine[T], error) {
-	opts := LRUSimilarityEngineOptions{
-		LRUEngineOptions: LRUEngineOptions{
-			MaxCacheSize: 1000,
-		},
-		DistanceFunc: CosineDistance,
-		Threshold:    float32(0.2),
-		ReturnFirst:  false,
-	}
+	opts := defaultLRUSimilarityEngineOptions()
 
 	for _, fn := range optFns {
 		fn(&opts)
 	}
 
-	cache, err := lru.New[string, *CacheEntry[T]](opts.MaxCacheSize)
-	if err != nil {
-		return nil, err
+	e := &LRUSimilarityEngine[T]{
+		embedder:   embedder,
+		opts:       opts,
+		metric:     metricOf(opts.DistanceFunc),
+		namespaces: newNamespaceTracker(opts.Namespaces),
 	}
 
-	return &LRUSimilarityEngine[T]{
-		embedder: embedder,
-		cache:    cache,
-		opts:     opts,
-	}, nil
-}
+	// Packed bit vectors, see PackBits, must be stored as they are
+	if e.metric == metricHamming {
+		switch {
+		case opts.Quantization != QuantizationNone:
+			return nil, errors.New("quantization cannot be combined with the Hamming distance")
+		case opts.Normalize:
+			return nil, errors.New("normalization cannot be combined with the Hamming distance")
+		}
+	}
 
-// Lookup retrieves the most similar cached result associated with the given text.
-// It returns the result and a boolean indicating whether a match was found.
-func (e *LRUSimilarityEngine[T]) Lookup(ctx context.Context, text string) (T, bool) {
-	if entry, ok := e.cache.Get(text); ok {
-		return entry.Result, true
+	switch {
+	case opts.IVF != nil && opts.LSH != nil:
+		return nil, errors.New("only one index can be configured")
+	case opts.IVF != nil:
+		e.index = newIVFIndex(*opts.IVF, e.vector)
+	case opts.LSH != nil:
+		e.index = newLSHIndex(*opts.LSH)
 	}
 
-	embedding, err := e.embedder.EmbedText(ctx, text)
-	if err != nil {
-		return *new(T), false
+	if _, ok := batchKernel(e.metric); ok && e.index == nil && opts.Quantization == QuantizationNone {
+		e.matrix = newEmbeddingMatrix()
 	}
 
-	var (
-		result T
-	)
+	if opts.Lexical != nil {
+		e.lexical = newLexicalMatcher(*opts.Lexical)
+	}
 
-	found := false
-	minDistance := float32(math.MaxFloat32)
+	if opts.Rerank != nil {
+		if opts.Rerank.Reranker == nil {
+			return nil, errors.New("reranker is required for re-ranking")
+		}
 
-	for _, entry := range e.cache.Values() {
-		if entry.Result == *new(T) {
-			continue
+		reranking := *opts.Rerank
+
+		if reranking.MaxDistance == 0 {
+			reranking.MaxDistance = opts.Threshold
 		}
 
-		otherEmbedding := entry.Embedding
+		if reranking.TopK <= 0 {
+			reranking.TopK = 5
+		}
 
-		distance, err := e.opts.DistanceFunc(embedding, otherEmbedding)
+		e.reranking = &reranking
+	}
+
+	if opts.AdaptiveThreshold != nil {
+		thresholds, err := newAdaptiveThresholds(context.Background(), *opts.AdaptiveThreshold, opts.Threshold)
 		if err != nil {
-			return *new(T), false
+			return nil, err
 		}
 
-		if distance < e.opts.Threshold && distance < minDistance {
-			minDistance = distance
-			result = entry.Result
-			found = true
+		e.thresholds = thresholds
+
+		// The lists are numbered anew by every training, so the thresholds learned for the previous
+		// lists are moved to the lists with the closest centroids. The centroids are not persisted,
+		// so the thresholds of the lists before a restart are discarded by the first training.
+		if idx, ok := e.index.(*ivfIndex); ok && opts.AdaptiveThreshold.Region == nil {
+			idx.onTrain = func(lists []int) {
+				_ = thresholds.remap(context.Background(), ivfRegionPrefix, func(region string) (string, bool) {
+					list, err := strconv.Atoi(strings.TrimPrefix(region, ivfRegionPrefix))
+					if err != nil || list < 0 || list >= len(lists) {
+						return "", false
+					}
 
-			if e.opts.ReturnFirst {
-				return result, true
+					return fmt.Sprintf("%s%d", ivfRegionPrefix, lists[list]), true
+				})
 			}
 		}
 	}
 
-	if found {
-		return result, true
+	cache, err := lru.NewWithEvict[string, *CacheEntry[T]](opts.MaxCacheSize, e.onEvict)
+	if err != nil {
+		return nil, err
+	}
+
+	e.cache = cache
+
+	return e, nil
+}
+
+// Lookup retrieves the most similar cached result associated with the given text.
+// It returns the result and a boolean indicating whether a match was found.
+func (e *LRUSimilarityEngine[T]) Lookup(ctx context.Context, text string) (T, bool) {
+	match, ok := e.LookupMatch(ctx, text)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the most similar cached entry associated with the given text.
+// It returns the match and a boolean indicating whether a match was found.
+func (e *LRUSimilarityEngine[T]) LookupMatch(ctx context.Context, text string) (Match[T], bool) {
+	namespace := NamespaceFromContext(ctx)
+
+	match, ok := e.lookupMatch(ctx, namespace, text)
+	e.namespaces.lookup(namespace, ok)
+
+	return match, ok
+}
+
+// lookupMatch retrieves the most similar cached entry of the namespace associated with the given text.
+func (e *LRUSimilarityEngine[T]) lookupMatch(ctx context.Context, namespace, text string) (Match[T], bool) {
+	key := namespaceKey(namespace, text)
+
+	// Entries without a result only hold the embedding of a previous lookup
+	entry, cached := e.cache.Get(key)
+	if cached && entry.Result != *new(T) {
+		return Match[T]{Result: entry.Result, Prompt: text, Exact: true}, true
+	}
+
+	embedding, err := e.embed(ctx, text)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	query := newQueryEmbedding(embedding, e.opts.Quantization)
+
+	match, err := e.search(ctx, namespace, text, query)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	if match != nil {
+		_, prompt := splitNamespaceKey(match.prompt)
+		return Match[T]{Result: match.entry.Result, Prompt: prompt, Distance: match.distance}, true
+	}
+
+	if cached {
+		return Match[T]{}, false
 	}
 
 	// Store the embedding in the cache
-	e.cache.Add(text, &CacheEntry[T]{
-		Embedding: embedding,
-		Result:    *new(T),
-	})
+	entry, err = e.newEntry(ctx, key, query, *new(T))
+	if err != nil {
+		return Match[T]{}, false
+	}
 
-	return *new(T), false
+	e.add(key, entry)
+
+	return Match[T]{}, false
 }
 
 // Update updates the cache with the provided prompt and result.
 // It retrieves the embedding if available, or embeds the prompt if it is a new entry.
 func (e *LRUSimilarityEngine[T]) Update(ctx context.Context, prompt string, result T) error {
-	if entry, ok := e.cache.Get(prompt); ok {
-		if entry.Result == result {
-			return nil // nothing to do
+	_, err := e.put(ctx, prompt, nil, result)
+	return err
+}
+
+// embedding returns the (dequantized) embedding of the cached entry of the prompt in the namespace of the context,
+// or embeds the prompt if it is not cached.
+func (e *LRUSimilarityEngine[T]) embedding(ctx context.Context, prompt string) ([]float32, error) {
+	if entry, ok := e.cache.Peek(namespaceKey(NamespaceFromContext(ctx), prompt)); ok {
+		if entry.Embedding != nil {
+			return entry.Embedding, nil
 		}
 
-		e.cache.Remove(prompt)
+		return entry.embedding.vector(nil), nil
+	}
+
+	return e.embed(ctx, prompt)
+}
+
+// put updates the cache with the provided prompt and result in the namespace of the context. A new entry
+// uses the given embedding, or embeds the prompt if it is nil. It returns the (dequantized) embedding of the entry.
+func (e *LRUSimilarityEngine[T]) put(ctx context.Context, prompt string, embedding []float32, result T) ([]float32, error) {
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+
+	namespace, prompt := splitNamespaceKey(key)
+	e.namespaces.update(namespace)
+
+	if entry, ok := e.cache.Get(key); ok {
+		vector := entry.Embedding
+		if vector == nil {
+			vector = entry.embedding.vector(nil)
+		}
+
+		if entry.Result == result {
+			return vector, nil // nothing to do
+		}
 
-		e.cache.Add(prompt, &CacheEntry[T]{
+		e.add(key, &CacheEntry[T]{
 			Embedding: entry.Embedding,
 			Result:    result,
+			norm:      entry.norm,
+			embedding: entry.embedding,
+			namespace: entry.namespace,
 		})
+
+		return vector, nil
+	}
+
+	var err error
+
+	if embedding == nil {
+		embedding, err = e.embed(ctx, prompt)
 	} else {
-		embedding, err := e.embedder.EmbedText(ctx, prompt)
-		if err != nil {
-			return err
-		}
+		embedding, err = e.prepare(embedding)
+	}
 
-		e.cache.Add(prompt, &CacheEntry[T]{
-			Embedding: embedding,
-			Result:    result,
-		})
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	entry, err := e.newEntry(ctx, key, newQueryEmbedding(embedding, QuantizationNone), result)
+	if err != nil {
+		return nil, err
+	}
+
+	e.add(key, entry)
+
+	return embedding, nil
+}
+
+// add adds the entry to the cache and evicts the least recently used entries of its namespace
+// if the namespace exceeds its quota. Only entries with a result count for the lexical statistics,
+// so that they do not drift with the lookups.
+func (e *LRUSimilarityEngine[T]) add(key string, entry *CacheEntry[T]) {
+	if e.lexical != nil {
+		if entry.Result == *new(T) {
+			e.lexical.remove(key)
+		} else {
+			e.lexical.add(key)
+		}
+	}
+
+	e.namespaces.add(key, entry.Result)
+	e.cache.Add(key, entry)
+	e.namespaces.enforce(key, e.cache.Keys, func(key string) { e.cache.Remove(key) })
 }
 
 // Clear clears the cache, removing all entries.
 // It returns an error if the clear operation fails.
 func (e *LRUSimilarityEngine[T]) Clear(ctx context.Context) error {
 	e.cache.Purge()
+	e.namespaces.reset()
+	e.dim.Store(0)
+
+	if e.index != nil {
+		e.index.reset()
+	}
+
+	if e.matrix != nil {
+		e.matrix.reset()
+	}
+
+	if e.lexical != nil {
+		e.lexical.reset()
+	}
+
+	return nil
+}
+
+// Delete removes the entry of the given prompt from the cache and the index.
+func (e *LRUSimilarityEngine[T]) Delete(ctx context.Context, prompt string) error {
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+	namespace, _ := splitNamespaceKey(key)
+
+	e.cache.Remove(key)
+	e.namespaces.delete(namespace)
+
+	return nil
+}
+
+// ClearNamespace removes all entries of the namespace from the cache and the index.
+func (e *LRUSimilarityEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	clearNamespace(namespace, e.cache.Keys(), func(key string) { e.cache.Remove(key) })
 	return nil
 }
+
+// NamespaceStats returns the usage statistics of the namespace.
+func (e *LRUSimilarityEngine[T]) NamespaceStats(namespace string) Stats {
+	return e.namespaces.stats(namespace)
+}
+
+// Namespaces returns the names of the namespaces with entries or statistics.
+func (e *LRUSimilarityEngine[T]) Namespaces() []string {
+	return e.namespaces.names()
+}
+
+// Len returns the number of cached entries, including the embeddings of prompts which were only looked up.
+func (e *LRUSimilarityEngine[T]) Len() int {
+	return e.cache.Len()
+}
+
+// embed embeds the text and prepares the embedding for storage and comparison.
+func (e *LRUSimilarityEngine[T]) embed(ctx context.Context, text string) ([]float32, error) {
+	embedding, err := e.embedder.EmbedText(ctx, text)
+	if err != nil {
+		return nil, err
+	}
+
+	return e.prepare(embedding)
+}
+
+// prepare validates the embedding. The dimension of the first embedding is stored,
+// all later embeddings must have the same dimension.
+// If normalization is enabled, a normalized copy of the embedding is returned.
+func (e *LRUSimilarityEngine[T]) prepare(embedding []float32) ([]float32, error) {
+	if len(embedding) == 0 {
+		return nil, fmt.Errorf("%w: empty embedding", ErrInvalidEmbedding)
+	}
+
+	// Packed bit vectors may contain any bit pattern
+	if e.metric != metricHamming {
+		for _, v := range embedding {
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				return nil, fmt.Errorf("%w: embedding contains NaN or Inf values", ErrInvalidEmbedding)
+			}
+		}
+	}
+
+	if !e.dim.CompareAndSwap(0, int64(len(embedding))) {
+		if dim := e.dim.Load(); dim != int64(len(embedding)) {
+			return nil, fmt.Errorf("%w: expected embedding dimension %d, got %d", ErrVectorSizeMismatch, dim, len(embedding))
+		}
+	}
+
+	if !e.opts.Normalize {
+		return embedding, nil
+	}
+
+	norm := Magnitude(embedding)
+	if norm == 0 {
+		return nil, fmt.Errorf("%w: cannot normalize a zero vector", ErrInvalidEmbedding)
+	}
+
+	normalized := make([]float32, len(embedding))
+	for i, v := range embedding {
+		normalized[i] = v / norm
+	}
+
+	return normalized, nil
+}
+
+// candidate is a cache entry considered as a match during lookup.
+type candidate[T comparable] struct {
+	// prompt is the cache key of the entry, the prompt qualified with its namespace.
+	prompt string
+	// entry is the cache entry.
+	entry *CacheEntry[T]
+	// distance is the distance between the query and the entry.
+	distance float32
+}
+
+// search returns the closest entry of the namespace within the threshold distance, or nil if there is none.
+func (e *LRUSimilarityEngine[T]) search(ctx context.Context, namespace, text string, query *queryEmbedding) (*candidate[T], error) {
+	var lq *lexicalQuery
+	if e.lexical != nil {
+		lq = e.lexical.query(text)
+	}
+
+	if e.rescoring() {
+		return e.searchRescored(ctx, namespace, text, lq, query)
+	}
+
+	if e.matrix != nil {
+		return e.scan(ctx, namespace, text, lq, query)
+	}
+
+	var s selection[T]
+
+	for _, prompt := range e.prompts(namespace, query) {
+		entry, ok := e.cache.Peek(prompt)
+		if !ok || entry.Result == *new(T) || entry.namespace != namespace {
+			continue
+		}
+
+		distance, err := e.distance(query, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		if e.consider(&s, lq, candidate[T]{prompt: prompt, entry: entry, distance: distance}) {
+			break
+		}
+	}
+
+	return e.selectMatch(ctx, text, &s)
+}
+
+// scan scores all entries at once with the batch kernel of the metric and returns the closest entry
+// of the namespace within the threshold distance, or nil if there is none.
+func (e *LRUSimilarityEngine[T]) scan(ctx context.Context, namespace, text string, lq *lexicalQuery, query *queryEmbedding) (*candidate[T], error) {
+	kernel, _ := batchKernel(e.metric)
+
+	prompts, scores, err := e.matrix.scan(query.vector, kernel)
+	if err != nil {
+		return nil, err
+	}
+
+	var s selection[T]
+
+	for i, prompt := range prompts {
+		entry, ok := e.cache.Peek(prompt)
+		if !ok || entry.Result == *new(T) || entry.namespace != namespace {
+			continue
+		}
+
+		if e.consider(&s, lq, candidate[T]{prompt: prompt, entry: entry, distance: e.batchDistance(query, entry, scores[i])}) {
+			break
+		}
+	}
+
+	return e.selectMatch(ctx, text, &s)
+}
+
+// searchRescored ranks all entries by their quantized distance and rescores
+// the closest candidates with their full precision embeddings.
+func (e *LRUSimilarityEngine[T]) searchRescored(ctx context.Context, namespace, text string, lq *lexicalQuery, query *queryEmbedding) (*candidate[T], error) {
+	candidates := make([]candidate[T], 0, e.cache.Len())
+
+	for _, prompt := range e.prompts(namespace, query) {
+		entry, ok := e.cache.Peek(prompt)
+		if !ok || entry.Result == *new(T) || entry.namespace != namespace {
+			continue
+		}
+
+		distance, err := e.distance(query, entry)
+		if err != nil {
+			return nil, err
+		}
+
+		candidates = append(candidates, candidate[T]{prompt: prompt, entry: entry, distance: distance})
+	}
+
+	sortCandidates(candidates)
+
+	if len(candidates) > e.opts.RescoreCandidates {
+		candidates = candidates[:e.opts.RescoreCandidates]
+	}
+
+	var s selection[T]
+
+	for _, c := range candidates {
+		embedding, err := e.opts.RescoreStore.Get(ctx, c.prompt)
+		if err != nil {
+			return nil, err
+		}
+
+		// Keep the quantized distance if the full precision embedding is not available
+		if embedding != nil {
+			c.distance, err = e.opts.DistanceFunc(query.vector, embedding)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if e.consider(&s, lq, c) {
+			break
+		}
+	}
+
+	return e.selectMatch(ctx, text, &s)
+}
+
+// selection collects the matches of a lookup.
+type selection[T comparable] struct {
+	// match is the closest candidate accepted without re-ranking.
+	match *candidate[T]
+	// grey holds the candidates within the grey zone of the re-ranking.
+	grey []candidate[T]
+}
+
+// consider adds the candidate to the selection if it is a match or within the grey zone.
+// It returns true if the search can stop, because the first match is returned.
+func (e *LRUSimilarityEngine[T]) consider(s *selection[T], lq *lexicalQuery, c candidate[T]) bool {
+	distance, ok := e.accept(lq, c.prompt, c.distance)
+	if !ok {
+		return false
+	}
+
+	c.distance = distance
+
+	if e.reranking != nil && distance >= e.reranking.MinDistance {
+		s.grey = append(s.grey, c)
+		return false
+	}
+
+	if s.match == nil || distance < s.match.distance {
+		// Copy the candidate, so that only matches escape to the heap
+		match := c
+		s.match = &match
+
+		return e.opts.ReturnFirst
+	}
+
+	return false
+}
+
+// selectMatch returns the match of the selection. If no candidate was accepted
+// without re-ranking, the closest candidates of the grey zone are re-ranked.
+func (e *LRUSimilarityEngine[T]) selectMatch(ctx context.Context, text string, s *selection[T]) (*candidate[T], error) {
+	if s.match != nil || len(s.grey) == 0 {
+		return s.match, nil
+	}
+
+	sortCandidates(s.grey)
+
+	grey := s.grey[:min(len(s.grey), e.reranking.TopK)]
+
+	accepted, err := rerank(ctx, e.reranking, text, grey)
+	if err != nil {
+		// Fall back to the threshold
+		if e.reranking.RejectOnFailure || grey[0].distance >= e.threshold(grey[0].prompt) {
+			return nil, nil
+		}
+
+		return &grey[0], nil
+	}
+
+	if len(accepted) == 0 {
+		return nil, nil
+	}
+
+	return &accepted[0], nil
+}
+
+// accept reports whether a candidate with the given distance is a match or within the grey zone of the
+// re-ranking. If the lexical guard is enabled, the distance of the candidate is combined with its lexical distance.
+func (e *LRUSimilarityEngine[T]) accept(lq *lexicalQuery, prompt string, distance float32) (float32, bool) {
+	limit := e.opts.Threshold
+
+	switch {
+	case e.reranking != nil:
+		limit = e.reranking.MaxDistance
+	case e.thresholds != nil:
+		// Skip the region lookup for candidates beyond all thresholds
+		if distance >= e.thresholds.upper() {
+			return distance, false
+		}
+
+		limit = e.threshold(prompt)
+	}
+
+	if distance >= limit {
+		return distance, false
+	}
+
+	if e.lexical == nil {
+		return distance, true
+	}
+
+	_, text := splitNamespaceKey(prompt)
+	distance, ok := e.lexical.score(lq, text, distance)
+
+	return distance, ok && distance < limit
+}
+
+// threshold returns the threshold of the region of the cached prompt.
+func (e *LRUSimilarityEngine[T]) threshold(prompt string) float32 {
+	if e.thresholds == nil {
+		return e.opts.Threshold
+	}
+
+	return e.thresholds.get(e.region(prompt))
+}
+
+// ivfRegionPrefix is the prefix of the regions of the IVF lists.
+const ivfRegionPrefix = "ivf-"
+
+// region returns the region of the cached prompt for the adaptive thresholds.
+func (e *LRUSimilarityEngine[T]) region(prompt string) string {
+	if e.opts.AdaptiveThreshold.Region != nil {
+		_, text := splitNamespaceKey(prompt)
+		return e.opts.AdaptiveThreshold.Region(text)
+	}
+
+	if idx, ok := e.index.(*ivfIndex); ok {
+		if list, ok := idx.list(prompt); ok {
+			return fmt.Sprintf("%s%d", ivfRegionPrefix, list)
+		}
+	}
+
+	return ""
+}
+
+// ReportBadHit reports that the cached prompt matchedPrompt was a wrong match for the prompt.
+// With adaptive thresholds, the threshold of the region of matchedPrompt is lowered below their distance.
+func (e *LRUSimilarityEngine[T]) ReportBadHit(ctx context.Context, prompt, matchedPrompt string) error {
+	return e.report(ctx, prompt, matchedPrompt, false)
+}
+
+// ReportGoodHit reports that the cached prompt matchedPrompt was a correct match for the prompt.
+// With adaptive thresholds, the threshold of the region of matchedPrompt is raised.
+func (e *LRUSimilarityEngine[T]) ReportGoodHit(ctx context.Context, prompt, matchedPrompt string) error {
+	return e.report(ctx, prompt, matchedPrompt, true)
+}
+
+// Thresholds returns the learned thresholds of the regions, or nil if adaptive thresholds are disabled.
+func (e *LRUSimilarityEngine[T]) Thresholds() map[string]float32 {
+	if e.thresholds == nil {
+		return nil
+	}
+
+	return e.thresholds.snapshot()
+}
+
+// report adjusts the adaptive threshold of the region of matchedPrompt to the feedback.
+func (e *LRUSimilarityEngine[T]) report(ctx context.Context, prompt, matchedPrompt string, good bool) error {
+	if e.thresholds == nil {
+		return errors.New("adaptive thresholds are not enabled")
+	}
+
+	key := namespaceKey(NamespaceFromContext(ctx), matchedPrompt)
+
+	entry, ok := e.cache.Peek(key)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNotCached, matchedPrompt)
+	}
+
+	embedding, err := e.embed(ctx, prompt)
+	if err != nil {
+		return err
+	}
+
+	distance, err := e.distance(newQueryEmbedding(embedding, e.opts.Quantization), entry)
+	if err != nil {
+		return err
+	}
+
+	// Use the same distance as the lookup
+	if e.lexical != nil {
+		if d, ok := e.lexical.score(e.lexical.query(prompt), matchedPrompt, distance); ok {
+			distance = d
+		}
+	}
+
+	return e.thresholds.report(ctx, e.region(key), distance, good)
+}
+
+// sortCandidates sorts the candidates by ascending distance.
+func sortCandidates[T comparable](candidates []candidate[T]) {
+	slices.SortFunc(candidates, func(a, b candidate[T]) int {
+		switch {
+		case a.distance < b.distance:
+			return -1
+		case a.distance > b.distance:
+			return 1
+		default:
+			return 0
+		}
+	})
+}
+
+// prompts returns the prompts of the entries compared with the query. All entries are compared if
+// the index cannot narrow them down to candidates of the namespace holding a result, e.g. because
+// the buckets of the query only hold other namespaces or the embeddings of lookups.
+func (e *LRUSimilarityEngine[T]) prompts(namespace string, query *queryEmbedding) []string {
+	if e.index != nil {
+		if prompts, ok := e.index.search(query.vector); ok {
+			prompts = slices.DeleteFunc(prompts, func(prompt string) bool {
+				entry, ok := e.cache.Peek(prompt)
+				return !ok || entry.Result == *new(T) || entry.namespace != namespace
+			})
+
+			if len(prompts) > 0 {
+				return prompts
+			}
+		}
+	}
+
+	return e.cache.Keys()
+}
+
+// vector returns the (dequantized) embedding of a cached prompt.
+func (e *LRUSimilarityEngine[T]) vector(prompt string) ([]float32, bool) {
+	entry, ok := e.cache.Peek(prompt)
+	if !ok {
+		return nil, false
+	}
+
+	return entry.embedding.vector(nil), true
+}
+
+// entries returns the cached entries with a result from the least to the most recently used.
+func (e *LRUSimilarityEngine[T]) entries() []memoryEntry[T] {
+	keys := e.cache.Keys()
+	entries := make([]memoryEntry[T], 0, len(keys))
+
+	for _, prompt := range keys {
+		entry, ok := e.cache.Peek(prompt)
+		if !ok || entry.Result == *new(T) {
+			continue
+		}
+
+		vector := entry.Embedding
+		if vector == nil {
+			vector = entry.embedding.vector(nil)
+		}
+
+		entries = append(entries, memoryEntry[T]{prompt: prompt, embedding: vector, result: entry.Result})
+	}
+
+	return entries
+}
+
+// rescoring reports whether quantized candidates are rescored with full precision embeddings.
+func (e *LRUSimilarityEngine[T]) rescoring() bool {
+	return e.opts.Quantization != QuantizationNone && e.opts.RescoreStore != nil && e.opts.RescoreCandidates > 0
+}
+
+// newEntry creates a cache entry storing the embedding in the configured format.
+// The full precision embedding is written to the rescore store if rescoring is enabled.
+func (e *LRUSimilarityEngine[T]) newEntry(ctx context.Context, prompt string, query *queryEmbedding, result T) (*CacheEntry[T], error) {
+	if e.rescoring() {
+		if err := e.opts.RescoreStore.Set(ctx, prompt, query.vector); err != nil {
+			return nil, err
+		}
+	}
+
+	namespace, _ := splitNamespaceKey(prompt)
+
+	entry := &CacheEntry[T]{
+		Result:    result,
+		norm:      query.norm,
+		embedding: quantize(query.vector, query.norm, e.opts.Quantization),
+		namespace: namespace,
+	}
+
+	if e.opts.Quantization == QuantizationNone {
+		entry.Embedding = query.vector
+	}
+
+	if e.index != nil {
+		e.index.add(prompt, query.vector)
+	}
+
+	if e.matrix != nil {
+		e.matrix.add(prompt, query.vector)
+	}
+
+	return entry, nil
+}
+
+// onEvict removes an evicted entry from the namespace usage, the index, the embedding matrix and
+// the lexical statistics, and its full precision embedding from the rescore store.
+func (e *LRUSimilarityEngine[T]) onEvict(prompt string, _ *CacheEntry[T]) {
+	e.namespaces.remove(prompt)
+
+	if e.index != nil {
+		e.index.remove(prompt)
+	}
+
+	if e.matrix != nil {
+		e.matrix.remove(prompt)
+	}
+
+	if e.lexical != nil {
+		e.lexical.remove(prompt)
+	}
+
+	if e.rescoring() {
+		_ = e.opts.RescoreStore.Delete(context.Background(), prompt)
+	}
+}
+
+// distance calculates the distance between the query embedding and a cache entry.
+// For the cosine distance the cached norms are used, so only a single dot product is required.
+// Quantized entries are compared with the dot product of the quantized embeddings where possible.
+func (e *LRUSimilarityEngine[T]) distance(query *queryEmbedding, entry *CacheEntry[T]) (float32, error) {
+	if len(query.vector) != entry.embedding.dim() {
+		return 0, ErrVectorSizeMismatch
+	}
+
+	if e.metric == metricCosine {
+		// Avoid division by zero
+		if query.norm == 0 || entry.norm == 0 {
+			return 1, nil
+		}
+
+		return 1 - entry.embedding.dot(query)/(query.norm*entry.norm), nil
+	}
+
+	if e.opts.Quantization == QuantizationNone || e.metric == metricOther {
+		return e.opts.DistanceFunc(query.vector, query.dequantize(entry.embedding))
+	}
+
+	switch e.metric {
+	case metricNegativeInnerProduct:
+		return -entry.embedding.dot(query), nil
+	case metricSquaredL2:
+		return entry.embedding.squaredL2(query, entry.norm), nil
+	case metricEuclidean:
+		return math32.Sqrt(entry.embedding.squaredL2(query, entry.norm)), nil
+	default:
+		return e.opts.DistanceFunc(query.vector, query.dequantize(entry.embedding))
+	}
+}
+
+// batchDistance converts the score of an entry computed by the batch kernel into the distance.
+// For the cosine distance the cached norms are used, as in distance.
+func (e *LRUSimilarityEngine[T]) batchDistance(query *queryEmbedding, entry *CacheEntry[T], score float32) float32 {
+	switch e.metric {
+	case metricCosine:
+		// Avoid division by zero
+		if query.norm == 0 || entry.norm == 0 {
+			return 1
+		}
+
+		return 1 - score/(query.norm*entry.norm)
+	case metricNegativeInnerProduct:
+		return -score
+	case metricEuclidean:
+		return math32.Sqrt(score)
+	default:
+		return score
+	}
+}
+
+// metric is the kind of a distance function.
+type metric int
+
+const (
+	metricOther metric = iota
+	metricCosine
+	metricSquaredL2
+	metricEuclidean
+	metricNegativeInnerProduct
+	metricHamming
+)
+
+// metricOf returns the kind of the distance function. Distance functions
+// defined outside of this package are of kind metricOther.
+func metricOf(fn DistanceFunc) metric {
+	if fn == nil {
+		return metricOther
+	}
+
+	ptr := reflect.ValueOf(fn).Pointer()
+
+	switch ptr {
+	case reflect.ValueOf(CosineDistance).Pointer():
+		return metricCosine
+	case reflect.ValueOf(SquaredL2).Pointer():
+		return metricSquaredL2
+	case reflect.ValueOf(Euclidean).Pointer():
+		return metricEuclidean
+	case reflect.ValueOf(NegativeInnerProduct).Pointer():
+		return metricNegativeInnerProduct
+	case reflect.ValueOf(Hamming).Pointer():
+		return metricHamming
+	default:
+		return metricOther
+	}
+}
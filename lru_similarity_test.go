@@ -2,6 +2,9 @@ package llmcache
 
 import (
 	"context"
+	"fmt"
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -56,6 +59,293 @@ func TestLRUSimilarityEngine_LookupAndUpdate(t *testing.T) {
 			assert.Equal(t, "", foundResult)
 		})
 	})
+
+	t.Run("LookupMatch", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder)
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = cache.Update(ctx, "prompt1", "result1")
+		assert.NoError(t, err)
+
+		match, ok := cache.LookupMatch(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, Match[string]{Result: "result1", Prompt: "prompt1", Exact: true}, match)
+
+		match, ok = cache.LookupMatch(ctx, "prompt2")
+		assert.True(t, ok)
+		assert.Equal(t, "result1", match.Result)
+		assert.Equal(t, "prompt1", match.Prompt)
+		assert.False(t, match.Exact)
+		assert.Greater(t, match.Distance, float32(0))
+
+		_, ok = cache.LookupMatch(ctx, "prompt3")
+		assert.False(t, ok)
+
+		// The first lookup only stored the embedding
+		_, ok = cache.LookupMatch(ctx, "prompt3")
+		assert.False(t, ok)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.IVF = &IVFOptions{Lists: 1}
+		})
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = cache.Update(ctx, "prompt1", "result1")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, cache.Len())
+
+		err = cache.Delete(ctx, "prompt1")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, cache.Len())
+
+		// Neither the exact nor the similar prompt matches
+		_, ok := cache.Lookup(ctx, "prompt2")
+		assert.False(t, ok)
+
+		_, ok = cache.Lookup(ctx, "prompt1")
+		assert.False(t, ok)
+	})
+}
+
+func TestLRUSimilarityEngine_Validation(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"prompt1": {0.1, 0.2, 0.3, 0.4},
+			"prompt2": {0.1, 0.2, 0.3},
+			"nan":     {0.1, float32(math.NaN()), 0.3, 0.4},
+			"inf":     {0.1, float32(math.Inf(1)), 0.3, 0.4},
+			"zero":    {0, 0, 0, 0},
+			"empty":   {},
+		},
+	}
+
+	t.Run("Dimension", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder)
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = cache.Update(ctx, "prompt1", "result1")
+		assert.NoError(t, err)
+
+		err = cache.Update(ctx, "prompt2", "result2")
+		assert.ErrorIs(t, err, ErrVectorSizeMismatch)
+		assert.EqualError(t, err, "vector sizes do not match: expected embedding dimension 4, got 3")
+
+		_, ok := cache.Lookup(ctx, "prompt2")
+		assert.False(t, ok)
+		assert.False(t, cache.cache.Contains(namespaceKey("", "prompt2")))
+
+		// Clear resets the dimension
+		err = cache.Clear(ctx)
+		assert.NoError(t, err)
+
+		err = cache.Update(ctx, "prompt2", "result2")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Invalid Values", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder)
+		assert.NoError(t, err)
+
+		for _, prompt := range []string{"nan", "inf", "empty"} {
+			err = cache.Update(context.TODO(), prompt, "result")
+			assert.ErrorIs(t, err, ErrInvalidEmbedding)
+		}
+
+		err = cache.Update(context.TODO(), "zero", "result")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Normalize", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Normalize = true
+		})
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = cache.Update(ctx, "prompt1", "result1")
+		assert.NoError(t, err)
+
+		entry, ok := cache.cache.Peek(namespaceKey("", "prompt1"))
+		assert.True(t, ok)
+		assert.InDelta(t, 1, Magnitude(entry.Embedding), 1e-6)
+		assert.InDelta(t, 1, entry.norm, 1e-6)
+
+		// The embedding of the embedder is not modified
+		assert.Equal(t, []float32{0.1, 0.2, 0.3, 0.4}, mockEmbedder.embeddings["prompt1"])
+
+		err = cache.Update(ctx, "zero", "result")
+		assert.ErrorIs(t, err, ErrInvalidEmbedding)
+	})
+
+	t.Run("Hamming", func(t *testing.T) {
+		for _, q := range []Quantization{QuantizationInt8, QuantizationBinary, QuantizationFloat16, QuantizationBFloat16} {
+			_, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+				o.DistanceFunc = Hamming
+				o.Quantization = q
+			})
+			assert.EqualError(t, err, "quantization cannot be combined with the Hamming distance")
+		}
+
+		_, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.DistanceFunc = Hamming
+			o.Normalize = true
+		})
+		assert.EqualError(t, err, "normalization cannot be combined with the Hamming distance")
+
+		_, err = NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.DistanceFunc = Hamming
+		})
+		assert.NoError(t, err)
+	})
+}
+
+func TestLRUSimilarityEngine_Distance(t *testing.T) {
+	engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{})
+	assert.NoError(t, err)
+	assert.Equal(t, metricCosine, engine.metric)
+
+	tests := []struct {
+		name string
+		a, b []float32
+	}{
+		{"Similar", []float32{0.1, 0.2, 0.3, 0.4}, []float32{0.2, 0.2, 0.3, 0.4}},
+		{"Opposite", []float32{0.1, 0.2, 0.3, 0.4}, []float32{-0.1, -0.2, -0.3, -0.4}},
+		{"Zero", []float32{0, 0, 0, 0}, []float32{0.1, 0.2, 0.3, 0.4}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			expected, err := CosineDistance(tc.a, tc.b)
+			assert.NoError(t, err)
+
+			distance, err := engine.distance(newQueryEmbedding(tc.a, QuantizationNone), &CacheEntry[string]{norm: Magnitude(tc.b), embedding: float32Embedding(tc.b)})
+			assert.NoError(t, err)
+			assert.InDelta(t, expected, distance, 1e-6)
+		})
+	}
+
+	t.Run("Size Mismatch", func(t *testing.T) {
+		_, err := engine.distance(newQueryEmbedding([]float32{1, 2}, QuantizationNone), &CacheEntry[string]{norm: 1, embedding: float32Embedding{1, 2, 3}})
+		assert.Error(t, err)
+	})
+}
+
+func TestLRUSimilarityEngine_Quantization(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"prompt1": {0.1, 0.2, 0.3, 0.4},
+			"prompt2": {0.2, 0.2, 0.3, 0.4},
+			"prompt3": {-0.1, -0.2, -0.3, -0.4},
+		},
+	}
+
+	for _, q := range []Quantization{QuantizationInt8, QuantizationBinary, QuantizationFloat16, QuantizationBFloat16} {
+		t.Run(q.String(), func(t *testing.T) {
+			store := NewMemoryVectorStore()
+
+			cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+				o.MaxCacheSize = 2
+				o.Quantization = q
+				o.RescoreStore = store
+			})
+			assert.NoError(t, err)
+
+			ctx := context.TODO()
+
+			err = cache.Update(ctx, "prompt1", "result1")
+			assert.NoError(t, err)
+
+			entry, ok := cache.cache.Peek(namespaceKey("", "prompt1"))
+			assert.True(t, ok)
+			assert.Nil(t, entry.Embedding)
+
+			embedding, err := store.Get(ctx, namespaceKey("", "prompt1"))
+			assert.NoError(t, err)
+			assert.Equal(t, mockEmbedder.embeddings["prompt1"], embedding)
+
+			foundResult, ok := cache.Lookup(ctx, "prompt2")
+			assert.True(t, ok)
+			assert.Equal(t, "result1", foundResult)
+
+			foundResult, ok = cache.Lookup(ctx, "prompt3")
+			assert.False(t, ok)
+			assert.Equal(t, "", foundResult)
+
+			// prompt1 is evicted by prompt2, the cache already holds the embedding of prompt3
+			err = cache.Update(ctx, "prompt2", "result2")
+			assert.NoError(t, err)
+
+			embedding, err = store.Get(ctx, namespaceKey("", "prompt1"))
+			assert.NoError(t, err)
+			assert.Nil(t, embedding)
+		})
+	}
+}
+
+func BenchmarkLRUSimilarityEngine_Lookup(b *testing.B) {
+	const entries = 1000
+
+	for _, dim := range []int{384, 768, 1536, 3072} {
+		embedder := &mockEmbedder{embeddings: make(map[string][]float32, entries+1)}
+
+		for i := 0; i <= entries; i++ {
+			embedding := make([]float32, dim)
+			for j := range embedding {
+				embedding[j] = rand.Float32()*2 - 1 // nolint gosec
+			}
+
+			embedder.embeddings[fmt.Sprintf("prompt%d", i)] = embedding
+		}
+
+		engine, err := NewLRUSimilarityEngine[string](embedder, func(o *LRUSimilarityEngineOptions) {
+			o.MaxCacheSize = entries + 1
+			o.Threshold = 0
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for i := 0; i < entries; i++ {
+			if err := engine.Update(context.Background(), fmt.Sprintf("prompt%d", i), "result"); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		query := fmt.Sprintf("prompt%d", entries)
+
+		b.Run(fmt.Sprintf("dim=%d/batch", dim), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				engine.cache.Remove(namespaceKey("", query))
+				engine.Lookup(context.Background(), query)
+			}
+		})
+
+		// Without the embedding matrix every entry is compared on its own
+		matrix := engine.matrix
+		engine.matrix = nil
+
+		b.Run(fmt.Sprintf("dim=%d/single", dim), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				engine.cache.Remove(namespaceKey("", query))
+				engine.Lookup(context.Background(), query)
+			}
+		})
+
+		engine.matrix = matrix
+	}
 }
 
 // mockEmbedder is a mock implementation of the Embedder interface for testing.
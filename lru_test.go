@@ -67,4 +67,42 @@ func TestLRUEngine(t *testing.T) {
 		assert.False(t, ok)
 		assert.Equal(t, 0, foundResult)
 	})
+
+	t.Run("LookupMatch", func(t *testing.T) {
+		engine, err := NewLRUEngine[int]()
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = engine.Update(ctx, "Hello, World!", 42)
+		assert.NoError(t, err)
+
+		match, ok := engine.LookupMatch(ctx, "Hello, World!")
+		assert.True(t, ok)
+		assert.Equal(t, Match[int]{Result: 42, Prompt: "Hello, World!", Exact: true}, match)
+
+		_, ok = engine.LookupMatch(ctx, "Goodbye")
+		assert.False(t, ok)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		engine, err := NewLRUEngine[int]()
+		assert.NoError(t, err)
+
+		ctx := context.TODO()
+
+		err = engine.Update(ctx, "Hello, World!", 42)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, engine.Len())
+
+		err = engine.Delete(ctx, "Hello, World!")
+		assert.NoError(t, err)
+		assert.Equal(t, 0, engine.Len())
+
+		_, ok := engine.Lookup(ctx, "Hello, World!")
+		assert.False(t, ok)
+
+		// Deleting a missing prompt is not an error
+		assert.NoError(t, engine.Delete(ctx, "Goodbye"))
+	})
 }
@@ -0,0 +1,211 @@
+package llmcache
+
+import (
+	"math/rand"
+	"sync"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+)
+
+// LSHOptions contains options for configuring the locality-sensitive hashing pre-filter
+// of the LRUSimilarityEngine. Each table hashes an embedding to the signs of its projections
+// onto random hyperplanes (SimHash), so embeddings with a small angle share buckets.
+// A lookup only compares the entries sharing a bucket with the query in any table.
+// More bits make the buckets smaller and the lookup faster, more tables increase the recall.
+type LSHOptions struct {
+	// Tables is the number of hash tables. Default is 16.
+	Tables int
+	// Bits is the number of hyperplanes of each table, at most 64. Default is 8.
+	Bits int
+	// Seed is the seed of the random number generator used to create the hyperplanes.
+	Seed int64
+}
+
+// lshIndex is a random hyperplane locality-sensitive hashing index.
+type lshIndex struct {
+	mu   sync.RWMutex
+	opts LSHOptions
+	// dim is the dimension of the hyperplanes.
+	dim int
+	// planes holds the hyperplanes of each table in a row-major matrix.
+	planes [][]float32
+	// tables maps the hashes of each table to the prompts of the bucket.
+	tables []map[uint64]map[string]struct{}
+	// hashes holds the hashes of each prompt, one per table.
+	hashes map[string][]uint64
+	// projections is a buffer for the projections of an embedding.
+	projections sync.Pool
+}
+
+// newLSHIndex creates a new lshIndex instance.
+func newLSHIndex(opts LSHOptions) *lshIndex {
+	if opts.Tables <= 0 {
+		opts.Tables = 16
+	}
+
+	if opts.Bits <= 0 {
+		opts.Bits = 8
+	}
+
+	opts.Bits = min(opts.Bits, 64)
+
+	idx := &lshIndex{
+		opts: opts,
+	}
+
+	idx.projections.New = func() any {
+		p := make([]float32, opts.Bits)
+		return &p
+	}
+
+	idx.resetLocked()
+
+	return idx
+}
+
+func (idx *lshIndex) add(prompt string, embedding []float32) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(prompt)
+
+	// The hyperplanes are created with the dimension of the first embedding
+	if idx.planes == nil {
+		idx.initPlanes(len(embedding))
+	}
+
+	if len(embedding) != idx.dim {
+		return
+	}
+
+	hashes := idx.hash(embedding)
+
+	for t, h := range hashes {
+		bucket, ok := idx.tables[t][h]
+		if !ok {
+			bucket = make(map[string]struct{})
+			idx.tables[t][h] = bucket
+		}
+
+		bucket[prompt] = struct{}{}
+	}
+
+	idx.hashes[prompt] = hashes
+}
+
+func (idx *lshIndex) remove(prompt string) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.removeLocked(prompt)
+}
+
+// removeLocked removes the prompt from its buckets. The caller must hold the lock.
+func (idx *lshIndex) removeLocked(prompt string) {
+	hashes, ok := idx.hashes[prompt]
+	if !ok {
+		return
+	}
+
+	for t, h := range hashes {
+		bucket := idx.tables[t][h]
+		delete(bucket, prompt)
+
+		if len(bucket) == 0 {
+			delete(idx.tables[t], h)
+		}
+	}
+
+	delete(idx.hashes, prompt)
+}
+
+// search returns the prompts sharing a bucket with the query in any table.
+// If all buckets are empty, all entries have to be compared.
+func (idx *lshIndex) search(embedding []float32) ([]string, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	if idx.planes == nil || len(embedding) != idx.dim {
+		return nil, false
+	}
+
+	seen := make(map[string]struct{})
+
+	var prompts []string
+
+	for t, h := range idx.hash(embedding) {
+		for prompt := range idx.tables[t][h] {
+			if _, ok := seen[prompt]; !ok {
+				seen[prompt] = struct{}{}
+				prompts = append(prompts, prompt)
+			}
+		}
+	}
+
+	if len(prompts) == 0 {
+		return nil, false
+	}
+
+	return prompts, true
+}
+
+func (idx *lshIndex) reset() {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+
+	idx.resetLocked()
+}
+
+// resetLocked removes all entries and hyperplanes. The caller must hold the lock.
+func (idx *lshIndex) resetLocked() {
+	idx.dim = 0
+	idx.planes = nil
+	idx.tables = make([]map[uint64]map[string]struct{}, idx.opts.Tables)
+	idx.hashes = make(map[string][]uint64)
+
+	for t := range idx.tables {
+		idx.tables[t] = make(map[uint64]map[string]struct{})
+	}
+}
+
+// initPlanes creates the random hyperplanes of all tables. The caller must hold the lock.
+func (idx *lshIndex) initPlanes(dim int) {
+	r := rand.New(rand.NewSource(idx.opts.Seed)) // nolint gosec
+
+	idx.dim = dim
+	idx.planes = make([][]float32, idx.opts.Tables)
+
+	for t := range idx.planes {
+		idx.planes[t] = make([]float32, idx.opts.Bits*dim)
+
+		// Normally distributed normals give uniformly distributed hyperplanes
+		for i := range idx.planes[t] {
+			idx.planes[t][i] = float32(r.NormFloat64())
+		}
+	}
+}
+
+// hash returns the hash of the embedding in each table.
+func (idx *lshIndex) hash(embedding []float32) []uint64 {
+	p := idx.projections.Get().(*[]float32) // nolint errcheck
+	defer idx.projections.Put(p)
+
+	projections := *p
+	hashes := make([]uint64, len(idx.planes))
+
+	for t, planes := range idx.planes {
+		math32.DotBatch(embedding, planes, projections)
+
+		var h uint64
+
+		for i, d := range projections {
+			if d > 0 {
+				h |= 1 << i
+			}
+		}
+
+		hashes[t] = h
+	}
+
+	return hashes
+}
@@ -0,0 +1,154 @@
+package llmcache
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLSHIndex(t *testing.T) {
+	idx := newLSHIndex(LSHOptions{Tables: 4, Bits: 8, Seed: 1})
+
+	_, ok := idx.search([]float32{1, 0, 0, 0})
+	assert.False(t, ok, "empty index")
+
+	idx.add("a", []float32{1, 0.1, 0, 0})
+	idx.add("b", []float32{-1, -0.1, 0, 0})
+
+	t.Run("Search", func(t *testing.T) {
+		prompts, ok := idx.search([]float32{1, 0.1, 0, 0})
+		assert.True(t, ok)
+		assert.Equal(t, []string{"a"}, prompts)
+
+		prompts, ok = idx.search([]float32{-1, -0.1, 0, 0})
+		assert.True(t, ok)
+		assert.Equal(t, []string{"b"}, prompts)
+	})
+
+	t.Run("Dimension Mismatch", func(t *testing.T) {
+		_, ok := idx.search([]float32{1, 0})
+		assert.False(t, ok)
+
+		idx.add("c", []float32{1, 0})
+		assert.NotContains(t, idx.hashes, "c")
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		idx.remove("a")
+
+		_, ok := idx.search([]float32{1, 0.1, 0, 0})
+		assert.False(t, ok, "empty buckets fall back to brute force")
+
+		for _, table := range idx.tables {
+			assert.Len(t, table, 1)
+		}
+	})
+
+	t.Run("Reset", func(t *testing.T) {
+		idx.reset()
+
+		assert.Empty(t, idx.hashes)
+		assert.Nil(t, idx.planes)
+	})
+}
+
+func TestLRUSimilarityEngine_LSH(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"prompt1": {0.1, 0.2, 0.3, 0.4},
+			"prompt2": {0.1, 0.2, 0.3, 0.41},
+			"prompt3": {-0.1, -0.2, -0.3, -0.4},
+		},
+	}
+
+	cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+		o.MaxCacheSize = 1
+		o.LSH = &LSHOptions{}
+	})
+	assert.NoError(t, err)
+
+	ctx := context.TODO()
+
+	err = cache.Update(ctx, "prompt1", "result1")
+	assert.NoError(t, err)
+
+	result, ok := cache.Lookup(ctx, "prompt2")
+	assert.True(t, ok)
+	assert.Equal(t, "result1", result)
+
+	_, ok = cache.Lookup(ctx, "prompt3")
+	assert.False(t, ok)
+
+	// prompt1 was evicted by the embedding of prompt3
+	idx := cache.index.(*lshIndex)
+	assert.NotContains(t, idx.hashes, namespaceKey("", "prompt1"))
+	assert.Contains(t, idx.hashes, namespaceKey("", "prompt3"))
+
+	t.Run("Fallback", func(t *testing.T) {
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.LSH = &LSHOptions{}
+		})
+		assert.NoError(t, err)
+
+		tenant := WithNamespace(ctx, "tenant")
+		assert.NoError(t, cache.Update(tenant, "prompt1", "result1"))
+		assert.NoError(t, cache.Update(ctx, "prompt3", "result3"))
+
+		_, ok := cache.Lookup(ctx, "prompt2")
+		assert.False(t, ok)
+
+		query := newQueryEmbedding(mockEmbedder.embeddings["prompt2"], QuantizationNone)
+
+		// The buckets of the query only hold an entry of another namespace and the embedding of the lookup
+		prompts, ok := cache.index.search(query.vector)
+		assert.True(t, ok)
+		assert.ElementsMatch(t, []string{namespaceKey("tenant", "prompt1"), namespaceKey("", "prompt2")}, prompts)
+		assert.ElementsMatch(t, cache.cache.Keys(), cache.prompts("", query))
+
+		assert.Equal(t, []string{namespaceKey("tenant", "prompt1")}, cache.prompts("tenant", query))
+	})
+
+	t.Run("Multiple Indexes", func(t *testing.T) {
+		_, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.LSH = &LSHOptions{}
+			o.IVF = &IVFOptions{}
+		})
+		assert.Error(t, err)
+	})
+}
+
+func BenchmarkLRUSimilarityEngine_LSH(b *testing.B) {
+	const entries = 10000
+
+	embedder := &mockEmbedder{embeddings: make(map[string][]float32, entries+1)}
+	for i := 0; i <= entries; i++ {
+		embedder.embeddings[fmt.Sprintf("prompt%d", i)] = randomEmbedding(384)
+	}
+
+	for _, lsh := range []*LSHOptions{nil, {Tables: 16, Bits: 8}} {
+		engine, err := NewLRUSimilarityEngine[string](embedder, func(o *LRUSimilarityEngineOptions) {
+			o.MaxCacheSize = entries + 1
+			o.LSH = lsh
+		})
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		for i := 0; i < entries; i++ {
+			if err := engine.Update(context.Background(), fmt.Sprintf("prompt%d", i), "result"); err != nil {
+				b.Fatal(err)
+			}
+		}
+
+		query := fmt.Sprintf("prompt%d", entries)
+
+		b.Run(fmt.Sprintf("lsh=%t", lsh != nil), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				engine.cache.Remove(namespaceKey("", query))
+				engine.Lookup(context.Background(), query)
+			}
+		})
+	}
+}
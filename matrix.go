@@ -0,0 +1,121 @@
+package llmcache
+
+import (
+	"slices"
+	"sync"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+)
+
+// embeddingMatrix holds the embeddings of the cached prompts in a contiguous row-major matrix,
+// so that a brute-force scan scores all entries with a single call of a batch kernel.
+type embeddingMatrix struct {
+	mu sync.RWMutex
+	// dim is the dimension of the rows, which is set by the first row.
+	dim int
+	// data holds the rows of the matrix.
+	data []float32
+	// prompts holds the prompt of each row.
+	prompts []string
+	// rows maps the prompts to their row.
+	rows map[string]int
+}
+
+// newEmbeddingMatrix creates a new embeddingMatrix instance.
+func newEmbeddingMatrix() *embeddingMatrix {
+	return &embeddingMatrix{
+		rows: make(map[string]int),
+	}
+}
+
+// add adds the embedding of the prompt, or replaces it if the prompt is already present.
+// Embeddings whose dimension differs from the rows are ignored.
+func (m *embeddingMatrix) add(prompt string, embedding []float32) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if len(m.prompts) == 0 {
+		m.dim = len(embedding)
+	}
+
+	if len(embedding) != m.dim {
+		return
+	}
+
+	if row, ok := m.rows[prompt]; ok {
+		copy(m.data[row*m.dim:(row+1)*m.dim], embedding)
+		return
+	}
+
+	m.rows[prompt] = len(m.prompts)
+	m.prompts = append(m.prompts, prompt)
+	m.data = append(m.data, embedding...)
+}
+
+// remove removes the embedding of the prompt. The last row is moved into its place.
+func (m *embeddingMatrix) remove(prompt string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	row, ok := m.rows[prompt]
+	if !ok {
+		return
+	}
+
+	last := len(m.prompts) - 1
+
+	if row != last {
+		copy(m.data[row*m.dim:(row+1)*m.dim], m.data[last*m.dim:])
+		m.prompts[row] = m.prompts[last]
+		m.rows[m.prompts[row]] = row
+	}
+
+	delete(m.rows, prompt)
+	m.prompts = m.prompts[:last]
+	m.data = m.data[:last*m.dim]
+}
+
+// reset removes all embeddings.
+func (m *embeddingMatrix) reset() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.dim = 0
+	m.data = nil
+	m.prompts = nil
+	m.rows = make(map[string]int)
+}
+
+// scan scores the query against every row with the batch kernel and returns the prompts of
+// the rows with their scores. The prompts are copied, so the caller may look up the entries
+// without holding the lock of the matrix.
+func (m *embeddingMatrix) scan(query []float32, kernel func(q, m, out []float32)) ([]string, []float32, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if len(m.prompts) == 0 {
+		return nil, nil, nil
+	}
+
+	if len(query) != m.dim {
+		return nil, nil, ErrVectorSizeMismatch
+	}
+
+	scores := make([]float32, len(m.prompts))
+	kernel(query, m.data, scores)
+
+	return slices.Clone(m.prompts), scores, nil
+}
+
+// batchKernel returns the batch kernel computing the scores of the metric, and whether
+// the metric can be computed from them.
+func batchKernel(metric metric) (func(q, m, out []float32), bool) {
+	switch metric {
+	case metricCosine, metricNegativeInnerProduct:
+		return math32.DotBatch, true
+	case metricSquaredL2, metricEuclidean:
+		return math32.SquaredL2Batch, true
+	default:
+		return nil, false
+	}
+}
@@ -0,0 +1,132 @@
+package llmcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEmbeddingMatrix(t *testing.T) {
+	t.Run("Add And Remove", func(t *testing.T) {
+		m := newEmbeddingMatrix()
+
+		m.add("prompt1", []float32{1, 0})
+		m.add("prompt2", []float32{0, 1})
+		m.add("prompt3", []float32{1, 1})
+
+		// Embeddings of another dimension are ignored
+		m.add("prompt4", []float32{1, 1, 1})
+
+		prompts, scores, err := m.scan([]float32{2, 3}, math32.DotBatch)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"prompt1", "prompt2", "prompt3"}, prompts)
+		assert.Equal(t, []float32{2, 3, 5}, scores)
+
+		// The last row is moved into the place of the removed row
+		m.remove("prompt1")
+		m.remove("prompt4")
+
+		prompts, scores, err = m.scan([]float32{2, 3}, math32.DotBatch)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"prompt3", "prompt2"}, prompts)
+		assert.Equal(t, []float32{5, 3}, scores)
+
+		// An existing row is replaced
+		m.add("prompt2", []float32{2, 2})
+
+		prompts, scores, err = m.scan([]float32{2, 3}, math32.SquaredL2Batch)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"prompt3", "prompt2"}, prompts)
+		assert.Equal(t, []float32{5, 1}, scores)
+
+		m.remove("prompt2")
+		m.remove("prompt3")
+
+		prompts, _, err = m.scan([]float32{2, 3}, math32.DotBatch)
+		assert.NoError(t, err)
+		assert.Empty(t, prompts)
+	})
+
+	t.Run("Dimension Mismatch", func(t *testing.T) {
+		m := newEmbeddingMatrix()
+		m.add("prompt1", []float32{1, 0})
+
+		_, _, err := m.scan([]float32{1, 0, 0}, math32.DotBatch)
+		assert.ErrorIs(t, err, ErrVectorSizeMismatch)
+
+		// The dimension is set anew after a reset
+		m.reset()
+		m.add("prompt1", []float32{1, 0, 0})
+
+		_, scores, err := m.scan([]float32{1, 0, 0}, math32.DotBatch)
+		assert.NoError(t, err)
+		assert.Equal(t, []float32{1}, scores)
+	})
+
+	t.Run("Engine", func(t *testing.T) {
+		ctx := context.Background()
+
+		for _, tc := range []struct {
+			fn        DistanceFunc
+			threshold float32
+		}{
+			{CosineDistance, 0.2},
+			{SquaredL2, 0.2},
+			{Euclidean, 0.2},
+			// The negated inner product of prompt1 and prompt3 is -0.304
+			{NegativeInnerProduct, -0.3},
+		} {
+			engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{
+				embeddings: map[string][]float32{
+					"prompt1": {0.1, 0.2, 0.3, 0.4},
+					"prompt2": {0.9, 0.1, 0.1, 0.1},
+					"prompt3": {0.1, 0.2, 0.3, 0.41},
+					"prompt4": {0.1, 0.9, 0.1, 0.1},
+				},
+			}, func(o *LRUSimilarityEngineOptions) {
+				o.MaxCacheSize = 2
+				o.DistanceFunc = tc.fn
+				o.Threshold = tc.threshold
+			})
+			assert.NoError(t, err)
+			assert.NotNil(t, engine.matrix)
+
+			assert.NoError(t, engine.Update(ctx, "prompt1", "result1"))
+			assert.NoError(t, engine.Update(ctx, "prompt2", "result2"))
+
+			match, ok := engine.LookupMatch(ctx, "prompt3")
+			assert.True(t, ok)
+			assert.Equal(t, "prompt1", match.Prompt)
+			assert.Equal(t, "result1", match.Result)
+
+			want, err := tc.fn([]float32{0.1, 0.2, 0.3, 0.41}, []float32{0.1, 0.2, 0.3, 0.4})
+			assert.NoError(t, err)
+			assert.InDelta(t, want, match.Distance, 1e-6)
+
+			// Evicted entries are removed from the matrix
+			assert.NoError(t, engine.Update(ctx, "prompt4", "result4"))
+			assert.NoError(t, engine.Update(ctx, "prompt2", "result2"))
+
+			_, ok = engine.Lookup(ctx, "prompt3")
+			assert.False(t, ok)
+			assert.Len(t, engine.matrix.prompts, engine.Len())
+
+			assert.NoError(t, engine.Clear(ctx))
+			assert.Empty(t, engine.matrix.prompts)
+		}
+	})
+
+	t.Run("Not Used", func(t *testing.T) {
+		for _, fn := range []func(o *LRUSimilarityEngineOptions){
+			func(o *LRUSimilarityEngineOptions) { o.Quantization = QuantizationInt8 },
+			func(o *LRUSimilarityEngineOptions) { o.DistanceFunc = Manhattan },
+			func(o *LRUSimilarityEngineOptions) { o.LSH = &LSHOptions{} },
+		} {
+			engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{}, fn)
+			assert.NoError(t, err)
+			assert.Nil(t, engine.matrix)
+		}
+	})
+}
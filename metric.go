@@ -2,10 +2,15 @@ package llmcache
 
 import (
 	"errors"
+	"math"
+	"math/bits"
 
 	"github.com/hupe1980/go-llmcache/internal/math32"
 )
 
+// ErrVectorSizeMismatch is returned when two vectors of different sizes are compared.
+var ErrVectorSizeMismatch = errors.New("vector sizes do not match")
+
 // Magnitude calculates the magnitude (length) of a float32 slice.
 func Magnitude(v []float32) float32 {
 	return math32.Sqrt(math32.Dot(v, v))
@@ -15,7 +20,7 @@ func Magnitude(v []float32) float32 {
 func CosineSimilarity(v1, v2 []float32) (float32, error) {
 	// Check if the vector sizes match
 	if len(v1) != len(v2) {
-		return 0, errors.New("vector sizes do not match")
+		return 0, ErrVectorSizeMismatch
 	}
 
 	dotProduct := math32.Dot(v1, v2)
@@ -31,6 +36,8 @@ func CosineSimilarity(v1, v2 []float32) (float32, error) {
 }
 
 // CosineDistance calculates the cosine distance between two float32 slices.
+// The distance ranges from 0 (same direction) to 2 (opposite direction);
+// thresholds between 0.05 and 0.25 are typical for semantic caching.
 func CosineDistance(a, b []float32) (float32, error) {
 	s, err := CosineSimilarity(a, b)
 	if err != nil {
@@ -41,11 +48,101 @@ func CosineDistance(a, b []float32) (float32, error) {
 }
 
 // SquaredL2 calculates the squared L2 distance between two float32 slices.
+// For unit-length embeddings the distance ranges from 0 to 4 and equals twice the cosine distance.
 func SquaredL2(v1, v2 []float32) (float32, error) {
 	// Check if the vector sizes match
 	if len(v1) != len(v2) {
-		return 0, errors.New("vector sizes do not match")
+		return 0, ErrVectorSizeMismatch
 	}
 
 	return math32.SquaredL2(v1, v2), nil
 }
+
+// Euclidean calculates the Euclidean (L2) distance between two float32 slices.
+// For unit-length embeddings the distance ranges from 0 to 2; thresholds between 0.3 and 0.7 are typical.
+func Euclidean(v1, v2 []float32) (float32, error) {
+	d, err := SquaredL2(v1, v2)
+	if err != nil {
+		return 0, err
+	}
+
+	return math32.Sqrt(d), nil
+}
+
+// NegativeInnerProduct calculates the negated inner product of two float32 slices,
+// so that smaller values indicate more similar vectors. It is intended for embedding
+// models trained for dot-product similarity. The range depends on the model; for
+// unit-length embeddings it is -1 to 1 and a threshold around -0.8 is a good start.
+func NegativeInnerProduct(v1, v2 []float32) (float32, error) {
+	// Check if the vector sizes match
+	if len(v1) != len(v2) {
+		return 0, ErrVectorSizeMismatch
+	}
+
+	return -math32.Dot(v1, v2), nil
+}
+
+// Manhattan calculates the Manhattan (L1) distance between two float32 slices.
+// The distance grows with the number of dimensions, so the threshold has to be
+// chosen per embedding model, e.g. by calibrating it on labeled prompt pairs.
+func Manhattan(v1, v2 []float32) (float32, error) {
+	// Check if the vector sizes match
+	if len(v1) != len(v2) {
+		return 0, ErrVectorSizeMismatch
+	}
+
+	return math32.L1(v1, v2), nil
+}
+
+// AngularDistance calculates the normalized angle between two float32 slices.
+// Unlike the cosine distance it is a proper metric. The distance ranges from
+// 0 (same direction) to 1 (opposite direction); thresholds between 0.1 and 0.25 are typical.
+func AngularDistance(v1, v2 []float32) (float32, error) {
+	s, err := CosineSimilarity(v1, v2)
+	if err != nil {
+		return 0, err
+	}
+
+	// Clamp rounding errors to the domain of acos
+	s = float32(math.Max(-1, math.Min(1, float64(s))))
+
+	return float32(math.Acos(float64(s)) / math.Pi), nil
+}
+
+// Hamming calculates the number of differing bits between two packed bit vectors.
+// Each float32 element carries 32 bits of the signature, see PackBits. The distance
+// ranges from 0 to 32 times the vector size; thresholds of a few percent of the
+// total number of bits are typical.
+func Hamming(v1, v2 []float32) (float32, error) {
+	// Check if the vector sizes match
+	if len(v1) != len(v2) {
+		return 0, ErrVectorSizeMismatch
+	}
+
+	distance := 0
+	for i := range v1 {
+		distance += bits.OnesCount32(math.Float32bits(v1[i]) ^ math.Float32bits(v2[i]))
+	}
+
+	return float32(distance), nil
+}
+
+// PackBits packs a bit vector into float32 elements holding 32 bits each,
+// so that it can be compared with Hamming.
+func PackBits(b []bool) []float32 {
+	packed := make([]float32, (len(b)+31)/32)
+
+	for i := 0; i < len(packed); i++ {
+		var word uint32
+
+		for j := 0; j < 32 && i*32+j < len(b); j++ {
+			if b[i*32+j] {
+				word |= 1 << j
+			}
+		}
+
+		packed[i] = math.Float32frombits(word)
+	}
+
+	return packed
+}
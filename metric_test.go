@@ -175,6 +175,56 @@ func TestSquaredL2(t *testing.T) {
 	}
 }
 
+func TestDistanceFuncs(t *testing.T) {
+	tests := []struct {
+		name     string
+		fn       DistanceFunc
+		vector1  []float32
+		vector2  []float32
+		expected float32
+	}{
+		{name: "Euclidean Identical", fn: Euclidean, vector1: []float32{3, 4}, vector2: []float32{3, 4}, expected: 0},
+		{name: "Euclidean", fn: Euclidean, vector1: []float32{0, 0}, vector2: []float32{3, 4}, expected: 5},
+		{name: "NegativeInnerProduct", fn: NegativeInnerProduct, vector1: []float32{1, 2, 3}, vector2: []float32{4, 5, 6}, expected: -32},
+		{name: "NegativeInnerProduct Orthogonal", fn: NegativeInnerProduct, vector1: []float32{1, 0}, vector2: []float32{0, 1}, expected: 0},
+		{name: "Manhattan", fn: Manhattan, vector1: []float32{1, -2, 3}, vector2: []float32{-4, 5, -6}, expected: 21},
+		{name: "Manhattan Identical", fn: Manhattan, vector1: []float32{1, 2}, vector2: []float32{1, 2}, expected: 0},
+		{name: "AngularDistance Parallel", fn: AngularDistance, vector1: []float32{3, 4}, vector2: []float32{6, 8}, expected: 0},
+		{name: "AngularDistance Orthogonal", fn: AngularDistance, vector1: []float32{1, 0}, vector2: []float32{0, 1}, expected: 0.5},
+		{name: "AngularDistance Opposite", fn: AngularDistance, vector1: []float32{3, 4}, vector2: []float32{-3, -4}, expected: 1},
+		{name: "Hamming Identical", fn: Hamming, vector1: PackBits([]bool{true, false, true}), vector2: PackBits([]bool{true, false, true}), expected: 0},
+		{name: "Hamming", fn: Hamming, vector1: PackBits([]bool{true, false, true, true}), vector2: PackBits([]bool{false, false, true, false}), expected: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			actual, err := tt.fn(tt.vector1, tt.vector2)
+			require.NoError(t, err)
+			assert.InDelta(t, tt.expected, actual, 1e-6)
+		})
+	}
+
+	t.Run("Different Length Vectors", func(t *testing.T) {
+		for _, fn := range []DistanceFunc{Euclidean, NegativeInnerProduct, Manhattan, AngularDistance, Hamming} {
+			_, err := fn([]float32{1, 2}, []float32{1, 2, 3})
+			require.ErrorIs(t, err, ErrVectorSizeMismatch)
+		}
+	})
+}
+
+func TestPackBits(t *testing.T) {
+	bits := make([]bool, 40)
+	bits[0] = true
+	bits[33] = true
+
+	packed := PackBits(bits)
+	require.Len(t, packed, 2)
+
+	distance, err := Hamming(packed, PackBits(make([]bool, 40)))
+	require.NoError(t, err)
+	assert.Equal(t, float32(2), distance)
+}
+
 // BenchmarkCosineSimilarity benchmarks the CosineSimilarity function.
 func BenchmarkCosineSimilarity(b *testing.B) {
 	// Prepare random input data
@@ -0,0 +1,369 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ErrNamespacesNotSupported is returned when an engine does not partition its entries by namespace.
+var ErrNamespacesNotSupported = errors.New("engine does not support namespaces")
+
+// namespaceContextKey is the context key of the namespace.
+type namespaceContextKey struct{}
+
+// WithNamespace returns a copy of the context carrying the namespace. Engines supporting namespaces
+// only match entries of the namespace of the context. The default namespace is the empty string.
+func WithNamespace(ctx context.Context, namespace string) context.Context {
+	return context.WithValue(ctx, namespaceContextKey{}, namespace)
+}
+
+// NamespaceFromContext returns the namespace carried by the context, or the default namespace.
+func NamespaceFromContext(ctx context.Context) string {
+	namespace, _ := ctx.Value(namespaceContextKey{}).(string)
+	return namespace
+}
+
+// Namespacer is implemented by engines which partition their entries by namespace.
+type Namespacer interface {
+	// ClearNamespace removes all entries of the namespace.
+	ClearNamespace(ctx context.Context, namespace string) error
+	// NamespaceStats returns the usage statistics of the namespace.
+	NamespaceStats(namespace string) Stats
+	// Namespaces returns the names of the namespaces with entries or statistics.
+	Namespaces() []string
+}
+
+// Namespace is a view of an LLMCache whose operations apply to one namespace.
+type Namespace[T comparable] struct {
+	// cache is the viewed cache.
+	cache *LLMCache[T]
+	// name is the name of the namespace.
+	name string
+}
+
+// Name returns the name of the namespace.
+func (n *Namespace[T]) Name() string {
+	return n.name
+}
+
+// Lookup retrieves the cached result of the namespace associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (n *Namespace[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	return n.cache.Lookup(WithNamespace(ctx, n.name), prompt)
+}
+
+// LookupMatch retrieves the cached entry of the namespace matching the given prompt.
+// It returns the match and a boolean indicating whether a match was found.
+func (n *Namespace[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	return n.cache.LookupMatch(WithNamespace(ctx, n.name), prompt)
+}
+
+// Update updates the namespace with the provided prompt and result.
+// It returns an error if the update operation fails.
+func (n *Namespace[T]) Update(ctx context.Context, prompt string, result T) error {
+	return n.cache.Update(WithNamespace(ctx, n.name), prompt, result)
+}
+
+// GetOrCompute retrieves the cached result of the prompt in the namespace, or computes and caches it on a miss.
+func (n *Namespace[T]) GetOrCompute(ctx context.Context, prompt string, compute ComputeFunc[T]) (T, error) {
+	return n.cache.GetOrCompute(WithNamespace(ctx, n.name), prompt, compute)
+}
+
+// Clear removes all entries of the namespace.
+func (n *Namespace[T]) Clear(ctx context.Context) error {
+	return n.cache.ClearNamespace(ctx, n.name)
+}
+
+// Stats returns the usage statistics of the namespace.
+func (n *Namespace[T]) Stats() (Stats, error) {
+	return n.cache.NamespaceStats(n.name)
+}
+
+// NamespaceQuota limits the entries of a namespace. Zero values mean no limit.
+type NamespaceQuota struct {
+	// MaxEntries is the maximum number of entries of the namespace.
+	MaxEntries int
+	// MaxBytes is the maximum size of the prompts and results of the namespace.
+	MaxBytes int64
+}
+
+// NamespaceOptions contains options for the namespaces of an engine. The namespaces share the
+// capacity of the engine; a namespace exceeding its quota evicts its own least recently used entries.
+type NamespaceOptions struct {
+	// Quotas contains the quotas of the namespaces by name.
+	Quotas map[string]NamespaceQuota
+	// DefaultQuota is the quota of the namespaces without an entry in Quotas.
+	DefaultQuota NamespaceQuota
+	// ResultSize returns the size of a result in bytes. Default measures strings and byte slices,
+	// the size of other results is zero.
+	ResultSize func(result any) int
+}
+
+// namespaceKey returns the cache key of the prompt in the namespace. The key is prefixed with the
+// length of the namespace, e.g. "6:tenantprompt", and the keys of the default namespace are prefixed
+// with "0:", so no namespace and prompt share a key with another namespace and prompt.
+func namespaceKey(namespace, prompt string) string {
+	return strconv.Itoa(len(namespace)) + ":" + namespace + prompt
+}
+
+// splitNamespaceKey returns the namespace and the prompt of a cache key.
+// A key not created by namespaceKey is returned as prompt of the default namespace.
+func splitNamespaceKey(key string) (string, string) {
+	prefix, rest, ok := strings.Cut(key, ":")
+	if !ok {
+		return "", key
+	}
+
+	n, err := strconv.Atoi(prefix)
+	if err != nil || n < 0 || n > len(rest) {
+		return "", key
+	}
+
+	return rest[:n], rest[n:]
+}
+
+// resultSize returns the size of a result for the byte quotas.
+func (o NamespaceOptions) resultSize(result any) int {
+	if o.ResultSize != nil {
+		return o.ResultSize(result)
+	}
+
+	switch r := result.(type) {
+	case string:
+		return len(r)
+	case []byte:
+		return len(r)
+	default:
+		return 0
+	}
+}
+
+// quota returns the quota of the namespace.
+func (o NamespaceOptions) quota(namespace string) NamespaceQuota {
+	if q, ok := o.Quotas[namespace]; ok {
+		return q
+	}
+
+	return o.DefaultQuota
+}
+
+// namespaceUsage is the usage of a namespace.
+type namespaceUsage struct {
+	// stats contains the usage statistics, except the number of entries.
+	stats Stats
+	// bytes is the size of the prompts and results.
+	bytes int64
+}
+
+// namespaceTracker tracks the entries and statistics of the namespaces of an engine.
+type namespaceTracker struct {
+	// opts contains the options of the namespaces.
+	opts NamespaceOptions
+	// mu guards the fields below.
+	mu sync.Mutex
+	// sizes holds the size of each cached key.
+	sizes map[string]int64
+	// usage holds the usage of each namespace.
+	usage map[string]*namespaceUsage
+	// entries holds the number of entries of each namespace.
+	entries map[string]int
+}
+
+// newNamespaceTracker creates a new namespaceTracker instance.
+func newNamespaceTracker(opts NamespaceOptions) *namespaceTracker {
+	return &namespaceTracker{
+		opts:    opts,
+		sizes:   make(map[string]int64),
+		usage:   make(map[string]*namespaceUsage),
+		entries: make(map[string]int),
+	}
+}
+
+// get returns the usage of the namespace. It must be called with the lock held.
+func (t *namespaceTracker) get(namespace string) *namespaceUsage {
+	u, ok := t.usage[namespace]
+	if !ok {
+		u = &namespaceUsage{}
+		t.usage[namespace] = u
+	}
+
+	return u
+}
+
+// add records the entry of the key. It must be called before the entry is added to the cache,
+// so that a concurrent removal cannot be recorded before the addition.
+func (t *namespaceTracker) add(key string, result any) {
+	_, prompt := splitNamespaceKey(key)
+	t.addSize(key, int64(len(prompt)+t.opts.resultSize(result)))
+}
+
+// addSize records the entry of the key with the given size.
+func (t *namespaceTracker) addSize(key string, size int64) {
+	namespace, _ := splitNamespaceKey(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.get(namespace)
+
+	if old, ok := t.sizes[key]; ok {
+		u.bytes -= old
+	} else {
+		t.entries[namespace]++
+	}
+
+	t.sizes[key] = size
+	u.bytes += size
+}
+
+// remove records the removal of the entry of the key.
+func (t *namespaceTracker) remove(key string) {
+	namespace, _ := splitNamespaceKey(key)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	size, ok := t.sizes[key]
+	if !ok {
+		return
+	}
+
+	delete(t.sizes, key)
+	t.get(namespace).bytes -= size
+
+	if t.entries[namespace]--; t.entries[namespace] <= 0 {
+		delete(t.entries, namespace)
+	}
+}
+
+// reset removes all entries, but keeps the statistics.
+func (t *namespaceTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.sizes)
+	clear(t.entries)
+
+	for _, u := range t.usage {
+		u.bytes = 0
+	}
+}
+
+// exceeded reports whether the namespace exceeds its quota.
+func (t *namespaceTracker) exceeded(namespace string) bool {
+	quota := t.opts.quota(namespace)
+	if quota.MaxEntries <= 0 && quota.MaxBytes <= 0 {
+		return false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if quota.MaxEntries > 0 && t.entries[namespace] > quota.MaxEntries {
+		return true
+	}
+
+	return quota.MaxBytes > 0 && t.get(namespace).bytes > quota.MaxBytes
+}
+
+// lookup records a lookup in the namespace.
+func (t *namespaceTracker) lookup(namespace string, hit bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.get(namespace)
+	u.stats.Lookups++
+
+	if hit {
+		u.stats.Hits++
+	} else {
+		u.stats.Misses++
+	}
+}
+
+// update records an update in the namespace.
+func (t *namespaceTracker) update(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.get(namespace).stats.Updates++
+}
+
+// delete records a delete in the namespace.
+func (t *namespaceTracker) delete(namespace string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.get(namespace).stats.Deletes++
+}
+
+// stats returns the usage statistics of the namespace.
+func (t *namespaceTracker) stats(namespace string) Stats {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var stats Stats
+	if u, ok := t.usage[namespace]; ok {
+		stats = u.stats
+	}
+
+	stats.Entries = t.entries[namespace]
+
+	return stats
+}
+
+// names returns the sorted names of the namespaces with entries or statistics.
+func (t *namespaceTracker) names() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	names := make([]string, 0, len(t.usage))
+	for namespace := range t.usage {
+		names = append(names, namespace)
+	}
+
+	slices.Sort(names)
+
+	return names
+}
+
+// enforce evicts the least recently used entries of the namespace of the key, except the key itself,
+// until the namespace is within its quota. keys returns the cached keys from the least to the most
+// recently used, remove removes the entry of a key.
+func (t *namespaceTracker) enforce(key string, keys func() []string, remove func(key string)) {
+	namespace, _ := splitNamespaceKey(key)
+
+	if !t.exceeded(namespace) {
+		return
+	}
+
+	for _, k := range keys() {
+		if k == key {
+			continue
+		}
+
+		if ns, _ := splitNamespaceKey(k); ns != namespace {
+			continue
+		}
+
+		remove(k)
+
+		if !t.exceeded(namespace) {
+			return
+		}
+	}
+}
+
+// clearNamespace removes the entries of the namespace. keys returns the cached keys,
+// remove removes the entry of a key.
+func clearNamespace(namespace string, keys []string, remove func(key string)) {
+	for _, k := range keys {
+		if ns, _ := splitNamespaceKey(k); ns == namespace {
+			remove(k)
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package llmcache
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNamespaceContext(t *testing.T) {
+	ctx := context.TODO()
+	assert.Equal(t, "", NamespaceFromContext(ctx))
+	assert.Equal(t, "tenant-a", NamespaceFromContext(WithNamespace(ctx, "tenant-a")))
+}
+
+func TestNamespaceKey(t *testing.T) {
+	assert.Equal(t, "0:prompt", namespaceKey("", "prompt"))
+	assert.Equal(t, "8:tenant-aprompt", namespaceKey("tenant-a", "prompt"))
+
+	for _, tc := range []struct{ namespace, prompt string }{
+		{"", "prompt"},
+		{"tenant-a", "prompt"},
+		{"", "8:tenant-aprompt"},
+		{"tenant\x00A", "x"},
+		{"tenant", "A\x00x"},
+		{"", ""},
+	} {
+		namespace, prompt := splitNamespaceKey(namespaceKey(tc.namespace, tc.prompt))
+		assert.Equal(t, tc.namespace, namespace)
+		assert.Equal(t, tc.prompt, prompt)
+	}
+
+	// Prompts cannot forge the key of another namespace
+	assert.NotEqual(t, namespaceKey("tenant", "A\x00x"), namespaceKey("tenant\x00A", "x"))
+	assert.NotEqual(t, namespaceKey("", namespaceKey("tenant-a", "prompt")), namespaceKey("tenant-a", "prompt"))
+
+	namespace, prompt := splitNamespaceKey("prompt")
+	assert.Equal(t, "", namespace)
+	assert.Equal(t, "prompt", prompt)
+}
+
+func TestNamespaceIsolation(t *testing.T) {
+	ctx := context.TODO()
+	tenantA := WithNamespace(ctx, "tenant-a")
+	tenantB := WithNamespace(ctx, "tenant-b")
+
+	engines := map[string]func(t *testing.T) Engine[string]{
+		"LRUEngine": func(t *testing.T) Engine[string] {
+			engine, err := NewLRUEngine[string]()
+			assert.NoError(t, err)
+
+			return engine
+		},
+		"LRUSimilarityEngine": func(t *testing.T) Engine[string] {
+			engine, err := NewLRUSimilarityEngine[string](NewHashEmbedder(), func(o *LRUSimilarityEngineOptions) {
+				o.Threshold = 0.5
+			})
+			assert.NoError(t, err)
+
+			return engine
+		},
+		"FileEngine": func(t *testing.T) Engine[string] {
+			engine, err := NewFileEngine[string](t.TempDir(), NewHashEmbedder(), func(o *FileEngineOptions[string]) {
+				o.Threshold = 0.5
+			})
+			assert.NoError(t, err)
+			t.Cleanup(func() { _ = engine.Close() })
+
+			return engine
+		},
+		"BoltEngine": func(t *testing.T) Engine[string] {
+			engine, err := NewBoltEngine[string](filepath.Join(t.TempDir(), "cache.db"), NewHashEmbedder(), func(o *BoltEngineOptions[string]) {
+				o.Threshold = 0.5
+			})
+			assert.NoError(t, err)
+			t.Cleanup(func() { _ = engine.Close() })
+
+			return engine
+		},
+	}
+
+	for name, newEngine := range engines {
+		t.Run(name, func(t *testing.T) {
+			engine := newEngine(t)
+
+			assert.NoError(t, engine.Update(tenantA, "What year was Albert Einstein born?", "1879 for tenant A"))
+
+			// Neither exact nor similar prompts of another namespace match
+			_, ok := engine.Lookup(tenantB, "What year was Albert Einstein born?")
+			assert.False(t, ok)
+
+			_, ok = engine.Lookup(tenantB, "In what year was Albert Einstein born?")
+			assert.False(t, ok)
+
+			_, ok = engine.Lookup(ctx, "What year was Albert Einstein born?")
+			assert.False(t, ok)
+
+			match, ok := lookupMatch(tenantA, engine, "What year was Albert Einstein born?")
+			assert.True(t, ok)
+			assert.Equal(t, Match[string]{Result: "1879 for tenant A", Prompt: "What year was Albert Einstein born?", Exact: true}, match)
+
+			// The same prompt is cached separately in each namespace
+			assert.NoError(t, engine.Update(tenantB, "What year was Albert Einstein born?", "1879 for tenant B"))
+
+			result, ok := engine.Lookup(tenantB, "What year was Albert Einstein born?")
+			assert.True(t, ok)
+			assert.Equal(t, "1879 for tenant B", result)
+
+			result, ok = engine.Lookup(tenantA, "What year was Albert Einstein born?")
+			assert.True(t, ok)
+			assert.Equal(t, "1879 for tenant A", result)
+
+			// Deletes apply to the namespace of the context
+			assert.NoError(t, engine.(Deleter).Delete(tenantA, "What year was Albert Einstein born?"))
+
+			_, ok = engine.Lookup(tenantA, "What year was Albert Einstein born?")
+			assert.False(t, ok)
+
+			_, ok = engine.Lookup(tenantB, "What year was Albert Einstein born?")
+			assert.True(t, ok)
+
+			// Prompts cannot address the entries of another namespace
+			assert.NoError(t, engine.Update(tenantB, "secret question", "secret for tenant B"))
+
+			_, ok = engine.Lookup(ctx, "tenant-b\x00secret question")
+			assert.False(t, ok)
+
+			_, ok = engine.Lookup(ctx, namespaceKey("tenant-b", "secret question"))
+			assert.False(t, ok)
+
+			assert.NoError(t, engine.Update(WithNamespace(ctx, "tenant\x00A"), "x", "secret for tenant\x00A"))
+
+			_, ok = engine.Lookup(WithNamespace(ctx, "tenant"), "A\x00x")
+			assert.False(t, ok)
+
+			result, ok = engine.Lookup(WithNamespace(ctx, "tenant\x00A"), "x")
+			assert.True(t, ok)
+			assert.Equal(t, "secret for tenant\x00A", result)
+		})
+	}
+}
+
+func TestNamespaceSimilarity(t *testing.T) {
+	ctx := context.TODO()
+	tenantA := WithNamespace(ctx, "tenant-a")
+
+	engine, err := NewLRUSimilarityEngine[string](NewHashEmbedder(), func(o *LRUSimilarityEngineOptions) {
+		o.Threshold = 0.5
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, engine.Update(tenantA, "What year was Albert Einstein born?", "1879"))
+
+	match, ok := engine.LookupMatch(tenantA, "In what year was Albert Einstein born?")
+	assert.True(t, ok)
+	assert.Equal(t, "1879", match.Result)
+	assert.Equal(t, "What year was Albert Einstein born?", match.Prompt)
+	assert.False(t, match.Exact)
+}
+
+func TestNamespaceQuotas(t *testing.T) {
+	ctx := context.TODO()
+	tenantA := WithNamespace(ctx, "tenant-a")
+	tenantB := WithNamespace(ctx, "tenant-b")
+
+	t.Run("Entries", func(t *testing.T) {
+		engine, err := NewLRUEngine[string](func(o *LRUEngineOptions) {
+			o.MaxCacheSize = 100
+			o.Namespaces.Quotas = map[string]NamespaceQuota{
+				"tenant-a": {MaxEntries: 2},
+			}
+		})
+		assert.NoError(t, err)
+
+		for i := 0; i < 5; i++ {
+			assert.NoError(t, engine.Update(tenantA, fmt.Sprintf("a%d", i), "result"))
+			assert.NoError(t, engine.Update(tenantB, fmt.Sprintf("b%d", i), "result"))
+		}
+
+		// Tenant A only evicts its own entries
+		assert.Equal(t, 2, engine.NamespaceStats("tenant-a").Entries)
+		assert.Equal(t, 5, engine.NamespaceStats("tenant-b").Entries)
+
+		_, ok := engine.Lookup(tenantA, "a2")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(tenantA, "a4")
+		assert.True(t, ok)
+	})
+
+	t.Run("Least Recently Used", func(t *testing.T) {
+		engine, err := NewLRUSimilarityEngine[string](NewHashEmbedder(), func(o *LRUSimilarityEngineOptions) {
+			o.Threshold = 0.01
+			o.Namespaces.DefaultQuota = NamespaceQuota{MaxEntries: 2}
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, engine.Update(tenantA, "What year was Albert Einstein born?", "1879"))
+		assert.NoError(t, engine.Update(tenantA, "How tall is the Eiffel Tower?", "330 m"))
+
+		_, ok := engine.Lookup(tenantA, "What year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		assert.NoError(t, engine.Update(tenantA, "What is the capital of France?", "Paris"))
+
+		_, ok = engine.Lookup(tenantA, "What year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(tenantA, "How tall is the Eiffel Tower?")
+		assert.False(t, ok)
+	})
+
+	t.Run("Bytes", func(t *testing.T) {
+		engine, err := NewLRUEngine[string](func(o *LRUEngineOptions) {
+			o.Namespaces.DefaultQuota = NamespaceQuota{MaxBytes: 100}
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, engine.Update(tenantA, "a", strings.Repeat("x", 60)))
+		assert.NoError(t, engine.Update(tenantA, "b", strings.Repeat("x", 60)))
+
+		_, ok := engine.Lookup(tenantA, "a")
+		assert.False(t, ok)
+
+		// The updated entry is kept even if it exceeds the quota
+		assert.NoError(t, engine.Update(tenantA, "c", strings.Repeat("x", 200)))
+		assert.Equal(t, 1, engine.Len())
+	})
+
+	t.Run("Bolt", func(t *testing.T) {
+		engine, err := NewBoltEngine[string](filepath.Join(t.TempDir(), "cache.db"), nil, func(o *BoltEngineOptions[string]) {
+			o.Namespaces.Quotas = map[string]NamespaceQuota{
+				"tenant-a": {MaxEntries: 1},
+			}
+		})
+		assert.NoError(t, err)
+		defer engine.Close()
+
+		set := func(sec int64) {
+			engine.now = func() time.Time { return time.Unix(sec, 0) }
+		}
+
+		set(1)
+		assert.NoError(t, engine.Update(tenantA, "a1", "result"))
+		set(2)
+		assert.NoError(t, engine.Update(tenantB, "b1", "result"))
+		set(3)
+		assert.NoError(t, engine.Update(tenantA, "a2", "result"))
+
+		_, ok := engine.Lookup(tenantA, "a1")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(tenantB, "b1")
+		assert.True(t, ok)
+		assert.Equal(t, 2, engine.Len())
+	})
+}
+
+func TestNamespaceStatsAndClear(t *testing.T) {
+	ctx := context.TODO()
+	tenantA := WithNamespace(ctx, "tenant-a")
+	tenantB := WithNamespace(ctx, "tenant-b")
+
+	engine, err := NewLRUSimilarityEngine[string](NewHashEmbedder(), func(o *LRUSimilarityEngineOptions) {
+		o.Threshold = 0.5
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, engine.Update(tenantA, "What year was Albert Einstein born?", "1879"))
+	assert.NoError(t, engine.Update(tenantB, "What year was Albert Einstein born?", "1879"))
+
+	_, ok := engine.Lookup(tenantA, "In what year was Albert Einstein born?")
+	assert.True(t, ok)
+
+	_, ok = engine.Lookup(tenantA, "How do I bake sourdough bread?")
+	assert.False(t, ok)
+
+	// The entry holding the embedding of the missed lookup counts as entry
+	assert.Equal(t, Stats{Lookups: 2, Hits: 1, Misses: 1, Updates: 1, Entries: 2}, engine.NamespaceStats("tenant-a"))
+	assert.Equal(t, Stats{Updates: 1, Entries: 1}, engine.NamespaceStats("tenant-b"))
+	assert.Equal(t, []string{"tenant-a", "tenant-b"}, engine.Namespaces())
+
+	assert.NoError(t, engine.ClearNamespace(ctx, "tenant-a"))
+
+	_, ok = engine.Lookup(tenantA, "What year was Albert Einstein born?")
+	assert.False(t, ok)
+
+	_, ok = engine.Lookup(tenantB, "What year was Albert Einstein born?")
+	assert.True(t, ok)
+	assert.Equal(t, 1, engine.NamespaceStats("tenant-b").Entries)
+}
+
+func TestNamespacePersistence(t *testing.T) {
+	ctx := context.TODO()
+	tenantA := WithNamespace(ctx, "tenant-a")
+	dir := t.TempDir()
+
+	engine, err := NewFileEngine[string](dir, nil)
+	assert.NoError(t, err)
+
+	assert.NoError(t, engine.Update(tenantA, "a", "1"))
+	assert.NoError(t, engine.Update(tenantA, "b", "2"))
+	assert.NoError(t, engine.Update(ctx, "c", "3"))
+	assert.NoError(t, engine.Delete(tenantA, "b"))
+	assert.NoError(t, engine.Compact(ctx))
+	assert.NoError(t, engine.Update(tenantA, "d", "4"))
+	assert.NoError(t, engine.ClearNamespace(ctx, "tenant-x"))
+	assert.NoError(t, engine.Close())
+
+	engine, err = NewFileEngine[string](dir, nil)
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	for _, prompt := range []string{"a", "d"} {
+		_, ok := engine.Lookup(tenantA, prompt)
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(ctx, prompt)
+		assert.False(t, ok)
+	}
+
+	_, ok := engine.Lookup(tenantA, "b")
+	assert.False(t, ok)
+
+	_, ok = engine.Lookup(ctx, "c")
+	assert.True(t, ok)
+	assert.Equal(t, 2, engine.NamespaceStats("tenant-a").Entries)
+
+	assert.NoError(t, engine.ClearNamespace(ctx, "tenant-a"))
+	assert.NoError(t, engine.Close())
+
+	engine, err = NewFileEngine[string](dir, nil)
+	assert.NoError(t, err)
+	defer engine.Close()
+
+	_, ok = engine.Lookup(tenantA, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, engine.Len())
+}
+
+func TestLLMCacheNamespace(t *testing.T) {
+	ctx := context.TODO()
+
+	engine, err := NewLRUEngine[string]()
+	assert.NoError(t, err)
+
+	cache := New[string](engine)
+	tenantA, tenantB := cache.Namespace("tenant-a"), cache.Namespace("tenant-b")
+	assert.Equal(t, "tenant-a", tenantA.Name())
+
+	assert.NoError(t, tenantA.Update(ctx, "prompt", "result"))
+
+	_, ok := tenantB.Lookup(ctx, "prompt")
+	assert.False(t, ok)
+
+	// The view and the context address the same namespace
+	match, ok := cache.LookupMatch(WithNamespace(ctx, "tenant-a"), "prompt")
+	assert.True(t, ok)
+	assert.Equal(t, "prompt", match.Prompt)
+
+	stats, err := tenantA.Stats()
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{Lookups: 1, Hits: 1, Updates: 1, Entries: 1}, stats)
+
+	assert.NoError(t, tenantA.Clear(ctx))
+
+	_, ok = tenantA.Lookup(ctx, "prompt")
+	assert.False(t, ok)
+
+	// Engines without namespaces report an error
+	unsupported := New[string](lookupOnly[string]{engine})
+	assert.ErrorIs(t, unsupported.ClearNamespace(ctx, "tenant-a"), ErrNamespacesNotSupported)
+
+	_, err = unsupported.Namespace("tenant-a").Stats()
+	assert.ErrorIs(t, err, ErrNamespacesNotSupported)
+}
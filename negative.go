@@ -0,0 +1,148 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// ErrCachedFailure is matched by the errors returned by GetOrCompute for cached failures.
+var ErrCachedFailure = errors.New("cached failure")
+
+// ComputeFunc computes the result of a prompt, e.g. by calling the model.
+// It receives the prompt passed to GetOrCompute, not the normalized prompt.
+type ComputeFunc[T comparable] func(ctx context.Context, prompt string) (T, error)
+
+// CachedError is a failure of a ComputeFunc replayed from the negative cache. Only the class
+// and the message of the original error are retained.
+type CachedError struct {
+	// Class is the class of the original error, see NegativeCacheOptions.Classify.
+	Class string
+	// Message is the message of the original error.
+	Message string
+	// ExpiresAt is the time the failure expires.
+	ExpiresAt time.Time
+}
+
+// Error returns the class and the message of the original error.
+func (e *CachedError) Error() string {
+	return fmt.Sprintf("%s: %s: %s", ErrCachedFailure, e.Class, e.Message)
+}
+
+// Is reports whether the target is ErrCachedFailure.
+func (e *CachedError) Is(target error) bool {
+	return target == ErrCachedFailure
+}
+
+// ErrorClassifier is implemented by errors which report their class to the negative cache,
+// e.g. "refusal" or "rate_limit".
+type ErrorClassifier interface {
+	// ErrorClass returns the class of the error.
+	ErrorClass() string
+}
+
+// NegativeCacheOptions contains options for configuring the caching of failures.
+type NegativeCacheOptions struct {
+	// TTL is the time a failure is cached. Default is 30 seconds.
+	TTL time.Duration
+	// MaxEntries is the maximum number of cached failures. Default is 1000.
+	MaxEntries int
+	// Cacheable reports whether a failure is cached. Default caches all errors except
+	// context cancellations and deadlines, which say nothing about the prompt.
+	Cacheable func(err error) bool
+	// Classify returns the class of a failure. Default is the class reported by an ErrorClassifier
+	// in the error chain, or the type of the error.
+	Classify func(err error) string
+}
+
+// negativeCache caches failures of computations by namespaced key.
+type negativeCache struct {
+	// opts contains options for configuring the negative cache.
+	opts NegativeCacheOptions
+	// failures holds the cached failures by namespaced key.
+	failures *lru.Cache[string, *CachedError]
+	// now returns the current time.
+	now func() time.Time
+}
+
+// newNegativeCache creates a new negativeCache instance with the provided options.
+func newNegativeCache(opts NegativeCacheOptions) *negativeCache {
+	if opts.TTL <= 0 {
+		opts.TTL = 30 * time.Second
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 1000
+	}
+
+	if opts.Cacheable == nil {
+		opts.Cacheable = func(err error) bool {
+			return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+		}
+	}
+
+	if opts.Classify == nil {
+		opts.Classify = classifyError
+	}
+
+	// The size is always positive, so creating the cache cannot fail
+	failures, _ := lru.New[string, *CachedError](opts.MaxEntries)
+
+	return &negativeCache{
+		opts:     opts,
+		failures: failures,
+		now:      time.Now,
+	}
+}
+
+// get returns the cached failure of the key, or nil if there is none or it expired.
+func (n *negativeCache) get(key string) *CachedError {
+	failure, ok := n.failures.Get(key)
+	if !ok {
+		return nil
+	}
+
+	if !n.now().Before(failure.ExpiresAt) {
+		n.failures.Remove(key)
+		return nil
+	}
+
+	return failure
+}
+
+// add caches the failure of the key if it is cacheable.
+func (n *negativeCache) add(key string, err error) {
+	if !n.opts.Cacheable(err) {
+		return
+	}
+
+	n.failures.Add(key, &CachedError{
+		Class:     n.opts.Classify(err),
+		Message:   err.Error(),
+		ExpiresAt: n.now().Add(n.opts.TTL),
+	})
+}
+
+// remove removes the cached failure of the key.
+func (n *negativeCache) remove(key string) {
+	n.failures.Remove(key)
+}
+
+// clearNamespace removes the cached failures of the namespace.
+func (n *negativeCache) clearNamespace(namespace string) {
+	clearNamespace(namespace, n.failures.Keys(), n.remove)
+}
+
+// classifyError returns the class reported by an ErrorClassifier in the error chain,
+// or the type of the error.
+func classifyError(err error) string {
+	var classifier ErrorClassifier
+	if errors.As(err, &classifier) {
+		return classifier.ErrorClass()
+	}
+
+	return fmt.Sprintf("%T", err)
+}
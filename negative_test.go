@@ -0,0 +1,193 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// refusalError is a test error reporting its class.
+type refusalError struct{}
+
+func (refusalError) Error() string      { return "the model declined to answer" }
+func (refusalError) ErrorClass() string { return "refusal" }
+
+func TestLLMCache_GetOrCompute(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Read Through", func(t *testing.T) {
+		engine := &mockEngine[string]{cache: map[string]string{}}
+		cache := New[string](engine)
+
+		calls := 0
+		compute := func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "answer to " + prompt, nil
+		}
+
+		for i := 0; i < 2; i++ {
+			result, err := cache.GetOrCompute(ctx, "prompt1", compute)
+			assert.NoError(t, err)
+			assert.Equal(t, "answer to prompt1", result)
+		}
+
+		assert.Equal(t, 1, calls)
+		assert.Equal(t, map[string]string{"prompt1": "answer to prompt1"}, engine.cache)
+
+		// Failures are not cached without a negative cache
+		failing := func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "", refusalError{}
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetOrCompute(ctx, "prompt2", failing)
+			assert.Equal(t, refusalError{}, err)
+		}
+
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Negative Cache", func(t *testing.T) {
+		engine := &mockEngine[string]{cache: map[string]string{}}
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.NegativeCache = &NegativeCacheOptions{TTL: time.Minute}
+		})
+
+		now := time.Now()
+		cache.failures.now = func() time.Time { return now }
+
+		calls := 0
+		failing := func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "", refusalError{}
+		}
+
+		_, err := cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.Equal(t, refusalError{}, err)
+
+		_, err = cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.ErrorIs(t, err, ErrCachedFailure)
+		assert.Equal(t, 1, calls)
+
+		var cached *CachedError
+		assert.True(t, errors.As(err, &cached))
+		assert.Equal(t, &CachedError{
+			Class:     "refusal",
+			Message:   "the model declined to answer",
+			ExpiresAt: now.Add(time.Minute),
+		}, cached)
+
+		// Failures are cached per namespace
+		_, err = cache.Namespace("tenant").GetOrCompute(ctx, "prompt1", failing)
+		assert.Equal(t, refusalError{}, err)
+		assert.Equal(t, 2, calls)
+
+		// Expired failures are recomputed
+		now = now.Add(time.Minute)
+
+		_, err = cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.Equal(t, refusalError{}, err)
+		assert.Equal(t, 3, calls)
+
+		// An update replaces the cached failure
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+
+		result, err := cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.NoError(t, err)
+		assert.Equal(t, "result1", result)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Cacheable", func(t *testing.T) {
+		cache := New[string](&mockEngine[string]{cache: map[string]string{}}, func(o *LLMCacheOptions) {
+			o.NegativeCache = &NegativeCacheOptions{
+				Cacheable: func(err error) bool {
+					var refusal refusalError
+					return errors.As(err, &refusal)
+				},
+			}
+		})
+
+		calls := 0
+		compute := func(err error) ComputeFunc[string] {
+			return func(ctx context.Context, prompt string) (string, error) {
+				calls++
+				return "", err
+			}
+		}
+
+		unavailable := errors.New("service unavailable")
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetOrCompute(ctx, "prompt1", compute(unavailable))
+			assert.Equal(t, unavailable, err)
+		}
+
+		assert.Equal(t, 2, calls)
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetOrCompute(ctx, "prompt2", compute(refusalError{}))
+			assert.Error(t, err)
+		}
+
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("Default Cacheable", func(t *testing.T) {
+		cache := New[string](&mockEngine[string]{cache: map[string]string{}}, func(o *LLMCacheOptions) {
+			o.NegativeCache = &NegativeCacheOptions{}
+		})
+
+		calls := 0
+		canceled := func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "", context.Canceled
+		}
+
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetOrCompute(ctx, "prompt1", canceled)
+			assert.ErrorIs(t, err, context.Canceled)
+		}
+
+		assert.Equal(t, 2, calls)
+
+		_, _ = cache.GetOrCompute(ctx, "prompt2", func(ctx context.Context, prompt string) (string, error) {
+			return "", errors.New("bad request")
+		})
+
+		var cached *CachedError
+		_, err := cache.GetOrCompute(ctx, "prompt2", canceled)
+		assert.True(t, errors.As(err, &cached))
+		assert.Equal(t, "*errors.errorString", cached.Class)
+		assert.Equal(t, "cached failure: *errors.errorString: bad request", err.Error())
+	})
+
+	t.Run("Clear Namespace", func(t *testing.T) {
+		engine, err := NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.NegativeCache = &NegativeCacheOptions{}
+		})
+
+		calls := 0
+		failing := func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "", refusalError{}
+		}
+
+		tenant := cache.Namespace("tenant")
+
+		_, _ = tenant.GetOrCompute(ctx, "prompt1", failing)
+		_, _ = cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.NoError(t, tenant.Clear(ctx))
+
+		_, _ = tenant.GetOrCompute(ctx, "prompt1", failing)
+		_, _ = cache.GetOrCompute(ctx, "prompt1", failing)
+		assert.Equal(t, 3, calls)
+	})
+}
@@ -0,0 +1,158 @@
+package llmcache
+
+import (
+	"regexp"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Compile time check to ensure WhitespaceNormalizer satisfies the PromptNormalizer interface.
+var _ PromptNormalizer = WhitespaceNormalizer{}
+
+// Compile time check to ensure CaseFoldNormalizer satisfies the PromptNormalizer interface.
+var _ PromptNormalizer = CaseFoldNormalizer{}
+
+// Compile time check to ensure UnicodeNormalizer satisfies the PromptNormalizer interface.
+var _ PromptNormalizer = UnicodeNormalizer{}
+
+// Compile time check to ensure PIIMasker satisfies the PromptNormalizer interface.
+var _ PromptNormalizer = (*PIIMasker)(nil)
+
+// PromptNormalizer transforms prompts before they become cache keys and are embedded,
+// e.g. to fold insignificant differences or to mask personal data.
+type PromptNormalizer interface {
+	// Normalize returns the normalized prompt.
+	Normalize(prompt string) string
+}
+
+// PromptNormalizerFunc is an adapter to use an ordinary function as PromptNormalizer.
+type PromptNormalizerFunc func(prompt string) string
+
+// Normalize calls f(prompt).
+func (f PromptNormalizerFunc) Normalize(prompt string) string {
+	return f(prompt)
+}
+
+// normalizerPipeline applies normalizers in order.
+type normalizerPipeline []PromptNormalizer
+
+// NormalizerPipeline returns a PromptNormalizer applying the normalizers in order.
+func NormalizerPipeline(normalizers ...PromptNormalizer) PromptNormalizer {
+	return normalizerPipeline(normalizers)
+}
+
+// Normalize applies the normalizers of the pipeline in order.
+func (p normalizerPipeline) Normalize(prompt string) string {
+	for _, n := range p {
+		prompt = n.Normalize(prompt)
+	}
+
+	return prompt
+}
+
+// WhitespaceNormalizer trims the prompt and collapses runs of whitespace into a single space.
+type WhitespaceNormalizer struct{}
+
+// Normalize trims the prompt and collapses runs of whitespace.
+func (WhitespaceNormalizer) Normalize(prompt string) string {
+	return strings.Join(strings.FieldsFunc(prompt, unicode.IsSpace), " ")
+}
+
+// CaseFoldNormalizer folds the case of the prompt with Unicode full case folding,
+// so that prompts differing only in case share an entry.
+type CaseFoldNormalizer struct{}
+
+// Normalize folds the case of the prompt.
+func (CaseFoldNormalizer) Normalize(prompt string) string {
+	return cases.Fold().String(prompt)
+}
+
+// UnicodeNormalizer converts the prompt into a Unicode normalization form, so that
+// canonically equivalent prompts share an entry.
+type UnicodeNormalizer struct {
+	// Form is the normalization form. The zero value is norm.NFC; norm.NFKC additionally
+	// folds compatibility characters, e.g. ligatures and full-width letters.
+	Form norm.Form
+}
+
+// Normalize converts the prompt into the normalization form.
+func (n UnicodeNormalizer) Normalize(prompt string) string {
+	return n.Form.String(prompt)
+}
+
+// PIIRule masks a kind of personal data.
+type PIIRule struct {
+	// Name is the name of the kind of data, e.g. email.
+	Name string
+	// Pattern matches the data.
+	Pattern *regexp.Regexp
+	// Replacement replaces the matches, see regexp.Regexp.ReplaceAllString.
+	Replacement string
+}
+
+// DefaultPIIRules returns the rules masking email addresses, payment card numbers,
+// phone numbers and IPv4 addresses. The rules are applied in this order.
+func DefaultPIIRules() []PIIRule {
+	return []PIIRule{
+		{
+			Name:        "email",
+			Pattern:     regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+			Replacement: "<EMAIL>",
+		},
+		{
+			Name:        "card",
+			Pattern:     regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+			Replacement: "<CARD>",
+		},
+		{
+			Name:        "phone",
+			Pattern:     regexp.MustCompile(`(?:\+\d{1,3}[\s.-]?)?\(?\b\d{3}\)?[\s.-]?\d{3}[\s.-]?\d{4}\b`),
+			Replacement: "<PHONE>",
+		},
+		{
+			Name:        "ipv4",
+			Pattern:     regexp.MustCompile(`\b(?:\d{1,3}\.){3}\d{1,3}\b`),
+			Replacement: "<IP>",
+		},
+	}
+}
+
+// PIIMaskerOptions contains options for configuring the PIIMasker.
+type PIIMaskerOptions struct {
+	// Rules are the rules applied in order. Default is DefaultPIIRules.
+	Rules []PIIRule
+}
+
+// PIIMasker replaces personal data in prompts with placeholders, so that it is neither stored
+// in the cache nor sent to the embedder. Prompts differing only in the masked data share an entry.
+type PIIMasker struct {
+	// opts contains options for configuring the PIIMasker.
+	opts PIIMaskerOptions
+}
+
+// NewPIIMasker creates a new PIIMasker instance with the provided options.
+func NewPIIMasker(optFns ...func(o *PIIMaskerOptions)) *PIIMasker {
+	opts := PIIMaskerOptions{
+		Rules: DefaultPIIRules(),
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	return &PIIMasker{
+		opts: opts,
+	}
+}
+
+// Normalize replaces the matches of the rules in the prompt.
+func (m *PIIMasker) Normalize(prompt string) string {
+	for _, rule := range m.opts.Rules {
+		prompt = rule.Pattern.ReplaceAllString(prompt, rule.Replacement)
+	}
+
+	return prompt
+}
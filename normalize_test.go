@@ -0,0 +1,71 @@
+package llmcache
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/unicode/norm"
+)
+
+func TestWhitespaceNormalizer(t *testing.T) {
+	assert.Equal(t, "What is Go?", WhitespaceNormalizer{}.Normalize("  What \t is\n\nGo? "))
+	assert.Equal(t, "", WhitespaceNormalizer{}.Normalize(" \n "))
+}
+
+func TestCaseFoldNormalizer(t *testing.T) {
+	assert.Equal(t, "what is go?", CaseFoldNormalizer{}.Normalize("What is GO?"))
+	assert.Equal(t, "strasse", CaseFoldNormalizer{}.Normalize("STRAßE"))
+}
+
+func TestUnicodeNormalizer(t *testing.T) {
+	// e followed by a combining acute accent
+	assert.Equal(t, "caf\u00e9", UnicodeNormalizer{}.Normalize("cafe\u0301"))
+	assert.Equal(t, "file Go", UnicodeNormalizer{Form: norm.NFKC}.Normalize("ﬁle Ｇｏ"))
+}
+
+func TestPIIMasker(t *testing.T) {
+	masker := NewPIIMasker()
+
+	testCases := []struct {
+		name     string
+		prompt   string
+		expected string
+	}{
+		{"Email", "Contact jane.doe+support@example.co.uk please", "Contact <EMAIL> please"},
+		{"Card", "My card 4111 1111 1111 1111 was declined", "My card <CARD> was declined"},
+		{"Phone", "Call +1 (555) 123-4567 or 555.987.6543", "Call <PHONE> or <PHONE>"},
+		{"IPv4", "Ping 192.168.0.1 now", "Ping <IP> now"},
+		{"Dates And Numbers", "What happened on 2024-01-01 at 10:30 to 42 people?", "What happened on 2024-01-01 at 10:30 to 42 people?"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, masker.Normalize(tc.prompt))
+		})
+	}
+
+	t.Run("Custom Rules", func(t *testing.T) {
+		masker := NewPIIMasker(func(o *PIIMaskerOptions) {
+			o.Rules = append(DefaultPIIRules(), PIIRule{
+				Name:        "customer",
+				Pattern:     regexp.MustCompile(`\bCUST-\d+\b`),
+				Replacement: "<CUSTOMER>",
+			})
+		})
+
+		assert.Equal(t, "Orders of <CUSTOMER> for <EMAIL>", masker.Normalize("Orders of CUST-1234 for a@b.io"))
+	})
+}
+
+func TestNormalizerPipeline(t *testing.T) {
+	pipeline := NormalizerPipeline(
+		UnicodeNormalizer{Form: norm.NFKC},
+		WhitespaceNormalizer{},
+		CaseFoldNormalizer{},
+		PromptNormalizerFunc(func(prompt string) string { return prompt + "!" }),
+	)
+
+	assert.Equal(t, "hello world!", pipeline.Normalize("  Hello　WORLD "))
+	assert.Equal(t, "prompt", NormalizerPipeline().Normalize("prompt"))
+}
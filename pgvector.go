@@ -0,0 +1,380 @@
+package llmcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Compile time check to ensure PGVectorEngine satisfies the Engine interface.
+var _ Engine[any] = (*PGVectorEngine[any])(nil)
+
+// Compile time check to ensure PGVectorEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*PGVectorEngine[any])(nil)
+
+// Compile time check to ensure PGVectorEngine satisfies the Deleter interface.
+var _ Deleter = (*PGVectorEngine[any])(nil)
+
+// SQLDB is the SQL layer of the PGVectorEngine. NewSQLDB adapts a *sql.DB.
+type SQLDB interface {
+	// ExecContext executes a statement without returning rows.
+	ExecContext(ctx context.Context, query string, args ...any) error
+	// QueryRowContext executes a query returning at most one row.
+	QueryRowContext(ctx context.Context, query string, args ...any) SQLRow
+}
+
+// SQLRow is a row returned by SQLDB. Scan returns sql.ErrNoRows if the query returned no row.
+type SQLRow interface {
+	// Scan copies the columns of the row into the values pointed at by dest.
+	Scan(dest ...any) error
+}
+
+// NewSQLDB adapts a *sql.DB, e.g. opened with the pgx or lib/pq driver, to the SQLDB interface.
+func NewSQLDB(db *sql.DB) SQLDB {
+	return sqlDB{db}
+}
+
+// sqlDB adapts a *sql.DB to the SQLDB interface.
+type sqlDB struct {
+	db *sql.DB
+}
+
+func (db sqlDB) ExecContext(ctx context.Context, query string, args ...any) error {
+	_, err := db.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (db sqlDB) QueryRowContext(ctx context.Context, query string, args ...any) SQLRow {
+	return db.db.QueryRowContext(ctx, query, args...)
+}
+
+// PGVectorIndex is the kind of the vector index of a PGVectorEngine.
+type PGVectorIndex string
+
+const (
+	// PGVectorIndexHNSW is a hierarchical navigable small world graph index.
+	PGVectorIndexHNSW PGVectorIndex = "hnsw"
+	// PGVectorIndexIVFFlat is an inverted file index. It should be created after the table holds data.
+	PGVectorIndexIVFFlat PGVectorIndex = "ivfflat"
+	// PGVectorIndexNone disables the vector index, so that lookups scan the table.
+	PGVectorIndexNone PGVectorIndex = "none"
+)
+
+// PGVectorEngineOptions contains options for configuring the PGVectorEngine.
+type PGVectorEngineOptions[T any] struct {
+	// Table is the name of the table. Default is "llmcache".
+	Table string
+	// Dimension is the dimension of the embeddings. It is required if an embedder is given.
+	Dimension int
+	// DistanceFunc selects the pgvector operator: CosineDistance (<=>), Euclidean and SquaredL2 (<->),
+	// NegativeInnerProduct (<#>) or Manhattan (<+>). Default is CosineDistance.
+	DistanceFunc DistanceFunc
+	// Threshold is the maximum distance allowed for a result to be considered a match. Default is 0.2.
+	Threshold float32
+	// Index is the kind of the vector index. Default is PGVectorIndexHNSW.
+	Index PGVectorIndex
+	// TTL is the time to live of the entries. Default is 0, entries do not expire.
+	TTL time.Duration
+	// Codec serializes the results. Default is JSONCodec.
+	Codec Codec[T]
+	// SkipMigration skips the creation of the extension, the table and the indexes.
+	SkipMigration bool
+}
+
+// pgOperator is the pgvector operator of a distance function.
+type pgOperator struct {
+	// operator is the distance operator.
+	operator string
+	// opclass is the operator class of the index.
+	opclass string
+	// squared indicates that the distance of the operator is squared.
+	squared bool
+}
+
+// PGVectorEngine is a cache engine implementation which stores the results and embeddings
+// in PostgreSQL with the pgvector extension. Exact lookups use the SHA-256 hash of the prompt,
+// semantic lookups the distance operator of the configured distance function.
+type PGVectorEngine[T comparable] struct {
+	// db is the SQL layer.
+	db SQLDB
+	// embedder embeds the prompts, or is nil if only exact matches are served.
+	embedder Embedder
+	// opts contains options for configuring the PGVectorEngine.
+	opts PGVectorEngineOptions[T]
+	// op is the pgvector operator of the distance function.
+	op pgOperator
+	// now returns the current time.
+	now func() time.Time
+}
+
+// tableName matches valid table names.
+var tableName = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// NewPGVectorEngine creates a new PGVectorEngine instance and creates the extension, the table and
+// the indexes if they do not exist. If the embedder is nil, only exact matches are served.
+func NewPGVectorEngine[T comparable](ctx context.Context, db SQLDB, embedder Embedder, optFns ...func(o *PGVectorEngineOptions[T])) (*PGVectorEngine[T], error) {
+	opts := PGVectorEngineOptions[T]{
+		Table:        "llmcache",
+		DistanceFunc: CosineDistance,
+		Threshold:    0.2,
+		Index:        PGVectorIndexHNSW,
+		Codec:        JSONCodec[T]{},
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if !tableName.MatchString(opts.Table) {
+		return nil, fmt.Errorf("invalid table name %q", opts.Table)
+	}
+
+	if opts.Codec == nil {
+		return nil, errors.New("codec is required")
+	}
+
+	if embedder != nil && opts.Dimension <= 0 {
+		return nil, errors.New("dimension is required")
+	}
+
+	op, err := pgOperatorOf(opts.DistanceFunc)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &PGVectorEngine[T]{
+		db:       db,
+		embedder: embedder,
+		opts:     opts,
+		op:       op,
+		now:      time.Now,
+	}
+
+	if !opts.SkipMigration {
+		if err := e.migrate(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// pgOperatorOf returns the pgvector operator of a distance function.
+func pgOperatorOf(fn DistanceFunc) (pgOperator, error) {
+	switch metricOf(fn) {
+	case metricCosine:
+		return pgOperator{operator: "<=>", opclass: "vector_cosine_ops"}, nil
+	case metricEuclidean:
+		return pgOperator{operator: "<->", opclass: "vector_l2_ops"}, nil
+	case metricSquaredL2:
+		return pgOperator{operator: "<->", opclass: "vector_l2_ops", squared: true}, nil
+	case metricNegativeInnerProduct:
+		return pgOperator{operator: "<#>", opclass: "vector_ip_ops"}, nil
+	case metricHamming, metricOther:
+	}
+
+	if fn != nil && reflect.ValueOf(fn).Pointer() == reflect.ValueOf(Manhattan).Pointer() {
+		return pgOperator{operator: "<+>", opclass: "vector_l1_ops"}, nil
+	}
+
+	return pgOperator{}, errors.New("distance function is not supported by pgvector")
+}
+
+// migrate creates the extension, the table and the indexes if they do not exist.
+func (e *PGVectorEngine[T]) migrate(ctx context.Context) error {
+	t := e.opts.Table
+	dim := ""
+
+	if e.opts.Dimension > 0 {
+		dim = fmt.Sprintf("(%d)", e.opts.Dimension)
+	}
+
+	statements := []string{
+		"CREATE EXTENSION IF NOT EXISTS vector",
+		fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s (
+	prompt_hash BYTEA PRIMARY KEY,
+	namespace TEXT NOT NULL DEFAULT '',
+	prompt TEXT NOT NULL,
+	result BYTEA NOT NULL,
+	embedding vector%s,
+	expires_at TIMESTAMPTZ
+)`, t, dim),
+		fmt.Sprintf("ALTER TABLE %s ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''", t),
+		fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_expires_at_idx ON %s (expires_at)", t, t),
+	}
+
+	if e.embedder != nil && e.opts.Index != PGVectorIndexNone {
+		statements = append(statements, fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s_embedding_idx ON %s USING %s (embedding %s)",
+			t, t, e.opts.Index, e.op.opclass))
+	}
+
+	for _, statement := range statements {
+		if err := e.db.ExecContext(ctx, statement); err != nil {
+			return fmt.Errorf("migrate: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Lookup retrieves the cached result associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (e *PGVectorEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	match, ok := e.LookupMatch(ctx, prompt)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the result of the prompt, or the closest cached entry within the threshold
+// distance if the prompt is not cached. Expired entries and entries of other namespaces are ignored.
+//
+// pgvector applies the namespace and expiry filters to the candidates of the vector index after the
+// scan, so a semantic lookup may miss the entries of a small namespace. Enable iterative index scans
+// (pgvector 0.8 or later) or raise hnsw.ef_search or ivfflat.probes for the connections of the database.
+func (e *PGVectorEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	now := e.now()
+	namespace := NamespaceFromContext(ctx)
+
+	var data []byte
+
+	err := e.db.QueryRowContext(ctx,
+		fmt.Sprintf("SELECT result FROM %s WHERE prompt_hash = $1 AND (expires_at IS NULL OR expires_at > $2) AND namespace = $3", e.opts.Table),
+		promptHash(namespace, prompt), now, namespace).Scan(&data)
+	if err == nil {
+		result, err := e.opts.Codec.Decode(data)
+		if err != nil {
+			return Match[T]{}, false
+		}
+
+		return Match[T]{Result: result, Prompt: prompt, Exact: true}, true
+	}
+
+	if !errors.Is(err, sql.ErrNoRows) || e.embedder == nil {
+		return Match[T]{}, false
+	}
+
+	embedding, err := e.embedder.EmbedText(ctx, prompt)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	var (
+		matched  string
+		distance float64
+	)
+
+	err = e.db.QueryRowContext(ctx,
+		fmt.Sprintf(`SELECT prompt, result, embedding %[2]s $1::vector AS distance FROM %[1]s
+WHERE namespace = $3 AND embedding IS NOT NULL AND (expires_at IS NULL OR expires_at > $2)
+ORDER BY embedding %[2]s $1::vector LIMIT 1`, e.opts.Table, e.op.operator),
+		formatVector(embedding), now, namespace).Scan(&matched, &data, &distance)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	if e.op.squared {
+		distance *= distance
+	}
+
+	if float32(distance) > e.opts.Threshold {
+		return Match[T]{}, false
+	}
+
+	result, err := e.opts.Codec.Decode(data)
+	if err != nil {
+		return Match[T]{}, false
+	}
+
+	return Match[T]{Result: result, Prompt: matched, Distance: float32(distance)}, true
+}
+
+// Update inserts or replaces the entry of the prompt.
+// It returns an error if the prompt cannot be embedded or the statement fails.
+func (e *PGVectorEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	data, err := e.opts.Codec.Encode(result)
+	if err != nil {
+		return fmt.Errorf("encode result: %w", err)
+	}
+
+	var embedding any
+
+	if e.embedder != nil {
+		v, err := e.embedder.EmbedText(ctx, prompt)
+		if err != nil {
+			return err
+		}
+
+		if len(v) != e.opts.Dimension {
+			return fmt.Errorf("%w: expected embedding dimension %d, got %d", ErrVectorSizeMismatch, e.opts.Dimension, len(v))
+		}
+
+		embedding = formatVector(v)
+	}
+
+	var expiresAt any
+	if e.opts.TTL > 0 {
+		expiresAt = e.now().Add(e.opts.TTL)
+	}
+
+	namespace := NamespaceFromContext(ctx)
+
+	return e.db.ExecContext(ctx, fmt.Sprintf(`INSERT INTO %s (prompt_hash, prompt, result, embedding, expires_at, namespace)
+VALUES ($1, $2, $3, $4::vector, $5, $6)
+ON CONFLICT (prompt_hash) DO UPDATE SET result = EXCLUDED.result, embedding = EXCLUDED.embedding, expires_at = EXCLUDED.expires_at`,
+		e.opts.Table), promptHash(namespace, prompt), prompt, data, embedding, expiresAt, namespace)
+}
+
+// Delete removes the entry of the given prompt.
+func (e *PGVectorEngine[T]) Delete(ctx context.Context, prompt string) error {
+	namespace := NamespaceFromContext(ctx)
+
+	return e.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE prompt_hash = $1 AND namespace = $2", e.opts.Table),
+		promptHash(namespace, prompt), namespace)
+}
+
+// ClearNamespace removes all entries of the namespace.
+func (e *PGVectorEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	return e.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE namespace = $1", e.opts.Table), namespace)
+}
+
+// DeleteExpired removes the expired entries, which are ignored by lookups but remain in the table until deleted.
+func (e *PGVectorEngine[T]) DeleteExpired(ctx context.Context) error {
+	return e.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s WHERE expires_at <= $1", e.opts.Table), e.now())
+}
+
+// Clear clears the cache, removing all entries.
+// It returns an error if the clear operation fails.
+func (e *PGVectorEngine[T]) Clear(ctx context.Context) error {
+	return e.db.ExecContext(ctx, fmt.Sprintf("DELETE FROM %s", e.opts.Table))
+}
+
+// promptHash returns the SHA-256 hash of a prompt qualified with its namespace, see namespaceKey.
+func promptHash(namespace, prompt string) []byte {
+	hash := sha256.Sum256([]byte(namespaceKey(namespace, prompt)))
+	return hash[:]
+}
+
+// formatVector formats a vector in the text representation of pgvector, e.g. [1,2,3].
+func formatVector(v []float32) string {
+	var sb strings.Builder
+
+	sb.WriteByte('[')
+
+	for i, x := range v {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+
+		sb.WriteString(strconv.FormatFloat(float64(x), 'g', -1, 32))
+	}
+
+	sb.WriteByte(']')
+
+	return sb.String()
+}
@@ -0,0 +1,413 @@
+package llmcache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRow is a row of fakeSQL.
+type fakeRow struct {
+	namespace string
+	prompt    string
+	result    []byte
+	embedding []float32
+	expiresAt *time.Time
+}
+
+// fakeSQL is an in-memory stand-in for PostgreSQL, which interprets the statements of the PGVectorEngine.
+type fakeSQL struct {
+	mu         sync.Mutex
+	statements []string
+	args       [][]any
+	rows       map[string]fakeRow
+}
+
+func newFakeSQL() *fakeSQL {
+	return &fakeSQL{rows: make(map[string]fakeRow)}
+}
+
+func (db *fakeSQL) ExecContext(ctx context.Context, query string, args ...any) error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.statements = append(db.statements, query)
+
+	switch {
+	case strings.HasPrefix(query, "CREATE"), strings.HasPrefix(query, "ALTER"):
+	case strings.HasPrefix(query, "INSERT"):
+		row := fakeRow{prompt: args[1].(string), result: args[2].([]byte), namespace: args[5].(string)}
+
+		if v, ok := args[3].(string); ok {
+			row.embedding = parseVector(v)
+		}
+
+		if t, ok := args[4].(time.Time); ok {
+			row.expiresAt = &t
+		}
+
+		db.rows[string(args[0].([]byte))] = row
+	case strings.Contains(query, "WHERE prompt_hash = $1"):
+		delete(db.rows, string(args[0].([]byte)))
+	case strings.Contains(query, "WHERE namespace = $1"):
+		for k, row := range db.rows {
+			if row.namespace == args[0].(string) {
+				delete(db.rows, k)
+			}
+		}
+	case strings.Contains(query, "WHERE expires_at <= $1"):
+		for k, row := range db.rows {
+			if row.expiresAt != nil && !row.expiresAt.After(args[0].(time.Time)) {
+				delete(db.rows, k)
+			}
+		}
+	case strings.HasPrefix(query, "DELETE"):
+		clear(db.rows)
+	default:
+		return errors.New("unexpected statement")
+	}
+
+	return nil
+}
+
+func (db *fakeSQL) QueryRowContext(ctx context.Context, query string, args ...any) SQLRow {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	db.statements = append(db.statements, query)
+	db.args = append(db.args, args)
+
+	now := args[1].(time.Time)
+	live := func(row fakeRow) bool {
+		return row.expiresAt == nil || row.expiresAt.After(now)
+	}
+
+	if strings.HasPrefix(query, "SELECT result") {
+		row, ok := db.rows[string(args[0].([]byte))]
+		if !ok || !live(row) || row.namespace != args[2].(string) {
+			return fakeScanner{err: sql.ErrNoRows}
+		}
+
+		return fakeScanner{values: []any{row.result}}
+	}
+
+	var distance DistanceFunc
+
+	switch {
+	case strings.Contains(query, "<=>"):
+		distance = CosineDistance
+	case strings.Contains(query, "<->"):
+		distance = Euclidean
+	case strings.Contains(query, "<#>"):
+		distance = NegativeInnerProduct
+	default:
+		return fakeScanner{err: errors.New("unexpected operator")}
+	}
+
+	query32 := parseVector(args[0].(string))
+
+	var (
+		best  *fakeRow
+		bestD = math.Inf(1)
+	)
+
+	for _, row := range db.rows {
+		if row.embedding == nil || !live(row) || row.namespace != args[2].(string) {
+			continue
+		}
+
+		d, err := distance(row.embedding, query32)
+		if err != nil {
+			return fakeScanner{err: err}
+		}
+
+		if float64(d) < bestD {
+			row := row
+			best, bestD = &row, float64(d)
+		}
+	}
+
+	if best == nil {
+		return fakeScanner{err: sql.ErrNoRows}
+	}
+
+	return fakeScanner{values: []any{best.prompt, best.result, bestD}}
+}
+
+// fakeScanner is a row of fakeSQL.
+type fakeScanner struct {
+	values []any
+	err    error
+}
+
+func (s fakeScanner) Scan(dest ...any) error {
+	if s.err != nil {
+		return s.err
+	}
+
+	for i, d := range dest {
+		switch d := d.(type) {
+		case *string:
+			*d = s.values[i].(string)
+		case *[]byte:
+			*d = s.values[i].([]byte)
+		case *float64:
+			*d = s.values[i].(float64)
+		}
+	}
+
+	return nil
+}
+
+// parseVector parses the text representation of pgvector.
+func parseVector(s string) []float32 {
+	fields := strings.Split(strings.Trim(s, "[]"), ",")
+	v := make([]float32, len(fields))
+
+	for i, f := range fields {
+		x, _ := strconv.ParseFloat(f, 32)
+		v[i] = float32(x)
+	}
+
+	return v
+}
+
+func TestPGVectorEngine(t *testing.T) {
+	ctx := context.TODO()
+	embedder := NewHashEmbedder(func(o *HashEmbedderOptions) {
+		o.Dimension = 64
+	})
+
+	newEngine := func(t *testing.T, db *fakeSQL, optFns ...func(o *PGVectorEngineOptions[string])) *PGVectorEngine[string] {
+		t.Helper()
+
+		engine, err := NewPGVectorEngine[string](ctx, db, embedder, append([]func(o *PGVectorEngineOptions[string]){
+			func(o *PGVectorEngineOptions[string]) {
+				o.Dimension = 64
+				o.Threshold = 0.5
+			},
+		}, optFns...)...)
+		assert.NoError(t, err)
+
+		return engine
+	}
+
+	t.Run("Migration", func(t *testing.T) {
+		db := newFakeSQL()
+		newEngine(t, db)
+
+		assert.Len(t, db.statements, 5)
+		assert.Equal(t, "CREATE EXTENSION IF NOT EXISTS vector", db.statements[0])
+		assert.Contains(t, db.statements[1], "embedding vector(64)")
+		assert.Equal(t, "ALTER TABLE llmcache ADD COLUMN IF NOT EXISTS namespace TEXT NOT NULL DEFAULT ''", db.statements[2])
+		assert.Equal(t, "CREATE INDEX IF NOT EXISTS llmcache_embedding_idx ON llmcache USING hnsw (embedding vector_cosine_ops)", db.statements[4])
+
+		db = newFakeSQL()
+		newEngine(t, db, func(o *PGVectorEngineOptions[string]) {
+			o.Table = "answers"
+			o.DistanceFunc = NegativeInnerProduct
+			o.Index = PGVectorIndexIVFFlat
+		})
+		assert.Equal(t, "CREATE INDEX IF NOT EXISTS answers_embedding_idx ON answers USING ivfflat (embedding vector_ip_ops)", db.statements[4])
+
+		db = newFakeSQL()
+		newEngine(t, db, func(o *PGVectorEngineOptions[string]) {
+			o.SkipMigration = true
+		})
+		assert.Empty(t, db.statements)
+	})
+
+	t.Run("Lookup", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db)
+
+		err := engine.Update(ctx, "What year was Albert Einstein born?", "1879")
+		assert.NoError(t, err)
+
+		match, ok := engine.LookupMatch(ctx, "What year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, Match[string]{Result: "1879", Prompt: "What year was Albert Einstein born?", Exact: true}, match)
+
+		match, ok = engine.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, "1879", match.Result)
+		assert.Equal(t, "What year was Albert Einstein born?", match.Prompt)
+		assert.False(t, match.Exact)
+		assert.Contains(t, db.statements[len(db.statements)-1], "ORDER BY embedding <=> $1::vector LIMIT 1")
+
+		_, ok = engine.Lookup(ctx, "How do I bake sourdough bread?")
+		assert.False(t, ok)
+	})
+
+	t.Run("Squared L2", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db, func(o *PGVectorEngineOptions[string]) {
+			o.DistanceFunc = SquaredL2
+		})
+
+		assert.NoError(t, engine.Update(ctx, "What year was Albert Einstein born?", "1879"))
+
+		match, ok := engine.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		// The distance of the <-> operator is squared
+		expected, err := SquaredL2(mustEmbed(t, embedder, "What year was Albert Einstein born?"), mustEmbed(t, embedder, "In what year was Albert Einstein born?"))
+		assert.NoError(t, err)
+		assert.InDelta(t, expected, match.Distance, 1e-5)
+	})
+
+	t.Run("TTL", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db, func(o *PGVectorEngineOptions[string]) {
+			o.TTL = time.Minute
+		})
+
+		now := time.Now()
+		engine.now = func() time.Time { return now }
+
+		assert.NoError(t, engine.Update(ctx, "prompt", "result"))
+
+		_, ok := engine.Lookup(ctx, "prompt")
+		assert.True(t, ok)
+
+		now = now.Add(2 * time.Minute)
+
+		_, ok = engine.Lookup(ctx, "prompt")
+		assert.False(t, ok)
+
+		assert.NoError(t, engine.DeleteExpired(ctx))
+		assert.Empty(t, db.rows)
+	})
+
+	t.Run("Delete And Clear", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db)
+
+		assert.NoError(t, engine.Update(ctx, "a", "1"))
+		assert.NoError(t, engine.Update(ctx, "b", "2"))
+
+		assert.NoError(t, engine.Delete(ctx, "a"))
+		assert.Len(t, db.rows, 1)
+
+		assert.NoError(t, engine.Clear(ctx))
+		assert.Empty(t, db.rows)
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db)
+
+		tenantA := WithNamespace(ctx, "tenant-a")
+		tenantB := WithNamespace(ctx, "tenant-b")
+
+		assert.NoError(t, engine.Update(tenantA, "What year was Albert Einstein born?", "1879"))
+
+		_, ok := engine.Lookup(tenantB, "What year was Albert Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(tenantB, "In what year was Albert Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(tenantA, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		assert.NoError(t, engine.Update(tenantB, "What year was Albert Einstein born?", "1879"))
+
+		// Prompts cannot address the entries of another namespace
+		_, ok = engine.Lookup(ctx, "tenant-b\x00What year was Albert Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(ctx, namespaceKey("tenant-b", "What year was Albert Einstein born?"))
+		assert.False(t, ok)
+
+		assert.NoError(t, engine.ClearNamespace(ctx, "tenant-a"))
+
+		_, ok = engine.Lookup(tenantA, "What year was Albert Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = engine.Lookup(tenantB, "What year was Albert Einstein born?")
+		assert.True(t, ok)
+	})
+
+	t.Run("Semantic Lookup Filter", func(t *testing.T) {
+		db := newFakeSQL()
+		engine := newEngine(t, db)
+
+		now := time.Now()
+		engine.now = func() time.Time { return now }
+
+		tenant := WithNamespace(ctx, "tenant")
+
+		assert.NoError(t, engine.Update(tenant, "What year was Albert Einstein born?", "1879"))
+
+		_, ok := engine.Lookup(tenant, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		// The namespace and the expiry are filtered in the query of the vector index
+		query, args := db.statements[len(db.statements)-1], db.args[len(db.args)-1]
+		assert.Equal(t, `SELECT prompt, result, embedding <=> $1::vector AS distance FROM llmcache
+WHERE namespace = $3 AND embedding IS NOT NULL AND (expires_at IS NULL OR expires_at > $2)
+ORDER BY embedding <=> $1::vector LIMIT 1`, query)
+		assert.Equal(t, formatVector(mustEmbed(t, embedder, "In what year was Albert Einstein born?")), args[0])
+		assert.Equal(t, now, args[1])
+		assert.Equal(t, "tenant", args[2])
+	})
+
+	t.Run("Exact Only", func(t *testing.T) {
+		db := newFakeSQL()
+
+		engine, err := NewPGVectorEngine[string](ctx, db, nil)
+		assert.NoError(t, err)
+		assert.Len(t, db.statements, 4)
+
+		assert.NoError(t, engine.Update(ctx, "What year was Albert Einstein born?", "1879"))
+
+		_, ok := engine.Lookup(ctx, "What year was Albert Einstein born?")
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(ctx, "In what year was Albert Einstein born?")
+		assert.False(t, ok)
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		_, err := NewPGVectorEngine[string](ctx, newFakeSQL(), embedder)
+		assert.ErrorContains(t, err, "dimension is required")
+
+		_, err = NewPGVectorEngine[string](ctx, newFakeSQL(), nil, func(o *PGVectorEngineOptions[string]) {
+			o.Table = "llmcache; DROP TABLE users"
+		})
+		assert.ErrorContains(t, err, "invalid table name")
+
+		_, err = NewPGVectorEngine[string](ctx, newFakeSQL(), nil, func(o *PGVectorEngineOptions[string]) {
+			o.DistanceFunc = AngularDistance
+		})
+		assert.ErrorContains(t, err, "not supported")
+
+		engine := newEngine(t, newFakeSQL(), func(o *PGVectorEngineOptions[string]) {
+			o.Dimension = 3
+		})
+		assert.ErrorIs(t, engine.Update(ctx, "prompt", "result"), ErrVectorSizeMismatch)
+	})
+}
+
+func TestFormatVector(t *testing.T) {
+	assert.Equal(t, "[0.5,-1,0.1]", formatVector([]float32{0.5, -1, 0.1}))
+	assert.Equal(t, "[]", formatVector(nil))
+}
+
+func mustEmbed(t *testing.T, embedder Embedder, text string) []float32 {
+	t.Helper()
+
+	v, err := embedder.EmbedText(context.TODO(), text)
+	assert.NoError(t, err)
+
+	return v
+}
@@ -0,0 +1,542 @@
+// Package proxy provides a caching HTTP proxy for OpenAI-compatible APIs.
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/hupe1980/go-llmcache"
+)
+
+// Compile time check to ensure TextEmbedder satisfies the Embedder interface.
+var _ llmcache.Embedder = (*TextEmbedder)(nil)
+
+// HeaderCache is the response header reporting whether a response was served from the cache.
+const HeaderCache = "X-Cache"
+
+// Values of the X-Cache header.
+const (
+	// CacheHit marks a response cached for the same prompt.
+	CacheHit = "HIT"
+	// CacheSemantic marks a response cached for a similar prompt.
+	CacheSemantic = "SEMANTIC"
+	// CacheMiss marks a response of the upstream API.
+	CacheMiss = "MISS"
+)
+
+// Options contains options for configuring the Proxy.
+type Options struct {
+	// NewEngine creates the engine of a cache partition for chat completions and completions.
+	// Requests with different endpoints, models or parameters are cached in different partitions.
+	// The prompts of the engine are the canonical JSON of the requests, so similarity engines should
+	// embed them with a TextEmbedder. Default is an LRUEngine, which only serves exact matches.
+	NewEngine func() (llmcache.Engine[string], error)
+	// NewEmbeddingsEngine creates the engine of a cache partition for embeddings. Default is an LRUEngine.
+	NewEmbeddingsEngine func() (llmcache.Engine[string], error)
+	// Transport is used for the upstream requests. Default is http.DefaultTransport.
+	Transport http.RoundTripper
+	// APIKey replaces the Authorization header of the requests if set. All clients then share
+	// the cache, so they must authenticate with the ClientKey.
+	// If empty, the clients send their own key and the cache is partitioned by the Authorization
+	// header, so that responses are only served to clients with the same key. Requests to the
+	// cached endpoints without an Authorization header are rejected.
+	APIKey string
+	// ClientKey is the key clients must send as bearer token if APIKey is set. It is required with an APIKey.
+	ClientKey string
+	// MaxBodySize is the maximum size of cached request and response bodies. Default is 10 MiB.
+	MaxBodySize int64
+	// MaxPartitions is the maximum number of cache partitions. The least recently used partition
+	// is dropped when it is exceeded, and its engine is closed if it implements io.Closer. Default is 100.
+	MaxPartitions int
+}
+
+// Proxy is an http.Handler caching the responses of an OpenAI-compatible API. It serves
+// /v1/chat/completions, /v1/completions and /v1/embeddings from the cache and forwards
+// all other requests to the upstream API. Streaming requests are not cached.
+type Proxy struct {
+	opts     Options
+	upstream *url.URL
+	reverse  *httputil.ReverseProxy
+	mu       sync.Mutex
+	// partitions holds the cache of each client key, endpoint, model and parameters.
+	partitions *lru.Cache[string, *partition]
+}
+
+// partition is the cache of an endpoint, model and parameters, and of a client key
+// unless all clients share the cache.
+type partition struct {
+	cache  *llmcache.LLMCache[string]
+	engine llmcache.Engine[string]
+}
+
+// endpoint is a cached endpoint of the API.
+type endpoint struct {
+	// field is the request field holding the prompt.
+	field string
+	// render renders the prompt field as text. It also validates the field.
+	render func(raw json.RawMessage) (string, error)
+	// embeddings indicates the embeddings endpoint.
+	embeddings bool
+}
+
+// endpoints are the cached endpoints by path.
+var endpoints = map[string]endpoint{
+	"/v1/chat/completions": {field: "messages", render: renderMessages},
+	"/v1/completions":      {field: "prompt", render: renderJSON},
+	"/v1/embeddings":       {field: "input", render: renderJSON, embeddings: true},
+}
+
+// New creates a new Proxy instance forwarding to the upstream base URL, e.g. https://api.openai.com/v1.
+func New(upstream string, optFns ...func(o *Options)) (*Proxy, error) {
+	opts := Options{
+		NewEngine: func() (llmcache.Engine[string], error) {
+			return llmcache.NewLRUEngine[string]()
+		},
+		NewEmbeddingsEngine: func() (llmcache.Engine[string], error) {
+			return llmcache.NewLRUEngine[string]()
+		},
+		Transport:     http.DefaultTransport,
+		MaxBodySize:   10 << 20,
+		MaxPartitions: 100,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid upstream URL %q", upstream)
+	}
+
+	if opts.APIKey != "" && opts.ClientKey == "" {
+		return nil, errors.New("client key is required with an API key")
+	}
+
+	if opts.MaxPartitions <= 0 {
+		opts.MaxPartitions = 100
+	}
+
+	partitions, err := lru.NewWithEvict(opts.MaxPartitions, func(_ string, p *partition) {
+		if closer, ok := p.engine.(io.Closer); ok {
+			_ = closer.Close()
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	p := &Proxy{
+		opts:       opts,
+		upstream:   u,
+		partitions: partitions,
+	}
+
+	p.reverse = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      opts.Transport,
+		ModifyResponse: p.modifyResponse,
+		FlushInterval:  -1,
+	}
+
+	return p, nil
+}
+
+// cacheRequest is the cache state of a forwarded request.
+type cacheRequest struct {
+	cache  *llmcache.LLMCache[string]
+	prompt string
+}
+
+// cacheRequestKey is the context key of the cacheRequest.
+type cacheRequestKey struct{}
+
+// ServeHTTP serves the request from the cache or forwards it to the upstream API.
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+
+	// The API key of the proxy is only used on behalf of authenticated clients
+	if p.opts.APIKey != "" && subtle.ConstantTimeCompare([]byte(auth), []byte("Bearer "+p.opts.ClientKey)) != 1 {
+		http.Error(w, "invalid client key", http.StatusUnauthorized)
+		return
+	}
+
+	ep, ok := endpoints[r.URL.Path]
+	if !ok || r.Method != http.MethodPost {
+		p.reverse.ServeHTTP(w, r)
+		return
+	}
+
+	// Without an API key of the proxy, responses are cached by the key of the client
+	var credential string
+
+	if p.opts.APIKey == "" {
+		if auth == "" {
+			http.Error(w, "missing Authorization header", http.StatusUnauthorized)
+			return
+		}
+
+		sum := sha256.Sum256([]byte(auth))
+		credential = hex.EncodeToString(sum[:])
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, p.opts.MaxBodySize+1))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if int64(len(body)) > p.opts.MaxBodySize {
+		// Forward the complete body without caching
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		p.reverse.ServeHTTP(w, r)
+
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+
+	cache, prompt, err := p.cacheFor(r.URL.Path, credential, ep, body)
+	if err != nil {
+		// Streaming requests are forwarded and the upstream API reports invalid requests
+		w.Header().Set(HeaderCache, CacheMiss)
+		p.reverse.ServeHTTP(w, r)
+
+		return
+	}
+
+	if match, ok := cache.LookupMatch(r.Context(), prompt); ok {
+		status := CacheHit
+		if !match.Exact {
+			status = CacheSemantic
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set(HeaderCache, status)
+		_, _ = io.WriteString(w, match.Result)
+
+		return
+	}
+
+	ctx := context.WithValue(r.Context(), cacheRequestKey{}, &cacheRequest{cache: cache, prompt: prompt})
+	p.reverse.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// errNotCacheable is returned for requests which are forwarded without caching.
+var errNotCacheable = errors.New("request is not cacheable")
+
+// cacheFor returns the cache partition and the prompt of a request. The credential is the hash
+// of the Authorization header of the client, or empty if all clients share the cache.
+func (p *Proxy) cacheFor(path, credential string, ep endpoint, body []byte) (*llmcache.LLMCache[string], string, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, "", err
+	}
+
+	if stream, ok := fields["stream"]; ok && string(stream) == "true" {
+		return nil, "", errNotCacheable
+	}
+
+	raw, ok := fields[ep.field]
+	if !ok {
+		return nil, "", fmt.Errorf("missing field %q", ep.field)
+	}
+
+	if _, err := ep.render(raw); err != nil {
+		return nil, "", err
+	}
+
+	// The prompt is the canonical JSON of the field, so different requests never share a prompt
+	prompt, err := canonicalJSON(raw)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// All other parameters select the partition
+	delete(fields, ep.field)
+	delete(fields, "stream")
+	delete(fields, "stream_options")
+	delete(fields, "user")
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return nil, "", err
+	}
+
+	params, err := canonicalJSON(data)
+	if err != nil {
+		return nil, "", err
+	}
+
+	cache, err := p.partition(credential+" "+path+" "+params, ep.embeddings)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return cache, prompt, nil
+}
+
+// partition returns the cache of the partition key, creating it on first use.
+func (p *Proxy) partition(key string, embeddings bool) (*llmcache.LLMCache[string], error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if part, ok := p.partitions.Get(key); ok {
+		return part.cache, nil
+	}
+
+	newEngine := p.opts.NewEngine
+	if embeddings {
+		newEngine = p.opts.NewEmbeddingsEngine
+	}
+
+	engine, err := newEngine()
+	if err != nil {
+		return nil, err
+	}
+
+	cache := llmcache.New(engine)
+	p.partitions.Add(key, &partition{cache: cache, engine: engine})
+
+	return cache, nil
+}
+
+// rewrite rewrites a request to the upstream API.
+func (p *Proxy) rewrite(r *httputil.ProxyRequest) {
+	r.Out.URL.Path = strings.TrimPrefix(r.In.URL.Path, "/v1")
+	r.Out.URL.RawPath = ""
+	r.SetURL(p.upstream)
+	r.Out.Host = p.upstream.Host
+
+	if p.opts.APIKey != "" {
+		r.Out.Header.Set("Authorization", "Bearer "+p.opts.APIKey)
+	}
+
+	// Cached responses must not be compressed for a single client
+	if r.In.Context().Value(cacheRequestKey{}) != nil {
+		r.Out.Header.Del("Accept-Encoding")
+	}
+}
+
+// modifyResponse caches successful upstream responses of cacheable requests.
+func (p *Proxy) modifyResponse(res *http.Response) error {
+	cr, ok := res.Request.Context().Value(cacheRequestKey{}).(*cacheRequest)
+	if !ok {
+		return nil
+	}
+
+	res.Header.Set(HeaderCache, CacheMiss)
+
+	if res.StatusCode != http.StatusOK {
+		return nil
+	}
+
+	body, err := io.ReadAll(io.LimitReader(res.Body, p.opts.MaxBodySize+1))
+	if err != nil {
+		return err
+	}
+
+	if int64(len(body)) > p.opts.MaxBodySize {
+		res.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), res.Body), res.Body}
+
+		return nil
+	}
+
+	_ = res.Body.Close()
+
+	res.Body = io.NopCloser(bytes.NewReader(body))
+	res.ContentLength = int64(len(body))
+	res.Header.Del("Content-Length")
+
+	// A failed update only affects later requests
+	_ = cr.cache.Update(res.Request.Context(), cr.prompt, string(body))
+
+	return nil
+}
+
+// TextEmbedder embeds the prompts of the proxy, which are the canonical JSON of the requests,
+// as rendered text, e.g. "user: Hello" for the messages of a chat completion.
+type TextEmbedder struct {
+	embedder llmcache.Embedder
+}
+
+// NewTextEmbedder creates a new TextEmbedder instance embedding the rendered text with the embedder.
+func NewTextEmbedder(embedder llmcache.Embedder) *TextEmbedder {
+	return &TextEmbedder{
+		embedder: embedder,
+	}
+}
+
+// EmbedText renders the prompt as text and embeds it.
+func (e *TextEmbedder) EmbedText(ctx context.Context, prompt string) ([]float32, error) {
+	return e.embedder.EmbedText(ctx, renderPrompt(prompt))
+}
+
+// renderPrompt renders the canonical JSON of chat messages, a completion prompt or an embeddings
+// input as text. Other prompts are returned as is.
+func renderPrompt(prompt string) string {
+	if text, err := renderMessages(json.RawMessage(prompt)); err == nil {
+		return text
+	}
+
+	if text, err := renderJSON(json.RawMessage(prompt)); err == nil {
+		return text
+	}
+
+	return prompt
+}
+
+// canonicalJSON returns the canonical JSON of a value: compact, with sorted object keys
+// and numbers as written.
+func canonicalJSON(raw []byte) (string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return "", err
+	}
+
+	if dec.More() {
+		return "", errors.New("invalid JSON: trailing data")
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
+
+// renderMessages renders chat messages as text, one message per line.
+// Text content is rendered as is, all other fields as compact JSON.
+func renderMessages(raw json.RawMessage) (string, error) {
+	var messages []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &messages); err != nil {
+		return "", err
+	}
+
+	var sb strings.Builder
+
+	for i, m := range messages {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+
+		var role string
+		if err := json.Unmarshal(m["role"], &role); err != nil {
+			return "", fmt.Errorf("invalid role: %w", err)
+		}
+
+		sb.WriteString(role)
+		sb.WriteString(": ")
+
+		if content, ok := m["content"]; ok {
+			text, err := renderContent(content)
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(text)
+		}
+
+		keys := make([]string, 0, len(m))
+
+		for k := range m {
+			if k != "role" && k != "content" {
+				keys = append(keys, k)
+			}
+		}
+
+		slices.Sort(keys)
+
+		for _, k := range keys {
+			v, err := renderJSON(m[k])
+			if err != nil {
+				return "", err
+			}
+
+			sb.WriteString(" " + k + "=" + v)
+		}
+	}
+
+	return sb.String(), nil
+}
+
+// renderContent renders the content of a chat message. The content is either
+// a string or a list of parts, of which text parts are rendered as is.
+func renderContent(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var parts []json.RawMessage
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return renderJSON(raw)
+	}
+
+	rendered := make([]string, 0, len(parts))
+
+	for _, part := range parts {
+		var p struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		}
+
+		if err := json.Unmarshal(part, &p); err == nil && p.Type == "text" {
+			rendered = append(rendered, p.Text)
+			continue
+		}
+
+		s, err := renderJSON(part)
+		if err != nil {
+			return "", err
+		}
+
+		rendered = append(rendered, s)
+	}
+
+	return strings.Join(rendered, " "), nil
+}
+
+// renderJSON renders a string as is and all other values as compact JSON.
+func renderJSON(raw json.RawMessage) (string, error) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, nil
+	}
+
+	var buf bytes.Buffer
+	if err := json.Compact(&buf, raw); err != nil {
+		return "", err
+	}
+
+	return buf.String(), nil
+}
@@ -0,0 +1,384 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeUpstream is an OpenAI-compatible API answering with the number of requests.
+type fakeUpstream struct {
+	requests atomic.Int32
+	status   int
+	auth     atomic.Value
+	bodySize atomic.Int64
+}
+
+func (f *fakeUpstream) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := f.requests.Add(1)
+	f.auth.Store(r.Header.Get("Authorization"))
+
+	body, _ := io.ReadAll(r.Body)
+	f.bodySize.Store(int64(len(body)))
+
+	if f.status != 0 {
+		w.WriteHeader(f.status)
+		return
+	}
+
+	if strings.Contains(string(body), `"stream":true`) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprintf(w, "data: {\"n\":%d}\n\ndata: [DONE]\n\n", n)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprintf(w, `{"path":%q,"n":%d}`, r.URL.Path, n)
+}
+
+func newTestProxy(t *testing.T, upstream *fakeUpstream, optFns ...func(o *Options)) *httptest.Server {
+	t.Helper()
+
+	backend := httptest.NewServer(upstream)
+	t.Cleanup(backend.Close)
+
+	p, err := New(backend.URL, optFns...)
+	assert.NoError(t, err)
+
+	server := httptest.NewServer(p)
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+// send posts the body with the Authorization header, which is omitted if auth is empty.
+func send(t *testing.T, url, auth, body string) *http.Response {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(body))
+	assert.NoError(t, err)
+
+	req.Header.Set("Content-Type", "application/json")
+
+	if auth != "" {
+		req.Header.Set("Authorization", auth)
+	}
+
+	res, err := http.DefaultClient.Do(req)
+	assert.NoError(t, err)
+
+	return res
+}
+
+func post(t *testing.T, url, body string) (string, string) {
+	t.Helper()
+
+	return postAs(t, url, "Bearer client", body)
+}
+
+func postAs(t *testing.T, url, auth, body string) (string, string) {
+	t.Helper()
+
+	res := send(t, url, auth, body)
+	defer res.Body.Close()
+
+	b, err := io.ReadAll(res.Body)
+	assert.NoError(t, err)
+
+	return res.Header.Get(HeaderCache), string(b)
+}
+
+func chat(model, content string) string {
+	return fmt.Sprintf(`{"model":%q,"messages":[{"role":"user","content":%q}]}`, model, content)
+}
+
+func TestProxy(t *testing.T) {
+	t.Run("Chat Completions", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream)
+		url := server.URL + "/v1/chat/completions"
+
+		status, body := post(t, url, chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheMiss, status)
+		assert.JSONEq(t, `{"path":"/chat/completions","n":1}`, body)
+
+		// Formatting and parameter order do not matter
+		status, body = post(t, url, `{"messages": [{"content": "Hello", "role": "user"}], "model": "gpt-4o"}`)
+		assert.Equal(t, CacheHit, status)
+		assert.JSONEq(t, `{"path":"/chat/completions","n":1}`, body)
+
+		status, _ = post(t, url, chat("gpt-4o-mini", "Hello"))
+		assert.Equal(t, CacheMiss, status)
+
+		status, _ = post(t, url, chat("gpt-4o", "Goodbye"))
+		assert.Equal(t, CacheMiss, status)
+
+		assert.Equal(t, int32(3), upstream.requests.Load())
+	})
+
+	t.Run("Conversations", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream)
+		url := server.URL + "/v1/chat/completions"
+
+		status, _ := post(t, url, `{"model":"m","messages":[{"role":"user","content":"Hello"},{"role":"assistant","content":"Hi"}]}`)
+		assert.Equal(t, CacheMiss, status)
+
+		// A single message rendered like a conversation is a different request
+		status, _ = post(t, url, chat("m", "Hello\nassistant: Hi"))
+		assert.Equal(t, CacheMiss, status)
+
+		// A completion prompt string is not the same as a list of prompts
+		status, _ = post(t, server.URL+"/v1/completions", `{"model":"m","prompt":["a"]}`)
+		assert.Equal(t, CacheMiss, status)
+
+		status, _ = post(t, server.URL+"/v1/completions", `{"model":"m","prompt":"[\"a\"]"}`)
+		assert.Equal(t, CacheMiss, status)
+
+		assert.Equal(t, int32(4), upstream.requests.Load())
+	})
+
+	t.Run("Partitions", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream, func(o *Options) {
+			o.MaxPartitions = 2
+		})
+		url := server.URL + "/v1/chat/completions"
+
+		request := func(temperature string) string {
+			return `{"model":"m","temperature":` + temperature + `,"messages":[{"role":"user","content":"Hello"}]}`
+		}
+
+		for _, temperature := range []string{"0.1", "0.2", "0.3"} {
+			status, _ := post(t, url, request(temperature))
+			assert.Equal(t, CacheMiss, status)
+		}
+
+		status, _ := post(t, url, request("0.3"))
+		assert.Equal(t, CacheHit, status)
+
+		// The least recently used partition was dropped
+		status, _ = post(t, url, request("0.1"))
+		assert.Equal(t, CacheMiss, status)
+	})
+
+	t.Run("Semantic", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream, func(o *Options) {
+			o.NewEngine = func() (llmcache.Engine[string], error) {
+				return llmcache.NewLRUSimilarityEngine[string](NewTextEmbedder(llmcache.NewHashEmbedder()), func(o *llmcache.LRUSimilarityEngineOptions) {
+					o.Threshold = 0.5
+				})
+			}
+		})
+		url := server.URL + "/v1/chat/completions"
+
+		status, _ := post(t, url, chat("gpt-4o", "What year was Albert Einstein born?"))
+		assert.Equal(t, CacheMiss, status)
+
+		status, body := post(t, url, chat("gpt-4o", "In what year was Albert Einstein born?"))
+		assert.Equal(t, CacheSemantic, status)
+		assert.JSONEq(t, `{"path":"/chat/completions","n":1}`, body)
+
+		status, _ = post(t, url, chat("gpt-4o", "What year was Albert Einstein born?"))
+		assert.Equal(t, CacheHit, status)
+
+		assert.Equal(t, int32(1), upstream.requests.Load())
+	})
+
+	t.Run("Completions And Embeddings", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream)
+
+		for _, path := range []string{"/v1/completions", "/v1/embeddings"} {
+			field := "prompt"
+			if path == "/v1/embeddings" {
+				field = "input"
+			}
+
+			req := fmt.Sprintf(`{"model":"m",%q:["a","b"]}`, field)
+
+			status, _ := post(t, server.URL+path, req)
+			assert.Equal(t, CacheMiss, status)
+
+			status, body := post(t, server.URL+path, req)
+			assert.Equal(t, CacheHit, status)
+			assert.Contains(t, body, strings.TrimPrefix(path, "/v1"))
+		}
+
+		assert.Equal(t, int32(2), upstream.requests.Load())
+	})
+
+	t.Run("Streaming", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream)
+		req := `{"model":"gpt-4o","stream":true,"messages":[{"role":"user","content":"Hello"}]}`
+
+		for i := 1; i <= 2; i++ {
+			status, body := post(t, server.URL+"/v1/chat/completions", req)
+			assert.Equal(t, CacheMiss, status)
+			assert.Contains(t, body, fmt.Sprintf(`{"n":%d}`, i))
+		}
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		upstream := &fakeUpstream{status: http.StatusTooManyRequests}
+		server := newTestProxy(t, upstream)
+
+		for i := 0; i < 2; i++ {
+			res := send(t, server.URL+"/v1/chat/completions", "Bearer client", chat("gpt-4o", "Hello"))
+			assert.Equal(t, http.StatusTooManyRequests, res.StatusCode)
+			assert.Equal(t, CacheMiss, res.Header.Get(HeaderCache))
+			res.Body.Close()
+		}
+
+		assert.Equal(t, int32(2), upstream.requests.Load())
+	})
+
+	t.Run("Pass Through", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream, func(o *Options) {
+			o.APIKey = "secret"
+			o.ClientKey = "client"
+		})
+
+		req, err := http.NewRequest(http.MethodGet, server.URL+"/v1/models", nil)
+		assert.NoError(t, err)
+
+		req.Header.Set("Authorization", "Bearer client")
+
+		res, err := http.DefaultClient.Do(req)
+		assert.NoError(t, err)
+
+		defer res.Body.Close()
+
+		body, err := io.ReadAll(res.Body)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `{"path":"/models","n":1}`, string(body))
+		assert.Empty(t, res.Header.Get(HeaderCache))
+		assert.Equal(t, "Bearer secret", upstream.auth.Load())
+	})
+
+	t.Run("Oversized Body", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream, func(o *Options) {
+			o.MaxBodySize = 20
+		})
+
+		req := chat("gpt-4o", strings.Repeat("a", 40))
+
+		for i := 1; i <= 2; i++ {
+			res := send(t, server.URL+"/v1/chat/completions", "Bearer client", req)
+			assert.Equal(t, http.StatusOK, res.StatusCode)
+			assert.Empty(t, res.Header.Get(HeaderCache))
+			res.Body.Close()
+
+			// The complete body is forwarded without caching
+			assert.Equal(t, int64(len(req)), upstream.bodySize.Load())
+			assert.Equal(t, int32(i), upstream.requests.Load())
+		}
+	})
+
+	t.Run("Client Keys", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream)
+		url := server.URL + "/v1/chat/completions"
+
+		status, _ := postAs(t, url, "Bearer key-a", chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheMiss, status)
+
+		status, _ = postAs(t, url, "Bearer key-a", chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheHit, status)
+
+		// Responses are not served to clients with another key
+		status, _ = postAs(t, url, "Bearer key-b", chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheMiss, status)
+		assert.Equal(t, "Bearer key-b", upstream.auth.Load())
+
+		res := send(t, url, "", chat("gpt-4o", "Hello"))
+		assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+		res.Body.Close()
+
+		assert.Equal(t, int32(2), upstream.requests.Load())
+	})
+
+	t.Run("Shared API Key", func(t *testing.T) {
+		upstream := &fakeUpstream{}
+		server := newTestProxy(t, upstream, func(o *Options) {
+			o.APIKey = "secret"
+			o.ClientKey = "client"
+		})
+		url := server.URL + "/v1/chat/completions"
+
+		status, _ := post(t, url, chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheMiss, status)
+		assert.Equal(t, "Bearer secret", upstream.auth.Load())
+
+		// Cached responses are only served to clients with the client key
+		for _, auth := range []string{"", "Bearer invalid", "Bearer secret"} {
+			res := send(t, url, auth, chat("gpt-4o", "Hello"))
+			assert.Equal(t, http.StatusUnauthorized, res.StatusCode)
+			assert.Empty(t, res.Header.Get(HeaderCache))
+			res.Body.Close()
+		}
+
+		status, _ = post(t, url, chat("gpt-4o", "Hello"))
+		assert.Equal(t, CacheHit, status)
+
+		assert.Equal(t, int32(1), upstream.requests.Load())
+
+		_, err := New("http://localhost", func(o *Options) {
+			o.APIKey = "secret"
+		})
+		assert.EqualError(t, err, "client key is required with an API key")
+	})
+
+	t.Run("Invalid Upstream", func(t *testing.T) {
+		_, err := New("localhost")
+		assert.Error(t, err)
+	})
+}
+
+func TestRenderMessages(t *testing.T) {
+	text, err := renderMessages(json.RawMessage(`[
+		{"role": "system", "content": "Be brief."},
+		{"role": "user", "content": [{"type": "text", "text": "Describe"}, {"type": "image_url", "image_url": {"url": "a.png"}}]},
+		{"role": "tool", "content": "42", "tool_call_id": "call_1"}
+	]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "system: Be brief.\n"+
+		`user: Describe {"type":"image_url","image_url":{"url":"a.png"}}`+"\n"+
+		"tool: 42 tool_call_id=call_1", text)
+
+	_, err = renderMessages(json.RawMessage(`{}`))
+	assert.Error(t, err)
+}
+
+func TestTextEmbedder(t *testing.T) {
+	prompt, err := canonicalJSON([]byte(`[ {"content": "Hello", "role": "user"} ]`))
+	assert.NoError(t, err)
+	assert.Equal(t, `[{"content":"Hello","role":"user"}]`, prompt)
+
+	assert.Equal(t, "user: Hello", renderPrompt(prompt))
+	assert.Equal(t, "Hello", renderPrompt(`"Hello"`))
+	assert.Equal(t, `["a","b"]`, renderPrompt(`["a","b"]`))
+
+	embedder := llmcache.NewHashEmbedder()
+
+	want, err := embedder.EmbedText(context.TODO(), "user: Hello")
+	assert.NoError(t, err)
+
+	got, err := NewTextEmbedder(embedder).EmbedText(context.TODO(), prompt)
+	assert.NoError(t, err)
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,352 @@
+package llmcache
+
+import (
+	"math"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+)
+
+// Quantization defines the format in which embeddings are stored in the cache.
+type Quantization int
+
+const (
+	// QuantizationNone stores embeddings as float32 vectors.
+	QuantizationNone Quantization = iota
+	// QuantizationInt8 stores embeddings as int8 vectors with a per-vector scale and offset.
+	// It reduces the memory of an embedding by 75% with a small loss of accuracy.
+	QuantizationInt8
+	// QuantizationBinary stores the sign of each dimension as a single bit.
+	// It reduces the memory of an embedding by 97%, but only estimates the angle
+	// between embeddings, so it should be combined with rescoring.
+	QuantizationBinary
+	// QuantizationFloat16 stores embeddings as IEEE 754 half precision values.
+	// It halves the memory of an embedding with a negligible loss of accuracy.
+	QuantizationFloat16
+	// QuantizationBFloat16 stores embeddings as bfloat16 values. It halves the memory
+	// of an embedding and keeps the range of float32 at a lower precision than float16.
+	QuantizationBFloat16
+)
+
+// String returns the name of the quantization.
+func (q Quantization) String() string {
+	switch q {
+	case QuantizationNone:
+		return "none"
+	case QuantizationInt8:
+		return "int8"
+	case QuantizationBinary:
+		return "binary"
+	case QuantizationFloat16:
+		return "float16"
+	case QuantizationBFloat16:
+		return "bfloat16"
+	default:
+		return "unknown"
+	}
+}
+
+// storedEmbedding is the representation of an embedding kept in a cache entry.
+type storedEmbedding interface {
+	// dot approximates the dot product with the query embedding.
+	dot(q *queryEmbedding) float32
+	// squaredL2 approximates the squared L2 distance to the query embedding.
+	// norm is the L2 norm of the original embedding.
+	squaredL2(q *queryEmbedding, norm float32) float32
+	// vector returns the (dequantized) embedding. Quantized embeddings are dequantized into dst
+	// if its capacity suffices, so that comparisons can reuse a buffer.
+	vector(dst []float32) []float32
+	// dim returns the number of dimensions of the embedding.
+	dim() int
+}
+
+// queryEmbedding is an embedding prepared for the comparison with stored embeddings.
+type queryEmbedding struct {
+	// vector is the full precision embedding.
+	vector []float32
+	// norm is the L2 norm of the embedding.
+	norm float32
+	// int8 is the int8 quantized embedding, if int8 quantization is enabled.
+	int8 *int8Embedding
+	// binary is the binary quantized embedding, if binary quantization is enabled.
+	binary *binaryEmbedding
+	// scratch is the buffer for the dequantized embeddings compared with the query.
+	scratch []float32
+}
+
+// newQueryEmbedding prepares the embedding for the comparison with embeddings stored in the given format.
+func newQueryEmbedding(v []float32, q Quantization) *queryEmbedding {
+	query := &queryEmbedding{
+		vector: v,
+		norm:   Magnitude(v),
+	}
+
+	switch q {
+	case QuantizationInt8:
+		query.int8 = quantizeInt8(v)
+	case QuantizationBinary:
+		query.binary = quantizeBinary(v, query.norm)
+	case QuantizationNone, QuantizationFloat16, QuantizationBFloat16:
+	}
+
+	return query
+}
+
+// dequantize returns the (dequantized) stored embedding. It reuses the scratch buffer of the query,
+// so the result is only valid until the next call.
+func (q *queryEmbedding) dequantize(e storedEmbedding) []float32 {
+	// Full precision embeddings are returned as they are and must not become the buffer
+	if v, ok := e.(float32Embedding); ok {
+		return v
+	}
+
+	q.scratch = e.vector(q.scratch)
+
+	return q.scratch
+}
+
+// quantize converts the embedding into the given storage format.
+func quantize(v []float32, norm float32, q Quantization) storedEmbedding {
+	switch q {
+	case QuantizationInt8:
+		return quantizeInt8(v)
+	case QuantizationBinary:
+		return quantizeBinary(v, norm)
+	case QuantizationFloat16:
+		return toFloat16(v)
+	case QuantizationBFloat16:
+		return toBFloat16(v)
+	default:
+		return float32Embedding(v)
+	}
+}
+
+// float32Embedding is an embedding stored in full precision.
+type float32Embedding []float32
+
+func (e float32Embedding) dot(q *queryEmbedding) float32 {
+	return math32.Dot(q.vector, e)
+}
+
+func (e float32Embedding) squaredL2(q *queryEmbedding, _ float32) float32 {
+	return math32.SquaredL2(q.vector, e)
+}
+
+func (e float32Embedding) vector(_ []float32) []float32 {
+	return e
+}
+
+func (e float32Embedding) dim() int {
+	return len(e)
+}
+
+// int8Embedding is an embedding quantized to int8 values. The value of
+// dimension i is approximated by offset + scale*data[i].
+type int8Embedding struct {
+	data   []int8
+	scale  float32
+	offset float32
+	// sum is the sum of all quantized values.
+	sum int32
+}
+
+// quantizeInt8 maps the range of the vector linearly onto the int8 range.
+func quantizeInt8(v []float32) *int8Embedding {
+	e := &int8Embedding{
+		data: make([]int8, len(v)),
+	}
+
+	if len(v) == 0 {
+		return e
+	}
+
+	lo, hi := v[0], v[0]
+	for _, x := range v[1:] {
+		lo = min(lo, x)
+		hi = max(hi, x)
+	}
+
+	if hi == lo {
+		e.offset = lo
+		return e
+	}
+
+	e.scale = (hi - lo) / 255
+	e.offset = lo + 128*e.scale
+
+	for i, x := range v {
+		q := math.Round(float64((x-lo)/e.scale)) - 128
+		e.data[i] = int8(max(-128, min(127, q)))
+		e.sum += int32(e.data[i])
+	}
+
+	return e
+}
+
+func (e *int8Embedding) dot(q *queryEmbedding) float32 {
+	o := q.int8
+	n := float64(len(e.data))
+
+	return float32(n*float64(o.offset)*float64(e.offset) +
+		float64(o.offset)*float64(e.scale)*float64(e.sum) +
+		float64(e.offset)*float64(o.scale)*float64(o.sum) +
+		float64(o.scale)*float64(e.scale)*float64(math32.DotInt8(o.data, e.data)))
+}
+
+func (e *int8Embedding) squaredL2(q *queryEmbedding, norm float32) float32 {
+	return squaredL2FromDot(q.norm, norm, e.dot(q))
+}
+
+func (e *int8Embedding) vector(dst []float32) []float32 {
+	v := resize(dst, len(e.data))
+	for i, q := range e.data {
+		v[i] = e.offset + e.scale*float32(q)
+	}
+
+	return v
+}
+
+func (e *int8Embedding) dim() int {
+	return len(e.data)
+}
+
+// binaryEmbedding is an embedding quantized to the signs of its dimensions.
+type binaryEmbedding struct {
+	bits []uint64
+	size int
+	// scale is the magnitude of a single dimension, so that the dequantized
+	// embedding keeps the norm of the original embedding.
+	scale float32
+}
+
+// quantizeBinary keeps one bit per dimension which is set for positive values.
+func quantizeBinary(v []float32, norm float32) *binaryEmbedding {
+	e := &binaryEmbedding{
+		bits: make([]uint64, (len(v)+63)/64),
+		size: len(v),
+	}
+
+	if len(v) > 0 {
+		e.scale = norm / math32.Sqrt(float32(len(v)))
+	}
+
+	for i, x := range v {
+		if x > 0 {
+			e.bits[i/64] |= 1 << (i % 64)
+		}
+	}
+
+	return e
+}
+
+// dot estimates the angle between the embeddings from the fraction of differing signs.
+func (e *binaryEmbedding) dot(q *queryEmbedding) float32 {
+	if e.size == 0 {
+		return 0
+	}
+
+	h := math32.Hamming(q.binary.bits, e.bits)
+	cos := math.Cos(math.Pi * float64(h) / float64(e.size))
+
+	return float32(cos) * q.norm * e.scale * math32.Sqrt(float32(e.size))
+}
+
+func (e *binaryEmbedding) squaredL2(q *queryEmbedding, norm float32) float32 {
+	return squaredL2FromDot(q.norm, norm, e.dot(q))
+}
+
+func (e *binaryEmbedding) vector(dst []float32) []float32 {
+	v := resize(dst, e.size)
+	for i := range v {
+		if e.bits[i/64]&(1<<(i%64)) != 0 {
+			v[i] = e.scale
+		} else {
+			v[i] = -e.scale
+		}
+	}
+
+	return v
+}
+
+func (e *binaryEmbedding) dim() int {
+	return e.size
+}
+
+// float16Embedding is an embedding stored as IEEE 754 half precision values.
+type float16Embedding []uint16
+
+func toFloat16(v []float32) float16Embedding {
+	e := make(float16Embedding, len(v))
+	for i, x := range v {
+		e[i] = math32.Float16(x)
+	}
+
+	return e
+}
+
+func (e float16Embedding) dot(q *queryEmbedding) float32 {
+	return math32.DotFloat16(q.vector, e)
+}
+
+func (e float16Embedding) squaredL2(q *queryEmbedding, _ float32) float32 {
+	return math32.SquaredL2Float16(q.vector, e)
+}
+
+func (e float16Embedding) vector(dst []float32) []float32 {
+	v := resize(dst, len(e))
+	for i, h := range e {
+		v[i] = math32.Float16ToFloat32(h)
+	}
+
+	return v
+}
+
+func (e float16Embedding) dim() int {
+	return len(e)
+}
+
+// bfloat16Embedding is an embedding stored as bfloat16 values.
+type bfloat16Embedding []uint16
+
+func toBFloat16(v []float32) bfloat16Embedding {
+	e := make(bfloat16Embedding, len(v))
+	for i, x := range v {
+		e[i] = math32.BFloat16(x)
+	}
+
+	return e
+}
+
+func (e bfloat16Embedding) dot(q *queryEmbedding) float32 {
+	return math32.DotBFloat16(q.vector, e)
+}
+
+func (e bfloat16Embedding) squaredL2(q *queryEmbedding, _ float32) float32 {
+	return math32.SquaredL2BFloat16(q.vector, e)
+}
+
+func (e bfloat16Embedding) vector(dst []float32) []float32 {
+	v := resize(dst, len(e))
+	for i, h := range e {
+		v[i] = math32.BFloat16ToFloat32(h)
+	}
+
+	return v
+}
+
+func (e bfloat16Embedding) dim() int {
+	return len(e)
+}
+
+// resize returns dst resized to n elements, or a new slice if its capacity does not suffice.
+func resize(dst []float32, n int) []float32 {
+	if cap(dst) < n {
+		return make([]float32, n)
+	}
+
+	return dst[:n]
+}
+
+// squaredL2FromDot calculates the squared L2 distance from the norms and the dot product of two vectors.
+func squaredL2FromDot(normA, normB, dot float32) float32 {
+	return max(0, normA*normA+normB*normB-2*dot)
+}
@@ -0,0 +1,144 @@
+package llmcache
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache/internal/math32"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQuantizeInt8(t *testing.T) {
+	t.Run("Dequantize", func(t *testing.T) {
+		v := []float32{-1, -0.5, 0, 0.25, 1}
+		e := quantizeInt8(v)
+
+		for i, x := range e.vector(nil) {
+			assert.InDelta(t, v[i], x, 0.005)
+		}
+	})
+
+	t.Run("Constant Vector", func(t *testing.T) {
+		v := []float32{0.3, 0.3, 0.3}
+		assert.Equal(t, v, quantizeInt8(v).vector(nil))
+	})
+
+	t.Run("Empty Vector", func(t *testing.T) {
+		assert.Empty(t, quantizeInt8(nil).vector(nil))
+	})
+
+	t.Run("Dot", func(t *testing.T) {
+		a, b := randomEmbedding(384), randomEmbedding(384)
+		query := newQueryEmbedding(a, QuantizationInt8)
+
+		assert.InDelta(t, math32.Dot(a, b), quantizeInt8(b).dot(query), 0.005)
+	})
+}
+
+func TestQuantizeBinary(t *testing.T) {
+	t.Run("Dequantize", func(t *testing.T) {
+		v := []float32{3, -4}
+		e := quantizeBinary(v, Magnitude(v))
+
+		assert.Equal(t, 2, e.dim())
+		assert.InDelta(t, Magnitude(v), Magnitude(e.vector(nil)), 1e-6)
+		assert.Greater(t, e.vector(nil)[0], float32(0))
+		assert.Less(t, e.vector(nil)[1], float32(0))
+	})
+
+	t.Run("Dot", func(t *testing.T) {
+		a := randomEmbedding(384)
+		query := newQueryEmbedding(a, QuantizationBinary)
+
+		same := quantizeBinary(a, query.norm)
+		assert.InDelta(t, query.norm*query.norm, same.dot(query), 1e-3)
+
+		opposite := make([]float32, len(a))
+		for i := range a {
+			opposite[i] = -a[i]
+		}
+
+		assert.InDelta(t, -query.norm*query.norm, quantizeBinary(opposite, query.norm).dot(query), 1e-3)
+	})
+}
+
+func TestHalfPrecision(t *testing.T) {
+	v := randomEmbedding(384)
+
+	t.Run("Float16", func(t *testing.T) {
+		e := toFloat16(v)
+		assert.Equal(t, 384, e.dim())
+
+		for i, x := range e.vector(nil) {
+			assert.InDelta(t, v[i], x, 1e-3*max(1e-2, math.Abs(float64(v[i]))))
+		}
+	})
+
+	t.Run("BFloat16", func(t *testing.T) {
+		e := toBFloat16(v)
+		assert.Equal(t, 384, e.dim())
+
+		for i, x := range e.vector(nil) {
+			assert.InDelta(t, v[i], x, 1e-2*math.Abs(float64(v[i])))
+		}
+	})
+}
+
+func TestLRUSimilarityEngine_QuantizedDistance(t *testing.T) {
+	a, b := randomEmbedding(384), randomEmbedding(384)
+	for i := range b {
+		b[i] = a[i] + b[i]*0.5
+	}
+
+	nb := Magnitude(b)
+	for i := range b {
+		b[i] /= nb
+	}
+
+	for _, q := range []Quantization{QuantizationInt8, QuantizationFloat16, QuantizationBFloat16} {
+		t.Run(q.String(), func(t *testing.T) {
+			for _, fn := range []DistanceFunc{CosineDistance, SquaredL2, Euclidean, NegativeInnerProduct, Manhattan} {
+				expected, err := fn(a, b)
+				assert.NoError(t, err)
+
+				engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{}, func(o *LRUSimilarityEngineOptions) {
+					o.DistanceFunc = fn
+					o.Quantization = q
+				})
+				assert.NoError(t, err)
+
+				entry, err := engine.newEntry(context.TODO(), "prompt", newQueryEmbedding(b, QuantizationNone), "result")
+				assert.NoError(t, err)
+
+				query := newQueryEmbedding(a, q)
+
+				distance, err := engine.distance(query, entry)
+				assert.NoError(t, err)
+				assert.InDelta(t, expected, distance, 0.01*max(1, float64(expected)))
+
+				// Dequantized embeddings reuse the buffer of the query
+				allocs := testing.AllocsPerRun(10, func() {
+					_, _ = engine.distance(query, entry)
+				})
+				assert.Zero(t, allocs)
+			}
+		})
+	}
+}
+
+// randomEmbedding returns a random unit-length vector, like most embedding models do.
+func randomEmbedding(dim int) []float32 {
+	v := make([]float32, dim)
+	for i := range v {
+		v[i] = rand.Float32()*2 - 1 // nolint gosec
+	}
+
+	norm := Magnitude(v)
+	for i := range v {
+		v[i] /= norm
+	}
+
+	return v
+}
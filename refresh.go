@@ -0,0 +1,240 @@
+package llmcache
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lru "github.com/hashicorp/golang-lru/v2"
+)
+
+// RefreshOptions contains options for configuring the expiration and background refresh of entries.
+type RefreshOptions struct {
+	// SoftTTL is the age after which an entry is stale. Stale entries are still returned, but
+	// hitting one with an exact match refreshes it in the background with the refresh function,
+	// see SetRefreshFunc. Zero disables it, but entries of unknown age are always stale.
+	SoftTTL time.Duration
+	// HardTTL is the age after which an entry expires. Expired entries are lookup misses and are
+	// deleted if the engine implements Deleter. Entries of unknown age, e.g. loaded by a persistent
+	// engine or written by another replica sharing the engine, never expire, since they may be
+	// recent; they are stale instead. Zero disables it.
+	HardTTL time.Duration
+	// MaxConcurrency is the maximum number of concurrent refreshes. Stale hits beyond it are
+	// served without a refresh. Default is 4.
+	MaxConcurrency int
+	// Timeout is the timeout of a refresh. Default is 30 seconds.
+	Timeout time.Duration
+	// MaxEntries is the maximum number of entries whose age is tracked. It should not be less than
+	// the capacity of the engine, as entries whose age is no longer tracked are of unknown age and
+	// do not expire. Default is 10000.
+	MaxEntries int
+}
+
+// RefreshStats contains the statistics of the expiration and background refresh of entries.
+type RefreshStats struct {
+	// StaleHits is the number of lookups which returned a stale entry.
+	StaleHits int64 `json:"staleHits"`
+	// Expired is the number of lookups which found an expired entry.
+	Expired int64 `json:"expired"`
+	// Refreshes is the number of successful refreshes.
+	Refreshes int64 `json:"refreshes"`
+	// Failures is the number of failed refreshes.
+	Failures int64 `json:"failures"`
+	// Skipped is the number of refreshes skipped because MaxConcurrency was reached.
+	Skipped int64 `json:"skipped"`
+}
+
+// refresher tracks the age of entries and refreshes stale entries in the background.
+type refresher[T comparable] struct {
+	// opts contains options for configuring the refresher.
+	opts RefreshOptions
+	// written holds the last write times by namespaced key.
+	written *lru.Cache[string, time.Time]
+	// fn is the refresh function. It is nil if none is registered.
+	fn atomic.Pointer[ComputeFunc[T]]
+	// mu guards inflight.
+	mu sync.Mutex
+	// inflight holds the namespaced keys of the running refreshes.
+	inflight map[string]struct{}
+	// sem bounds the number of concurrent refreshes.
+	sem chan struct{}
+	// wg tracks the running refreshes.
+	wg sync.WaitGroup
+	// now returns the current time.
+	now func() time.Time
+	// staleHits, expired, refreshes, failures and skipped count the RefreshStats.
+	staleHits atomic.Int64
+	expired   atomic.Int64
+	refreshes atomic.Int64
+	failures  atomic.Int64
+	skipped   atomic.Int64
+}
+
+// newRefresher creates a new refresher instance with the provided options.
+func newRefresher[T comparable](opts RefreshOptions) *refresher[T] {
+	if opts.MaxConcurrency <= 0 {
+		opts.MaxConcurrency = 4
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = 30 * time.Second
+	}
+
+	if opts.MaxEntries <= 0 {
+		opts.MaxEntries = 10000
+	}
+
+	// The size is always positive, so creating the cache cannot fail
+	written, _ := lru.New[string, time.Time](opts.MaxEntries)
+
+	return &refresher[T]{
+		opts:     opts,
+		written:  written,
+		inflight: make(map[string]struct{}),
+		sem:      make(chan struct{}, opts.MaxConcurrency),
+		now:      time.Now,
+	}
+}
+
+// touch records the write time of the key.
+func (r *refresher[T]) touch(key string) {
+	r.written.Add(key, r.now())
+}
+
+// clearNamespace forgets the write times of the namespace.
+func (r *refresher[T]) clearNamespace(namespace string) {
+	clearNamespace(namespace, r.written.Keys(), func(key string) { r.written.Remove(key) })
+}
+
+// stats returns the refresh statistics.
+func (r *refresher[T]) stats() RefreshStats {
+	return RefreshStats{
+		StaleHits: r.staleHits.Load(),
+		Expired:   r.expired.Load(),
+		Refreshes: r.refreshes.Load(),
+		Failures:  r.failures.Load(),
+		Skipped:   r.skipped.Load(),
+	}
+}
+
+// SetRefreshFunc registers the function refreshing stale entries. It is called in the background
+// with the prompt of the lookup which hit the stale entry exactly, and its result replaces the entry.
+// Entries hit by similar prompts are not refreshed, since the answer to the prompt of the lookup
+// may differ from the answer to the cached prompt. It has no effect unless LLMCacheOptions.Refresh is set.
+func (c *LLMCache[T]) SetRefreshFunc(fn ComputeFunc[T]) {
+	if c.refresher == nil {
+		return
+	}
+
+	c.refresher.fn.Store(&fn)
+}
+
+// RefreshStats returns the statistics of the expiration and background refresh of entries.
+func (c *LLMCache[T]) RefreshStats() RefreshStats {
+	if c.refresher == nil {
+		return RefreshStats{}
+	}
+
+	return c.refresher.stats()
+}
+
+// WaitRefreshes waits for the running background refreshes to finish.
+func (c *LLMCache[T]) WaitRefreshes() {
+	if c.refresher == nil {
+		return
+	}
+
+	c.refresher.wg.Wait()
+}
+
+// fresh reports whether the entry hit by the prompt has not expired. An expired entry is deleted;
+// a stale entry hit exactly is refreshed in the background with the prompt of the lookup.
+// Entries of unknown age are stale, but never expired, as they may have been written recently
+// by another process sharing the engine.
+func (c *LLMCache[T]) fresh(ctx context.Context, prompt string, match Match[T]) bool {
+	r := c.refresher
+	entry := match.Prompt
+	key := namespaceKey(NamespaceFromContext(ctx), entry)
+
+	written, known := r.written.Peek(key)
+	age := r.now().Sub(written)
+
+	if known && r.opts.HardTTL > 0 && age >= r.opts.HardTTL {
+		r.expired.Add(1)
+		r.written.Remove(key)
+
+		if d, ok := c.engine.(Deleter); ok {
+			_ = d.Delete(ctx, entry)
+		}
+
+		return false
+	}
+
+	// Refreshing an entry of unknown age makes its age known
+	if !known || (r.opts.SoftTTL > 0 && age >= r.opts.SoftTTL) {
+		r.staleHits.Add(1)
+
+		if match.Exact {
+			c.refresh(ctx, prompt, entry, key)
+		}
+	}
+
+	return true
+}
+
+// refresh starts the refresh of the entry with the normalized prompt and the namespaced key,
+// unless no refresh function is registered, the entry is already being refreshed or
+// MaxConcurrency is reached.
+func (c *LLMCache[T]) refresh(ctx context.Context, prompt, entry, key string) {
+	r := c.refresher
+
+	fn := r.fn.Load()
+	if fn == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.inflight[key]; ok {
+		return
+	}
+
+	select {
+	case r.sem <- struct{}{}:
+	default:
+		r.skipped.Add(1)
+		return
+	}
+
+	r.inflight[key] = struct{}{}
+	r.wg.Add(1)
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.inflight, key)
+			r.mu.Unlock()
+
+			<-r.sem
+			r.wg.Done()
+		}()
+
+		// The refresh outlives the lookup, but keeps the values of its context, e.g. the namespace
+		ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), r.opts.Timeout)
+		defer cancel()
+
+		result, err := (*fn)(ctx, prompt)
+		if err == nil {
+			err = c.update(ctx, entry, result)
+		}
+
+		if err != nil {
+			r.failures.Add(1)
+			return
+		}
+
+		r.refreshes.Add(1)
+	}()
+}
@@ -0,0 +1,281 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLLMCache_Refresh(t *testing.T) {
+	ctx := context.Background()
+
+	newCache := func(t *testing.T, opts RefreshOptions) (*LLMCache[string], *time.Time) {
+		t.Helper()
+
+		engine, err := NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.Refresh = &opts
+		})
+
+		now := time.Now()
+		cache.refresher.now = func() time.Time { return now }
+
+		return cache, &now
+	}
+
+	t.Run("Stale While Revalidate", func(t *testing.T) {
+		cache, now := newCache(t, RefreshOptions{SoftTTL: time.Minute, HardTTL: time.Hour})
+
+		var prompts []string
+
+		cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+			prompts = append(prompts, prompt)
+			return "result2", nil
+		})
+
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+
+		result, ok := cache.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, "result1", result)
+
+		*now = now.Add(time.Minute)
+
+		// The stale result is served while it is refreshed
+		result, ok = cache.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, "result1", result)
+
+		cache.WaitRefreshes()
+
+		result, ok = cache.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, "result2", result)
+
+		assert.Equal(t, []string{"prompt1"}, prompts)
+		assert.Equal(t, RefreshStats{StaleHits: 1, Refreshes: 1}, cache.RefreshStats())
+	})
+
+	t.Run("Hard TTL", func(t *testing.T) {
+		cache, now := newCache(t, RefreshOptions{HardTTL: time.Hour})
+
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+
+		*now = now.Add(time.Hour)
+
+		_, ok := cache.LookupMatch(ctx, "prompt1")
+		assert.False(t, ok)
+
+		// Expired entries are deleted
+		assert.Equal(t, 0, cache.engine.(*LRUEngine[string]).Len())
+
+		calls := 0
+		result, err := cache.GetOrCompute(ctx, "prompt1", func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "result2", nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "result2", result)
+		assert.Equal(t, 1, calls)
+
+		assert.Equal(t, RefreshStats{Expired: 1}, cache.RefreshStats())
+	})
+
+	t.Run("Deduplication And Concurrency", func(t *testing.T) {
+		cache, now := newCache(t, RefreshOptions{SoftTTL: time.Minute, MaxConcurrency: 1})
+
+		release := make(chan struct{})
+
+		var (
+			mu      sync.Mutex
+			prompts []string
+		)
+
+		cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+			mu.Lock()
+			prompts = append(prompts, prompt)
+			mu.Unlock()
+
+			<-release
+
+			return "", errors.New("upstream unavailable")
+		})
+
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+		assert.NoError(t, cache.Update(ctx, "prompt2", "result2"))
+
+		*now = now.Add(time.Minute)
+
+		for i := 0; i < 3; i++ {
+			_, ok := cache.Lookup(ctx, "prompt1")
+			assert.True(t, ok)
+		}
+
+		// The only refresh slot is taken by prompt1
+		_, ok := cache.Lookup(ctx, "prompt2")
+		assert.True(t, ok)
+
+		close(release)
+		cache.WaitRefreshes()
+
+		assert.Equal(t, []string{"prompt1"}, prompts)
+		assert.Equal(t, RefreshStats{StaleHits: 4, Failures: 1, Skipped: 1}, cache.RefreshStats())
+
+		// A failed refresh keeps the stale entry
+		result, ok := cache.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, "result1", result)
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		cache, now := newCache(t, RefreshOptions{SoftTTL: time.Minute})
+
+		cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+			return NamespaceFromContext(ctx) + " refreshed", nil
+		})
+
+		tenant := cache.Namespace("tenant")
+		assert.NoError(t, tenant.Update(ctx, "prompt1", "result1"))
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+
+		*now = now.Add(time.Minute)
+
+		_, ok := tenant.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+
+		cache.WaitRefreshes()
+
+		result, _ := tenant.Lookup(ctx, "prompt1")
+		assert.Equal(t, "tenant refreshed", result)
+
+		// The default namespace was not hit, so it is still stale
+		result, _ = cache.Lookup(ctx, "prompt1")
+		assert.Equal(t, "result1", result)
+	})
+
+	t.Run("Semantic Hits", func(t *testing.T) {
+		engine, err := NewLRUSimilarityEngine[string](&mockEmbedder{
+			embeddings: map[string][]float32{
+				"prompt1": {0.1, 0.2, 0.3, 0.4},
+				"prompt2": {0.1, 0.2, 0.3, 0.41},
+			},
+		})
+		assert.NoError(t, err)
+
+		cache := New[string](engine, func(o *LLMCacheOptions) {
+			o.Refresh = &RefreshOptions{SoftTTL: time.Minute}
+		})
+
+		now := time.Now()
+		cache.refresher.now = func() time.Time { return now }
+
+		calls := 0
+
+		cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+			calls++
+			return "answer to " + prompt, nil
+		})
+
+		assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+
+		now = now.Add(time.Minute)
+
+		// The stale entry is served, but not replaced with the answer to another prompt
+		match, ok := cache.LookupMatch(ctx, "prompt2")
+		assert.True(t, ok)
+		assert.False(t, match.Exact)
+		assert.Equal(t, "result1", match.Result)
+
+		cache.WaitRefreshes()
+
+		result, ok := cache.Lookup(ctx, "prompt1")
+		assert.True(t, ok)
+		assert.Equal(t, "result1", result)
+
+		cache.WaitRefreshes()
+
+		result, _ = cache.Lookup(ctx, "prompt1")
+		assert.Equal(t, "answer to prompt1", result)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Unknown Age", func(t *testing.T) {
+		t.Run("Soft TTL", func(t *testing.T) {
+			engine := &mockEngine[string]{cache: map[string]string{"prompt1": "result1"}}
+			cache := New[string](engine, func(o *LLMCacheOptions) {
+				o.Refresh = &RefreshOptions{SoftTTL: time.Minute}
+			})
+
+			cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+				return "result2", nil
+			})
+
+			// Entries written before the cache was created are stale
+			result, ok := cache.Lookup(ctx, "prompt1")
+			assert.True(t, ok)
+			assert.Equal(t, "result1", result)
+
+			cache.WaitRefreshes()
+
+			assert.Equal(t, "result2", engine.cache["prompt1"])
+		})
+
+		t.Run("Hard TTL", func(t *testing.T) {
+			engine, err := NewLRUEngine[string]()
+			assert.NoError(t, err)
+			assert.NoError(t, engine.Update(ctx, "prompt1", "result1"))
+
+			cache := New[string](engine, func(o *LLMCacheOptions) {
+				o.Refresh = &RefreshOptions{HardTTL: time.Hour}
+			})
+
+			now := time.Now()
+			cache.refresher.now = func() time.Time { return now }
+
+			cache.SetRefreshFunc(func(ctx context.Context, prompt string) (string, error) {
+				return "result2", nil
+			})
+
+			// Entries written before the cache was created, e.g. by another replica, may be recent
+			result, ok := cache.Lookup(ctx, "prompt1")
+			assert.True(t, ok)
+			assert.Equal(t, "result1", result)
+			assert.Equal(t, 1, engine.Len())
+
+			cache.WaitRefreshes()
+
+			// The refresh makes the age known, so the entry expires
+			now = now.Add(time.Hour)
+
+			_, ok = cache.Lookup(ctx, "prompt1")
+			assert.False(t, ok)
+			assert.Equal(t, 0, engine.Len())
+			assert.Equal(t, RefreshStats{StaleHits: 1, Refreshes: 1, Expired: 1}, cache.RefreshStats())
+		})
+
+		t.Run("Untracked", func(t *testing.T) {
+			cache, now := newCache(t, RefreshOptions{HardTTL: time.Hour, MaxEntries: 1})
+
+			assert.NoError(t, cache.Update(ctx, "prompt1", "result1"))
+			assert.NoError(t, cache.Update(ctx, "prompt2", "result2"))
+
+			*now = now.Add(time.Hour)
+
+			// The age of prompt1 is no longer tracked, so it is stale but not expired
+			result, ok := cache.Lookup(ctx, "prompt1")
+			assert.True(t, ok)
+			assert.Equal(t, "result1", result)
+
+			_, ok = cache.Lookup(ctx, "prompt2")
+			assert.False(t, ok)
+
+			assert.Equal(t, 1, cache.engine.(*LRUEngine[string]).Len())
+		})
+	})
+}
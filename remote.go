@@ -0,0 +1,225 @@
+package llmcache
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/hupe1980/go-llmcache/internal/wire"
+)
+
+// Compile time check to ensure RemoteEngine satisfies the Engine interface.
+var _ Engine[any] = (*RemoteEngine[any])(nil)
+
+// Compile time check to ensure RemoteEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*RemoteEngine[any])(nil)
+
+// Compile time check to ensure RemoteEngine satisfies the Deleter interface.
+var _ Deleter = (*RemoteEngine[any])(nil)
+
+// RemoteEngineOptions contains options for configuring the RemoteEngine.
+type RemoteEngineOptions[T any] struct {
+	// Codec serializes the results. Default is JSONCodec.
+	Codec Codec[T]
+	// APIKey is sent as bearer token if set.
+	APIKey string
+	// Timeout is the timeout of a request. Default is 5 seconds.
+	Timeout time.Duration
+	// MaxIdleConns is the number of idle connections kept open to the server. Default is 64.
+	// It is ignored if Client is set.
+	MaxIdleConns int
+	// Client is the HTTP client used for the requests. Default is a client with a connection
+	// pool of MaxIdleConns connections.
+	Client *http.Client
+	// OnError is called with the errors of lookups, which are reported as misses.
+	OnError func(err error)
+}
+
+// RemoteEngine is a cache engine implementation which stores the results in a cache server,
+// see the server package and the llmcache-server command. Several services share one cache
+// by using the same server. The namespace of the context is sent with each request.
+type RemoteEngine[T comparable] struct {
+	// baseURL is the URL of the server without trailing slash.
+	baseURL string
+	// opts contains options for configuring the RemoteEngine.
+	opts RemoteEngineOptions[T]
+}
+
+// NewRemoteEngine creates a new RemoteEngine instance for the server at the base URL, e.g. http://localhost:8081.
+func NewRemoteEngine[T comparable](baseURL string, optFns ...func(o *RemoteEngineOptions[T])) (*RemoteEngine[T], error) {
+	opts := RemoteEngineOptions[T]{
+		Codec:        JSONCodec[T]{},
+		Timeout:      5 * time.Second,
+		MaxIdleConns: 64,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Codec == nil {
+		return nil, fmt.Errorf("codec is required")
+	}
+
+	if opts.Timeout <= 0 {
+		opts.Timeout = 5 * time.Second
+	}
+
+	if opts.MaxIdleConns <= 0 {
+		opts.MaxIdleConns = 64
+	}
+
+	if opts.Client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.MaxIdleConns = opts.MaxIdleConns
+		transport.MaxIdleConnsPerHost = opts.MaxIdleConns
+
+		opts.Client = &http.Client{Transport: transport}
+	}
+
+	return &RemoteEngine[T]{
+		baseURL: strings.TrimRight(baseURL, "/"),
+		opts:    opts,
+	}, nil
+}
+
+// Lookup retrieves the cached result associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (e *RemoteEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	match, ok := e.LookupMatch(ctx, prompt)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the cached entry matching the given prompt.
+// Failed requests are reported to OnError and as misses.
+func (e *RemoteEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	var res wire.LookupResponse
+	if err := e.do(ctx, http.MethodPost, wire.PathLookup, wire.PromptRequest{Prompt: prompt}, &res); err != nil {
+		e.onError(err)
+		return Match[T]{}, false
+	}
+
+	if !res.Found {
+		return Match[T]{}, false
+	}
+
+	result, err := e.opts.Codec.Decode(res.Result)
+	if err != nil {
+		e.onError(fmt.Errorf("decode result: %w", err))
+		return Match[T]{}, false
+	}
+
+	return Match[T]{Result: result, Prompt: res.Prompt, Distance: res.Distance, Exact: res.Exact}, true
+}
+
+// Update updates the cache with the provided prompt and result.
+// It returns an error if the result cannot be encoded or the request fails.
+func (e *RemoteEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	data, err := e.opts.Codec.Encode(result)
+	if err != nil {
+		return fmt.Errorf("encode result: %w", err)
+	}
+
+	return e.do(ctx, http.MethodPost, wire.PathUpdate, wire.UpdateRequest{Prompt: prompt, Result: data}, nil)
+}
+
+// Delete removes the entry of the given prompt.
+// It returns an error if the engine of the server does not support deletes.
+func (e *RemoteEngine[T]) Delete(ctx context.Context, prompt string) error {
+	return e.do(ctx, http.MethodPost, wire.PathDelete, wire.PromptRequest{Prompt: prompt}, nil)
+}
+
+// Clear clears the cache, removing all entries of all clients.
+// It returns an error if the clear operation fails.
+func (e *RemoteEngine[T]) Clear(ctx context.Context) error {
+	return e.do(WithNamespace(ctx, ""), http.MethodPost, wire.PathClear, nil, nil)
+}
+
+// ClearNamespace removes all entries of the namespace.
+// It returns an error if the engine of the server does not support namespaces.
+func (e *RemoteEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	if namespace == "" {
+		return errors.New("the default namespace cannot be cleared remotely")
+	}
+
+	return e.do(WithNamespace(ctx, namespace), http.MethodPost, wire.PathClear, nil, nil)
+}
+
+// Stats returns the usage statistics of the server.
+func (e *RemoteEngine[T]) Stats(ctx context.Context) (Stats, error) {
+	var stats Stats
+	err := e.do(ctx, http.MethodGet, wire.PathStats, nil, &stats)
+
+	return stats, err
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out, if set.
+func (e *RemoteEngine[T]) do(ctx context.Context, method, path string, in, out any) error {
+	ctx, cancel := context.WithTimeout(ctx, e.opts.Timeout)
+	defer cancel()
+
+	var body io.Reader
+
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return err
+		}
+
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, e.baseURL+path, body)
+	if err != nil {
+		return err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if e.opts.APIKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.opts.APIKey)
+	}
+
+	if namespace := NamespaceFromContext(ctx); namespace != "" {
+		req.Header.Set(wire.HeaderNamespace, namespace)
+	}
+
+	res, err := e.opts.Client.Do(req)
+	if err != nil {
+		return err
+	}
+
+	defer res.Body.Close()
+
+	if res.StatusCode >= http.StatusBadRequest {
+		var errRes wire.ErrorResponse
+		if err := json.NewDecoder(res.Body).Decode(&errRes); err != nil || errRes.Error == "" {
+			errRes.Error = http.StatusText(res.StatusCode)
+		}
+
+		return fmt.Errorf("cache server: %s (status %d)", errRes.Error, res.StatusCode)
+	}
+
+	if out == nil {
+		// Drain the body so that the connection is reused
+		_, _ = io.Copy(io.Discard, res.Body)
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// onError reports a lookup error.
+func (e *RemoteEngine[T]) onError(err error) {
+	if e.opts.OnError != nil {
+		e.opts.OnError(err)
+	}
+}
@@ -0,0 +1,153 @@
+package llmcache_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/hupe1980/go-llmcache/server"
+	"github.com/stretchr/testify/assert"
+)
+
+type answer struct {
+	Text   string
+	Tokens int
+}
+
+func TestRemoteEngine(t *testing.T) {
+	ctx := context.TODO()
+
+	newRemote := func(t *testing.T, engine llmcache.Engine[string], optFns ...func(o *llmcache.RemoteEngineOptions[answer])) *llmcache.RemoteEngine[answer] {
+		t.Helper()
+
+		ts := httptest.NewServer(server.New(engine, func(o *server.Options) {
+			o.APIKey = "secret"
+		}))
+		t.Cleanup(ts.Close)
+
+		remote, err := llmcache.NewRemoteEngine[answer](ts.URL, append([]func(o *llmcache.RemoteEngineOptions[answer]){
+			func(o *llmcache.RemoteEngineOptions[answer]) {
+				o.APIKey = "secret"
+			},
+		}, optFns...)...)
+		assert.NoError(t, err)
+
+		return remote
+	}
+
+	t.Run("Exact", func(t *testing.T) {
+		engine, err := llmcache.NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		remote := newRemote(t, engine)
+
+		_, ok := remote.Lookup(ctx, "What year was Einstein born?")
+		assert.False(t, ok)
+
+		err = remote.Update(ctx, "What year was Einstein born?", answer{Text: "1879", Tokens: 2})
+		assert.NoError(t, err)
+
+		result, ok := remote.Lookup(ctx, "What year was Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, answer{Text: "1879", Tokens: 2}, result)
+
+		err = remote.Delete(ctx, "What year was Einstein born?")
+		assert.NoError(t, err)
+
+		_, ok = remote.Lookup(ctx, "What year was Einstein born?")
+		assert.False(t, ok)
+
+		stats, err := remote.Stats(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, llmcache.Stats{Lookups: 3, Hits: 1, Misses: 2, Updates: 1, Deletes: 1}, stats)
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		engine, err := llmcache.NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		remote := newRemote(t, engine)
+		tenantA := llmcache.WithNamespace(ctx, "tenant-a")
+		tenantB := llmcache.WithNamespace(ctx, "tenant-b")
+
+		assert.NoError(t, remote.Update(tenantA, "What year was Einstein born?", answer{Text: "1879"}))
+
+		_, ok := remote.Lookup(tenantB, "What year was Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = remote.Lookup(ctx, "What year was Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = remote.Lookup(tenantA, "What year was Einstein born?")
+		assert.True(t, ok)
+
+		assert.NoError(t, remote.Update(ctx, "What year was Einstein born?", answer{Text: "1879"}))
+		assert.NoError(t, remote.ClearNamespace(ctx, "tenant-a"))
+
+		_, ok = remote.Lookup(tenantA, "What year was Einstein born?")
+		assert.False(t, ok)
+
+		_, ok = remote.Lookup(ctx, "What year was Einstein born?")
+		assert.True(t, ok)
+	})
+
+	t.Run("Semantic", func(t *testing.T) {
+		engine, err := llmcache.NewLRUSimilarityEngine[string](llmcache.NewHashEmbedder(), func(o *llmcache.LRUSimilarityEngineOptions) {
+			o.Threshold = 0.5
+		})
+		assert.NoError(t, err)
+
+		remote := newRemote(t, engine)
+
+		err = remote.Update(ctx, "What year was Albert Einstein born?", answer{Text: "1879"})
+		assert.NoError(t, err)
+
+		match, ok := remote.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, answer{Text: "1879"}, match.Result)
+		assert.Equal(t, "What year was Albert Einstein born?", match.Prompt)
+		assert.False(t, match.Exact)
+		assert.Greater(t, match.Distance, float32(0))
+
+		err = remote.Clear(ctx)
+		assert.NoError(t, err)
+
+		_, ok = remote.Lookup(ctx, "What year was Albert Einstein born?")
+		assert.False(t, ok)
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		engine, err := llmcache.NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		var lookupErr error
+
+		remote := newRemote(t, engine, func(o *llmcache.RemoteEngineOptions[answer]) {
+			o.APIKey = "wrong"
+			o.OnError = func(err error) {
+				lookupErr = err
+			}
+		})
+
+		err = remote.Update(ctx, "prompt", answer{})
+		assert.ErrorContains(t, err, "status 401")
+
+		_, ok := remote.Lookup(ctx, "prompt")
+		assert.False(t, ok)
+		assert.ErrorContains(t, lookupErr, "invalid API key")
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		remote, err := llmcache.NewRemoteEngine[answer]("http://192.0.2.1", func(o *llmcache.RemoteEngineOptions[answer]) {
+			o.Timeout = 50 * time.Millisecond
+		})
+		assert.NoError(t, err)
+
+		start := time.Now()
+		err = remote.Update(ctx, "prompt", answer{})
+		assert.Error(t, err)
+		assert.Less(t, time.Since(start), 2*time.Second)
+	})
+}
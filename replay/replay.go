@@ -0,0 +1,164 @@
+// Package replay simulates a cache configuration by replaying a log of prompts through an engine.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hupe1980/go-llmcache"
+)
+
+// Record is a logged LLM request.
+type Record struct {
+	// Timestamp is the time of the request.
+	Timestamp time.Time `json:"timestamp"`
+	// Prompt is the prompt of the request.
+	Prompt string `json:"prompt"`
+	// Response is the response of the LLM.
+	Response string `json:"response"`
+	// Cost is the cost of the request, e.g. in dollars or tokens.
+	Cost float64 `json:"cost"`
+	// Key is the optional ground truth: requests with the same key can be answered with the same response.
+	Key string `json:"key,omitempty"`
+}
+
+// ReadRecords reads a JSONL log of requests, one record per line. Empty lines are skipped.
+func ReadRecords(r io.Reader) ([]Record, error) {
+	var records []Record
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		var record Record
+		if err := json.Unmarshal([]byte(text), &record); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+
+		records = append(records, record)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// Report summarizes a replay.
+type Report struct {
+	// Requests is the number of replayed requests.
+	Requests int `json:"requests"`
+	// Hits is the number of requests answered from the cache.
+	Hits int `json:"hits"`
+	// SemanticHits is the number of hits whose prompt was not cached exactly.
+	SemanticHits int `json:"semanticHits"`
+	// HitRate is the fraction of requests answered from the cache.
+	HitRate float64 `json:"hitRate"`
+	// SemanticHitRate is the fraction of requests answered by a similar cached prompt.
+	SemanticHitRate float64 `json:"semanticHitRate"`
+	// TotalCost is the cost of all requests without a cache.
+	TotalCost float64 `json:"totalCost"`
+	// CostSaved is the cost of the requests answered from the cache.
+	CostSaved float64 `json:"costSaved"`
+	// LabelledHits is the number of hits for which the ground truth is available.
+	LabelledHits int `json:"labelledHits"`
+	// FalseHits is the number of labelled hits whose cached prompt has a different key.
+	FalseHits int `json:"falseHits"`
+	// FalseHitRate is the fraction of labelled hits which are false hits.
+	FalseHitRate float64 `json:"falseHitRate"`
+	// Errors is the number of failed cache updates.
+	Errors int `json:"errors"`
+}
+
+// Run replays the records in order through the engine. Each request is looked up first,
+// on a miss the logged response is written to the cache. If the engine implements
+// llmcache.Matcher, the matched prompt is used to detect semantic and false hits,
+// otherwise they are derived from the returned response.
+func Run(ctx context.Context, engine llmcache.Engine[string], records []Record) (*Report, error) {
+	matcher, _ := engine.(llmcache.Matcher[string])
+
+	var report Report
+
+	// Ground truth of the cached prompts and responses
+	promptKeys := make(map[string]string)
+	responseKeys := make(map[string]string)
+
+	for _, record := range records {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		report.Requests++
+		report.TotalCost += record.Cost
+
+		var (
+			match llmcache.Match[string]
+			ok    bool
+		)
+
+		if matcher != nil {
+			match, ok = matcher.LookupMatch(ctx, record.Prompt)
+		} else {
+			match.Result, ok = engine.Lookup(ctx, record.Prompt)
+			_, match.Exact = promptKeys[record.Prompt]
+		}
+
+		if !ok {
+			if err := engine.Update(ctx, record.Prompt, record.Response); err != nil {
+				report.Errors++
+				continue
+			}
+
+			promptKeys[record.Prompt] = record.Key
+			responseKeys[record.Response] = record.Key
+
+			continue
+		}
+
+		report.Hits++
+		report.CostSaved += record.Cost
+
+		if !match.Exact {
+			report.SemanticHits++
+		}
+
+		cachedKey, labelled := responseKeys[match.Result]
+		if matcher != nil {
+			cachedKey, labelled = promptKeys[match.Prompt]
+		}
+
+		if labelled && cachedKey != "" && record.Key != "" {
+			report.LabelledHits++
+
+			if cachedKey != record.Key {
+				report.FalseHits++
+			}
+		}
+	}
+
+	if report.Requests > 0 {
+		report.HitRate = float64(report.Hits) / float64(report.Requests)
+		report.SemanticHitRate = float64(report.SemanticHits) / float64(report.Requests)
+	}
+
+	if report.LabelledHits > 0 {
+		report.FalseHitRate = float64(report.FalseHits) / float64(report.LabelledHits)
+	}
+
+	return &report, nil
+}
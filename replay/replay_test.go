@@ -0,0 +1,101 @@
+package replay
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/stretchr/testify/assert"
+)
+
+const log = `{"timestamp": "2024-05-01T10:00:00Z", "prompt": "What year was Albert Einstein born?", "response": "1879", "cost": 0.5, "key": "einstein"}
+{"timestamp": "2024-05-01T10:01:00Z", "prompt": "What year was Albert Einstein born?", "response": "1879", "cost": 0.5, "key": "einstein"}
+
+{"timestamp": "2024-05-01T10:02:00Z", "prompt": "In what year was Albert Einstein born?", "response": "1879", "cost": 0.5, "key": "einstein"}
+{"timestamp": "2024-05-01T10:03:00Z", "prompt": "What year was Albert Einstein buried?", "response": "1955", "cost": 0.5, "key": "einstein-death"}
+{"timestamp": "2024-05-01T10:04:00Z", "prompt": "How do I bake sourdough bread?", "response": "...", "cost": 1}
+`
+
+func TestReadRecords(t *testing.T) {
+	records, err := ReadRecords(strings.NewReader(log))
+	assert.NoError(t, err)
+	assert.Len(t, records, 5)
+	assert.Equal(t, "What year was Albert Einstein born?", records[0].Prompt)
+	assert.Equal(t, 0.5, records[0].Cost)
+	assert.Equal(t, "einstein", records[0].Key)
+	assert.Equal(t, 2024, records[0].Timestamp.Year())
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ReadRecords(strings.NewReader(`{"prompt": 1}`))
+		assert.ErrorContains(t, err, "line 1")
+	})
+}
+
+// lookupOnly hides the Matcher implementation of an engine.
+type lookupOnly struct {
+	llmcache.Engine[string]
+}
+
+func TestRun(t *testing.T) {
+	records, err := ReadRecords(strings.NewReader(log))
+	assert.NoError(t, err)
+
+	ctx := context.TODO()
+
+	t.Run("Exact", func(t *testing.T) {
+		engine, err := llmcache.NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		report, err := Run(ctx, engine, records)
+		assert.NoError(t, err)
+		assert.Equal(t, 5, report.Requests)
+		assert.Equal(t, 1, report.Hits)
+		assert.Equal(t, 0, report.SemanticHits)
+		assert.Equal(t, 0.2, report.HitRate)
+		assert.Equal(t, 3.0, report.TotalCost)
+		assert.Equal(t, 0.5, report.CostSaved)
+		assert.Equal(t, 1, report.LabelledHits)
+		assert.Equal(t, 0, report.FalseHits)
+	})
+
+	for _, wrap := range []bool{false, true} {
+		name := "Semantic"
+		if wrap {
+			name = "Semantic Without Matcher"
+		}
+
+		t.Run(name, func(t *testing.T) {
+			similarity, err := llmcache.NewLRUSimilarityEngine[string](llmcache.NewHashEmbedder(), func(o *llmcache.LRUSimilarityEngineOptions) {
+				o.Threshold = 0.5
+			})
+			assert.NoError(t, err)
+
+			var engine llmcache.Engine[string] = similarity
+			if wrap {
+				engine = lookupOnly{similarity}
+			}
+
+			report, err := Run(ctx, engine, records)
+			assert.NoError(t, err)
+			assert.Equal(t, 3, report.Hits)
+			assert.Equal(t, 2, report.SemanticHits)
+			assert.InDelta(t, 0.4, report.SemanticHitRate, 1e-9)
+			assert.Equal(t, 1.5, report.CostSaved)
+			assert.Equal(t, 3, report.LabelledHits)
+			assert.Equal(t, 1, report.FalseHits)
+			assert.InDelta(t, 1.0/3, report.FalseHitRate, 1e-9)
+		})
+	}
+
+	t.Run("Canceled", func(t *testing.T) {
+		engine, err := llmcache.NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err = Run(ctx, engine, records)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,107 @@
+package llmcache
+
+import (
+	"context"
+	"time"
+)
+
+// RerankCandidate is a cached prompt passed to a Reranker.
+type RerankCandidate struct {
+	// Prompt is the cached prompt.
+	Prompt string
+	// Distance is the distance between the query and the cached prompt.
+	Distance float32
+}
+
+// Reranker decides which cached prompts are a match for a prompt, e.g. with a cross-encoder or an LLM judge.
+type Reranker interface {
+	// Rerank returns the accepted candidates ordered from best to worst.
+	// Candidates which are not returned are rejected.
+	Rerank(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error)
+}
+
+// RerankerFunc is an adapter to allow the use of ordinary functions as Reranker.
+type RerankerFunc func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error)
+
+// Rerank calls f(ctx, prompt, candidates).
+func (f RerankerFunc) Rerank(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+	return f(ctx, prompt, candidates)
+}
+
+// RerankOptions contains options for configuring the re-ranking of the LRUSimilarityEngine.
+// Candidates closer than MinDistance are accepted without re-ranking. If there is no such candidate,
+// the closest candidates within the grey zone between MinDistance and MaxDistance are passed to the Reranker.
+type RerankOptions struct {
+	// Reranker decides which candidates of the grey zone are accepted.
+	Reranker Reranker
+	// MinDistance is the lower bound of the grey zone. Default is 0.
+	MinDistance float32
+	// MaxDistance is the upper bound of the grey zone. Default is the threshold.
+	MaxDistance float32
+	// TopK is the per-lookup budget of candidates passed to the Reranker. Default is 5.
+	TopK int
+	// Timeout is the maximum duration of the re-ranking. Default is no timeout.
+	Timeout time.Duration
+	// RejectOnFailure is a boolean flag indicating whether the grey zone candidates are rejected if the
+	// Reranker fails or times out. By default the closest candidate within the threshold is accepted.
+	RejectOnFailure bool
+}
+
+// rerank passes the candidates to the reranker and returns the accepted candidates in the order of the reranker.
+// The reranker runs in a separate goroutine, so that the timeout is kept even if the reranker ignores the context.
+func rerank[T comparable](ctx context.Context, opts *RerankOptions, prompt string, candidates []candidate[T]) ([]candidate[T], error) {
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	// The candidates are of the same namespace, so the reranker sees the prompts without it
+	rc := make([]RerankCandidate, len(candidates))
+	for i, c := range candidates {
+		_, p := splitNamespaceKey(c.prompt)
+		rc[i] = RerankCandidate{Prompt: p, Distance: c.distance}
+	}
+
+	type result struct {
+		ranked []RerankCandidate
+		err    error
+	}
+
+	done := make(chan result, 1)
+
+	go func() {
+		ranked, err := opts.Reranker.Rerank(ctx, prompt, rc)
+		done <- result{ranked: ranked, err: err}
+	}()
+
+	var res result
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res = <-done:
+		if res.err != nil {
+			return nil, res.err
+		}
+	}
+
+	byPrompt := make(map[string]candidate[T], len(candidates))
+	for _, c := range candidates {
+		_, p := splitNamespaceKey(c.prompt)
+		byPrompt[p] = c
+	}
+
+	accepted := make([]candidate[T], 0, len(res.ranked))
+
+	for _, r := range res.ranked {
+		// Ignore unknown or duplicate prompts
+		if c, ok := byPrompt[r.Prompt]; ok {
+			accepted = append(accepted, c)
+			delete(byPrompt, r.Prompt)
+		}
+	}
+
+	return accepted, nil
+}
@@ -0,0 +1,121 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLRUSimilarityEngine_Rerank(t *testing.T) {
+	mockEmbedder := &mockEmbedder{
+		embeddings: map[string][]float32{
+			"query": {1, 0, 0, 0},
+			"a":     {1, 0.1, 0, 0}, // distance ~0.005
+			"b":     {1, 0.3, 0, 0}, // distance ~0.042
+			"c":     {1, 0.6, 0, 0}, // distance ~0.14
+			"d":     {1, 1, 0, 0},   // distance ~0.29
+		},
+	}
+
+	reverse := RerankerFunc(func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+		reversed := make([]RerankCandidate, 0, len(candidates))
+		for i := len(candidates) - 1; i >= 0; i-- {
+			reversed = append(reversed, candidates[i])
+		}
+
+		return reversed, nil
+	})
+
+	reject := RerankerFunc(func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+		return nil, nil
+	})
+
+	failing := RerankerFunc(func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+		return nil, errors.New("reranker failed")
+	})
+
+	blocking := RerankerFunc(func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+		time.Sleep(time.Second) // ignores the context
+		return candidates, nil
+	})
+
+	tests := []struct {
+		name     string
+		prompts  []string
+		rerank   RerankOptions
+		expected string
+		ok       bool
+	}{
+		{"Reorder", []string{"b", "c"}, RerankOptions{Reranker: reverse}, "c", true},
+		{"Reject", []string{"b", "c"}, RerankOptions{Reranker: reject}, "", false},
+		{"Accept Without Reranking", []string{"a", "c"}, RerankOptions{Reranker: reject, MinDistance: 0.01}, "a", true},
+		{"Beyond Threshold", []string{"d"}, RerankOptions{Reranker: reverse, MaxDistance: 0.5}, "d", true},
+		{"TopK", []string{"b", "c", "d"}, RerankOptions{Reranker: reverse, MaxDistance: 0.5, TopK: 2}, "c", true},
+		{"Failure", []string{"b", "c"}, RerankOptions{Reranker: failing}, "b", true},
+		{"Failure Beyond Threshold", []string{"d"}, RerankOptions{Reranker: failing, MaxDistance: 0.5}, "", false},
+		{"Reject On Failure", []string{"b", "c"}, RerankOptions{Reranker: failing, RejectOnFailure: true}, "", false},
+		{"Timeout", []string{"b", "c"}, RerankOptions{Reranker: blocking, Timeout: 10 * time.Millisecond}, "b", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+				o.Rerank = &tt.rerank
+			})
+			assert.NoError(t, err)
+
+			ctx := context.TODO()
+
+			for _, prompt := range tt.prompts {
+				err = cache.Update(ctx, prompt, prompt)
+				assert.NoError(t, err)
+			}
+
+			start := time.Now()
+
+			result, ok := cache.Lookup(ctx, "query")
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.expected, result)
+			assert.Less(t, time.Since(start), 500*time.Millisecond)
+		})
+	}
+
+	t.Run("Candidates", func(t *testing.T) {
+		var got []RerankCandidate
+
+		cache, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Rerank = &RerankOptions{
+				Reranker: RerankerFunc(func(ctx context.Context, prompt string, candidates []RerankCandidate) ([]RerankCandidate, error) {
+					assert.Equal(t, "query", prompt)
+
+					got = candidates
+
+					return candidates, nil
+				}),
+			}
+		})
+		assert.NoError(t, err)
+
+		for _, prompt := range []string{"d", "c", "b"} {
+			err = cache.Update(context.TODO(), prompt, prompt)
+			assert.NoError(t, err)
+		}
+
+		_, ok := cache.Lookup(context.TODO(), "query")
+		assert.True(t, ok)
+		assert.Len(t, got, 2)
+		assert.Equal(t, "b", got[0].Prompt)
+		assert.Equal(t, "c", got[1].Prompt)
+		assert.Less(t, got[0].Distance, got[1].Distance)
+	})
+
+	t.Run("Missing Reranker", func(t *testing.T) {
+		_, err := NewLRUSimilarityEngine[string](mockEmbedder, func(o *LRUSimilarityEngineOptions) {
+			o.Rerank = &RerankOptions{}
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,233 @@
+// Package server exposes a cache engine over an HTTP+JSON API, so that several services
+// share one cache. The llmcache.RemoteEngine is the client of the API.
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"sync/atomic"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/hupe1980/go-llmcache/internal/wire"
+)
+
+// Options contains options for configuring the Server.
+type Options struct {
+	// APIKey is the key clients must send as bearer token. If empty, requests are not authenticated.
+	APIKey string
+	// MaxBodySize is the maximum size of a request body. Default is 10 MiB.
+	MaxBodySize int64
+}
+
+// Server is an http.Handler serving the lookups, updates, deletes and clears of an engine.
+// Results are stored as encoded by the clients.
+type Server struct {
+	engine  llmcache.Engine[string]
+	cache   *llmcache.LLMCache[string]
+	opts    Options
+	mux     *http.ServeMux
+	lookups atomic.Int64
+	hits    atomic.Int64
+	updates atomic.Int64
+	deletes atomic.Int64
+}
+
+// New creates a new Server instance for the engine.
+func New(engine llmcache.Engine[string], optFns ...func(o *Options)) *Server {
+	opts := Options{
+		MaxBodySize: 10 << 20,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.MaxBodySize <= 0 {
+		opts.MaxBodySize = 10 << 20
+	}
+
+	s := &Server{
+		engine: engine,
+		cache:  llmcache.New(engine),
+		opts:   opts,
+		mux:    http.NewServeMux(),
+	}
+
+	s.mux.HandleFunc("POST "+wire.PathLookup, s.lookup)
+	s.mux.HandleFunc("POST "+wire.PathUpdate, s.update)
+	s.mux.HandleFunc("POST "+wire.PathDelete, s.delete)
+	s.mux.HandleFunc("POST "+wire.PathClear, s.clear)
+	s.mux.HandleFunc("GET "+wire.PathStats, s.stats)
+
+	return s
+}
+
+// ServeHTTP authenticates the request and dispatches it to the handler of its path.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if s.opts.APIKey != "" {
+		if subtle.ConstantTimeCompare([]byte(r.Header.Get("Authorization")), []byte("Bearer "+s.opts.APIKey)) != 1 {
+			writeError(w, http.StatusUnauthorized, errors.New("invalid API key"))
+			return
+		}
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, s.opts.MaxBodySize)
+
+	if namespace := r.Header.Get(wire.HeaderNamespace); namespace != "" {
+		r = r.WithContext(llmcache.WithNamespace(r.Context(), namespace))
+	}
+
+	s.mux.ServeHTTP(w, r)
+}
+
+// Stats returns the usage statistics of the server. The number of entries
+// is only reported if the engine has a Len method.
+func (s *Server) Stats() llmcache.Stats {
+	lookups, hits := s.lookups.Load(), s.hits.Load()
+
+	stats := llmcache.Stats{
+		Lookups: lookups,
+		Hits:    hits,
+		Misses:  lookups - hits,
+		Updates: s.updates.Load(),
+		Deletes: s.deletes.Load(),
+	}
+
+	if l, ok := s.engine.(interface{ Len() int }); ok {
+		stats.Entries = l.Len()
+	}
+
+	return stats
+}
+
+func (s *Server) lookup(w http.ResponseWriter, r *http.Request) {
+	var req wire.PromptRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	s.lookups.Add(1)
+
+	match, ok := s.cache.LookupMatch(r.Context(), req.Prompt)
+	if !ok {
+		writeJSON(w, http.StatusOK, wire.LookupResponse{})
+		return
+	}
+
+	s.hits.Add(1)
+
+	writeJSON(w, http.StatusOK, wire.LookupResponse{
+		Found:    true,
+		Result:   []byte(match.Result),
+		Prompt:   match.Prompt,
+		Distance: match.Distance,
+		Exact:    match.Exact,
+	})
+}
+
+func (s *Server) update(w http.ResponseWriter, r *http.Request) {
+	var req wire.UpdateRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	if err := s.engine.Update(r.Context(), req.Prompt, string(req.Result)); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.updates.Add(1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	var req wire.PromptRequest
+	if !readJSON(w, r, &req) {
+		return
+	}
+
+	deleter, ok := s.engine.(llmcache.Deleter)
+	if !ok {
+		writeError(w, http.StatusNotImplemented, errors.New("engine does not support delete"))
+		return
+	}
+
+	if err := deleter.Delete(r.Context(), req.Prompt); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	s.deletes.Add(1)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) clear(w http.ResponseWriter, r *http.Request) {
+	if namespace := r.Header.Get(wire.HeaderNamespace); namespace != "" {
+		s.clearNamespace(w, r, namespace)
+		return
+	}
+
+	if err := s.engine.Clear(r.Context()); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) clearNamespace(w http.ResponseWriter, r *http.Request, namespace string) {
+	clearer, ok := s.engine.(interface {
+		ClearNamespace(ctx context.Context, namespace string) error
+	})
+	if !ok {
+		writeError(w, http.StatusNotImplemented, llmcache.ErrNamespacesNotSupported)
+		return
+	}
+
+	if err := clearer.ClearNamespace(r.Context(), namespace); err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) stats(w http.ResponseWriter, _ *http.Request) {
+	writeJSON(w, http.StatusOK, s.Stats())
+}
+
+// readJSON decodes the request body and writes an error response if it is invalid.
+func readJSON(w http.ResponseWriter, r *http.Request, v any) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		status := http.StatusBadRequest
+
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			status = http.StatusRequestEntityTooLarge
+		}
+
+		if errors.Is(err, io.EOF) {
+			err = errors.New("empty request body")
+		}
+
+		writeError(w, status, err)
+
+		return false
+	}
+
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, wire.ErrorResponse{Error: err.Error()})
+}
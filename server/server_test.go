@@ -0,0 +1,96 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/hupe1980/go-llmcache"
+	"github.com/stretchr/testify/assert"
+)
+
+// lookupOnly hides all optional interfaces of an engine.
+type lookupOnly struct {
+	llmcache.Engine[string]
+}
+
+func serve(s *Server, method, path, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(method, path, strings.NewReader(body)))
+
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	engine, err := llmcache.NewLRUEngine[string]()
+	assert.NoError(t, err)
+
+	t.Run("Lookup And Update", func(t *testing.T) {
+		s := New(engine)
+
+		rec := serve(s, http.MethodPost, "/v1/update", `{"prompt": "p", "result": "MTg3OQ=="}`)
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+
+		rec = serve(s, http.MethodPost, "/v1/lookup", `{"prompt": "p"}`)
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.JSONEq(t, `{"found": true, "result": "MTg3OQ==", "prompt": "p", "exact": true}`, rec.Body.String())
+
+		rec = serve(s, http.MethodPost, "/v1/lookup", `{"prompt": "q"}`)
+		assert.JSONEq(t, `{"found": false}`, rec.Body.String())
+
+		rec = serve(s, http.MethodGet, "/v1/stats", "")
+		assert.JSONEq(t, `{"lookups": 2, "hits": 1, "misses": 1, "updates": 1, "deletes": 0, "entries": 1}`, rec.Body.String())
+
+		rec = serve(s, http.MethodPost, "/v1/clear", "")
+		assert.Equal(t, http.StatusNoContent, rec.Code)
+		assert.Equal(t, 0, engine.Len())
+	})
+
+	t.Run("Invalid Requests", func(t *testing.T) {
+		s := New(engine, func(o *Options) {
+			o.MaxBodySize = 16
+		})
+
+		rec := serve(s, http.MethodPost, "/v1/lookup", `{`)
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+		rec = serve(s, http.MethodPost, "/v1/lookup", "")
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "empty request body")
+
+		rec = serve(s, http.MethodPost, "/v1/lookup", `{"prompt": "a long prompt"}`)
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+
+		rec = serve(s, http.MethodGet, "/v1/lookup", "")
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	})
+
+	t.Run("Delete Not Supported", func(t *testing.T) {
+		s := New(lookupOnly{engine})
+
+		rec := serve(s, http.MethodPost, "/v1/delete", `{"prompt": "p"}`)
+		assert.Equal(t, http.StatusNotImplemented, rec.Code)
+
+		// Entries are only reported for engines with a Len method
+		_ = engine.Update(context.TODO(), "p", "r")
+		assert.Equal(t, 0, s.Stats().Entries)
+	})
+
+	t.Run("API Key", func(t *testing.T) {
+		s := New(engine, func(o *Options) {
+			o.APIKey = "secret"
+		})
+
+		rec := serve(s, http.MethodGet, "/v1/stats", "")
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+		req := httptest.NewRequest(http.MethodGet, "/v1/stats", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+
+		rec = httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+}
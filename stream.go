@@ -0,0 +1,379 @@
+package llmcache
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recording is a recorded stream of chunks, e.g. the tokens of an LLM response.
+type Recording[C any] struct {
+	// Chunks are the chunks of the stream in order.
+	Chunks []C
+	// Offsets are the times of the chunks relative to the start of the stream.
+	Offsets []time.Duration
+}
+
+// StreamFunc produces the chunks of a stream by calling send for each chunk.
+// It returns an error if the stream fails; the stream is only cached if it returns nil.
+type StreamFunc[C any] func(ctx context.Context, send func(chunk C) error) error
+
+// StreamSource describes where the chunks of a stream come from.
+type StreamSource int
+
+const (
+	// StreamMiss indicates a stream produced by the StreamFunc and written to the cache.
+	StreamMiss StreamSource = iota
+	// StreamShared indicates a stream shared with an identical request in progress.
+	StreamShared
+	// StreamHit indicates a stream replayed from the cache.
+	StreamHit
+)
+
+// String returns the name of the source.
+func (s StreamSource) String() string {
+	switch s {
+	case StreamMiss:
+		return "miss"
+	case StreamShared:
+		return "shared"
+	case StreamHit:
+		return "hit"
+	default:
+		return "unknown"
+	}
+}
+
+// StreamCacheOptions contains options for configuring the StreamCache.
+type StreamCacheOptions struct {
+	// Pacing scales the recorded offsets of the chunks when replaying a cached stream,
+	// e.g. 1 replays in real time and 0.5 twice as fast. Default is 0, which replays without delay.
+	Pacing float64
+	// OnError is called with the errors of writing recordings to the cache. The stream
+	// still ends successfully, since all its chunks were delivered.
+	OnError func(err error)
+}
+
+// StreamCache caches streams of chunks. A miss tees the produced chunks into the cache,
+// identical requests in progress subscribe to the same stream and hits replay the recorded chunks.
+type StreamCache[C any] struct {
+	engine Engine[*Recording[C]]
+	opts   StreamCacheOptions
+	mu     sync.Mutex
+	// inflight holds the streams in progress by namespaced prompt, see namespaceKey.
+	inflight map[string]*broadcast[C]
+}
+
+// NewStreamCache creates a new StreamCache instance with the provided engine.
+func NewStreamCache[C any](engine Engine[*Recording[C]], optFns ...func(o *StreamCacheOptions)) *StreamCache[C] {
+	opts := StreamCacheOptions{}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.Pacing < 0 {
+		opts.Pacing = 0
+	}
+
+	return &StreamCache[C]{
+		engine:   engine,
+		opts:     opts,
+		inflight: make(map[string]*broadcast[C]),
+	}
+}
+
+// Stream returns the stream of the given prompt. On a miss, fn is called in a separate goroutine
+// to produce the chunks. The producer is canceled when all subscribers closed the stream before it ended.
+// Only requests of the same namespace share a stream in progress.
+func (c *StreamCache[C]) Stream(ctx context.Context, prompt string, fn StreamFunc[C]) *Stream[C] {
+	key := namespaceKey(NamespaceFromContext(ctx), prompt)
+
+	if s, ok := c.subscribe(key); ok {
+		return s
+	}
+
+	if recording, ok := c.engine.Lookup(ctx, prompt); ok && recording != nil {
+		b := &broadcast[C]{
+			chunks:  recording.Chunks,
+			offsets: recording.Offsets,
+			done:    true,
+			notify:  make(chan struct{}),
+		}
+
+		return &Stream[C]{b: b, source: StreamHit, pacing: c.opts.Pacing}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	// An identical request may have started in the meantime
+	if b, ok := c.inflight[key]; ok && b.subscribe() {
+		return &Stream[C]{b: b, source: StreamShared}
+	}
+
+	producerCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+
+	b := &broadcast[C]{
+		notify:      make(chan struct{}),
+		subscribers: 1,
+		cancel:      cancel,
+	}
+
+	c.inflight[key] = b
+
+	go c.produce(producerCtx, key, prompt, b, fn)
+
+	return &Stream[C]{b: b, source: StreamMiss}
+}
+
+// Clear clears the cache, removing all recorded streams. Streams in progress are not affected.
+func (c *StreamCache[C]) Clear(ctx context.Context) error {
+	return c.engine.Clear(ctx)
+}
+
+// subscribe subscribes to the stream in progress with the namespaced key.
+func (c *StreamCache[C]) subscribe(key string) (*Stream[C], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	b, ok := c.inflight[key]
+	if !ok || !b.subscribe() {
+		return nil, false
+	}
+
+	return &Stream[C]{b: b, source: StreamShared}, true
+}
+
+// produce runs the producer, broadcasts its chunks and caches the recording if the producer
+// succeeded without being canceled.
+// The key is the namespaced prompt of the stream in progress. Failures to cache the
+// recording are reported to OnError and do not fail the stream.
+func (c *StreamCache[C]) produce(ctx context.Context, key, prompt string, b *broadcast[C], fn StreamFunc[C]) {
+	defer b.cancel()
+
+	start := time.Now()
+
+	err := fn(ctx, func(chunk C) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		b.append(chunk, time.Since(start))
+
+		return nil
+	})
+
+	// A canceled producer may ignore the error of send, so its stream may be truncated
+	if err == nil && ctx.Err() == nil {
+		b.mu.Lock()
+		recording := &Recording[C]{Chunks: b.chunks, Offsets: b.offsets}
+		b.mu.Unlock()
+
+		if updateErr := c.engine.Update(ctx, prompt, recording); updateErr != nil && c.opts.OnError != nil {
+			c.opts.OnError(updateErr)
+		}
+	}
+
+	c.mu.Lock()
+	if c.inflight[key] == b {
+		delete(c.inflight, key)
+	}
+	c.mu.Unlock()
+
+	b.finish(err)
+}
+
+// Stream is a stream of chunks. It is not safe for concurrent use.
+type Stream[C any] struct {
+	b      *broadcast[C]
+	source StreamSource
+	pacing float64
+	next   int
+	start  time.Time
+	closed bool
+}
+
+// Source returns where the chunks of the stream come from.
+func (s *Stream[C]) Source() StreamSource {
+	return s.source
+}
+
+// Next returns the next chunk of the stream. It blocks until the chunk is available
+// and returns io.EOF at the end of the stream or the error of the producer.
+func (s *Stream[C]) Next(ctx context.Context) (C, error) {
+	var zero C
+
+	if s.closed {
+		return zero, io.ErrClosedPipe
+	}
+
+	for {
+		s.b.mu.Lock()
+
+		if s.next < len(s.b.chunks) {
+			chunk, offset := s.b.chunks[s.next], s.b.offsets[s.next]
+			s.next++
+			s.b.mu.Unlock()
+
+			if err := s.pace(ctx, offset); err != nil {
+				return zero, err
+			}
+
+			return chunk, nil
+		}
+
+		if s.b.done {
+			err := s.b.err
+			s.b.mu.Unlock()
+
+			if err == nil {
+				err = io.EOF
+			}
+
+			return zero, err
+		}
+
+		notify := s.b.notify
+		s.b.mu.Unlock()
+
+		select {
+		case <-notify:
+		case <-ctx.Done():
+			return zero, ctx.Err()
+		}
+	}
+}
+
+// Chan returns a channel receiving the remaining chunks of the stream. The channel is
+// closed at the end of the stream, if the context is canceled or if the producer fails.
+// The stream is closed afterwards.
+func (s *Stream[C]) Chan(ctx context.Context) <-chan C {
+	ch := make(chan C)
+
+	go func() {
+		defer close(ch)
+		defer s.Close()
+
+		for {
+			chunk, err := s.Next(ctx)
+			if err != nil {
+				return
+			}
+
+			select {
+			case ch <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return ch
+}
+
+// Close unsubscribes from the stream. A producer without subscribers is canceled.
+func (s *Stream[C]) Close() error {
+	if s.closed {
+		return nil
+	}
+
+	s.closed = true
+
+	if s.source != StreamHit {
+		s.b.unsubscribe()
+	}
+
+	return nil
+}
+
+// pace delays a replayed chunk until its scaled offset.
+func (s *Stream[C]) pace(ctx context.Context, offset time.Duration) error {
+	if s.source != StreamHit || s.pacing == 0 {
+		return nil
+	}
+
+	if s.start.IsZero() {
+		s.start = time.Now()
+	}
+
+	wait := time.Until(s.start.Add(time.Duration(float64(offset) * s.pacing)))
+	if wait <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// broadcast holds the chunks of a stream for all its subscribers.
+type broadcast[C any] struct {
+	mu      sync.Mutex
+	chunks  []C
+	offsets []time.Duration
+	done    bool
+	err     error
+	// notify is closed and replaced whenever a chunk is appended or the stream ends.
+	notify      chan struct{}
+	subscribers int
+	canceled    bool
+	cancel      context.CancelFunc
+}
+
+// subscribe adds a subscriber unless the producer was canceled.
+func (b *broadcast[C]) subscribe() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.canceled {
+		return false
+	}
+
+	b.subscribers++
+
+	return true
+}
+
+// unsubscribe removes a subscriber and cancels the producer of an unfinished stream without subscribers.
+func (b *broadcast[C]) unsubscribe() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.subscribers--
+
+	if b.subscribers == 0 && !b.done {
+		b.canceled = true
+		b.cancel()
+	}
+}
+
+// append adds a chunk and wakes up the subscribers.
+func (b *broadcast[C]) append(chunk C, offset time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.chunks = append(b.chunks, chunk)
+	b.offsets = append(b.offsets, offset)
+
+	close(b.notify)
+	b.notify = make(chan struct{})
+}
+
+// finish ends the stream with the error of the producer.
+func (b *broadcast[C]) finish(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.done = true
+	b.err = err
+
+	close(b.notify)
+}
@@ -0,0 +1,306 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// collect reads all chunks of a stream.
+func collect[C any](t *testing.T, s *Stream[C]) ([]C, error) {
+	t.Helper()
+
+	var chunks []C
+
+	for {
+		chunk, err := s.Next(context.TODO())
+		if errors.Is(err, io.EOF) {
+			return chunks, nil
+		}
+
+		if err != nil {
+			return chunks, err
+		}
+
+		chunks = append(chunks, chunk)
+	}
+}
+
+func TestStreamCache(t *testing.T) {
+	ctx := context.TODO()
+
+	newCache := func(optFns ...func(o *StreamCacheOptions)) *StreamCache[string] {
+		engine, err := NewLRUEngine[*Recording[string]]()
+		assert.NoError(t, err)
+
+		return NewStreamCache(engine, optFns...)
+	}
+
+	tokens := func(calls *atomic.Int32) StreamFunc[string] {
+		return func(ctx context.Context, send func(string) error) error {
+			calls.Add(1)
+
+			for _, token := range []string{"Hello", ", ", "world"} {
+				if err := send(token); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		}
+	}
+
+	t.Run("Miss And Hit", func(t *testing.T) {
+		cache := newCache()
+
+		var calls atomic.Int32
+
+		s := cache.Stream(ctx, "prompt", tokens(&calls))
+		assert.Equal(t, StreamMiss, s.Source())
+
+		chunks, err := collect(t, s)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Hello", ", ", "world"}, chunks)
+		assert.NoError(t, s.Close())
+
+		s = cache.Stream(ctx, "prompt", tokens(&calls))
+		assert.Equal(t, StreamHit, s.Source())
+
+		chunks, err = collect(t, s)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Hello", ", ", "world"}, chunks)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Shared", func(t *testing.T) {
+		cache := newCache()
+
+		release := make(chan struct{})
+
+		var calls atomic.Int32
+
+		fn := func(ctx context.Context, send func(string) error) error {
+			calls.Add(1)
+
+			if err := send("first"); err != nil {
+				return err
+			}
+
+			<-release
+
+			return send("second")
+		}
+
+		first := cache.Stream(ctx, "prompt", fn)
+		chunk, err := first.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "first", chunk)
+
+		second := cache.Stream(ctx, "prompt", fn)
+		assert.Equal(t, StreamShared, second.Source())
+
+		close(release)
+
+		var wg sync.WaitGroup
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			chunks, err := collect(t, second)
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"first", "second"}, chunks)
+		}()
+
+		chunks, err := collect(t, first)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"second"}, chunks)
+
+		wg.Wait()
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("Namespaces", func(t *testing.T) {
+		cache := newCache()
+
+		release := make(chan struct{})
+
+		stream := func(answer string) StreamFunc[string] {
+			return func(ctx context.Context, send func(string) error) error {
+				<-release
+				return send(answer)
+			}
+		}
+
+		// Identical prompts of different namespaces in progress do not share a stream
+		first := cache.Stream(WithNamespace(ctx, "tenant-a"), "prompt", stream("answer for tenant A"))
+		second := cache.Stream(WithNamespace(ctx, "tenant-b"), "prompt", stream("answer for tenant B"))
+		assert.Equal(t, StreamMiss, second.Source())
+
+		close(release)
+
+		chunks, err := collect(t, first)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"answer for tenant A"}, chunks)
+
+		chunks, err = collect(t, second)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"answer for tenant B"}, chunks)
+	})
+
+	t.Run("Failure", func(t *testing.T) {
+		cache := newCache()
+		failure := errors.New("upstream failure")
+
+		s := cache.Stream(ctx, "prompt", func(ctx context.Context, send func(string) error) error {
+			_ = send("partial")
+			return failure
+		})
+
+		chunks, err := collect(t, s)
+		assert.ErrorIs(t, err, failure)
+		assert.Equal(t, []string{"partial"}, chunks)
+
+		var calls atomic.Int32
+
+		s = cache.Stream(ctx, "prompt", tokens(&calls))
+		assert.Equal(t, StreamMiss, s.Source())
+
+		_, err = collect(t, s)
+		assert.NoError(t, err)
+	})
+
+	t.Run("Cache Write Failure", func(t *testing.T) {
+		engine, err := NewLRUEngine[*Recording[string]]()
+		assert.NoError(t, err)
+
+		var errs []error
+
+		cache := NewStreamCache[string](failingEngine[*Recording[string]]{engine}, func(o *StreamCacheOptions) {
+			o.OnError = func(err error) {
+				errs = append(errs, err)
+			}
+		})
+
+		var calls atomic.Int32
+
+		s := cache.Stream(ctx, "prompt", tokens(&calls))
+
+		// The stream succeeds although the recording is not cached
+		chunks, err := collect(t, s)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Hello", ", ", "world"}, chunks)
+
+		assert.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "update failed")
+
+		s = cache.Stream(ctx, "prompt", tokens(&calls))
+		assert.Equal(t, StreamMiss, s.Source())
+	})
+
+	t.Run("Close Cancels Producer", func(t *testing.T) {
+		cache := newCache()
+		canceled := make(chan error)
+
+		s := cache.Stream(ctx, "prompt", func(ctx context.Context, send func(string) error) error {
+			<-ctx.Done()
+			canceled <- ctx.Err()
+
+			return ctx.Err()
+		})
+
+		assert.NoError(t, s.Close())
+		assert.ErrorIs(t, <-canceled, context.Canceled)
+
+		_, err := s.Next(ctx)
+		assert.ErrorIs(t, err, io.ErrClosedPipe)
+	})
+
+	t.Run("Canceled Producer Ignoring Send Errors", func(t *testing.T) {
+		cache := newCache()
+
+		s := cache.Stream(ctx, "prompt", func(ctx context.Context, send func(string) error) error {
+			_ = send("partial")
+			<-ctx.Done()
+			_ = send("ignored")
+
+			return nil
+		})
+
+		chunk, err := s.Next(ctx)
+		assert.NoError(t, err)
+		assert.Equal(t, "partial", chunk)
+		assert.NoError(t, s.Close())
+
+		assert.Eventually(t, func() bool {
+			cache.mu.Lock()
+			defer cache.mu.Unlock()
+
+			return len(cache.inflight) == 0
+		}, time.Second, time.Millisecond)
+
+		// The truncated stream is not cached
+		var calls atomic.Int32
+
+		s = cache.Stream(ctx, "prompt", tokens(&calls))
+		assert.Equal(t, StreamMiss, s.Source())
+
+		chunks, err := collect(t, s)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"Hello", ", ", "world"}, chunks)
+	})
+
+	t.Run("Pacing", func(t *testing.T) {
+		cache := newCache(func(o *StreamCacheOptions) {
+			o.Pacing = 1
+		})
+
+		delayed := func(ctx context.Context, send func(string) error) error {
+			_ = send("a")
+			time.Sleep(50 * time.Millisecond)
+
+			return send("b")
+		}
+
+		_, err := collect(t, cache.Stream(ctx, "prompt", delayed))
+		assert.NoError(t, err)
+
+		start := time.Now()
+		s := cache.Stream(ctx, "prompt", delayed)
+		assert.Equal(t, StreamHit, s.Source())
+
+		chunks, err := collect(t, s)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"a", "b"}, chunks)
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("Chan", func(t *testing.T) {
+		cache := newCache()
+
+		var (
+			calls  atomic.Int32
+			chunks []string
+		)
+
+		for chunk := range cache.Stream(ctx, "prompt", tokens(&calls)).Chan(ctx) {
+			chunks = append(chunks, chunk)
+		}
+
+		assert.Equal(t, []string{"Hello", ", ", "world"}, chunks)
+	})
+}
+
+func TestStreamSource_String(t *testing.T) {
+	assert.Equal(t, "miss", StreamMiss.String())
+	assert.Equal(t, "shared", StreamShared.String())
+	assert.Equal(t, "hit", StreamHit.String())
+	assert.Equal(t, "unknown", StreamSource(-1).String())
+}
@@ -0,0 +1,356 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+)
+
+// Compile time check to ensure TieredEngine satisfies the Engine interface.
+var _ Engine[any] = (*TieredEngine[any])(nil)
+
+// Compile time check to ensure TieredEngine satisfies the Matcher interface.
+var _ Matcher[any] = (*TieredEngine[any])(nil)
+
+// Compile time check to ensure TieredEngine satisfies the Deleter interface.
+var _ Deleter = (*TieredEngine[any])(nil)
+
+// ErrClosed is returned when an engine is used after it was closed.
+var ErrClosed = errors.New("engine is closed")
+
+// TieredEngineOptions contains options for configuring the TieredEngine.
+type TieredEngineOptions struct {
+	// WriteBehind writes updates to the second tier asynchronously. By default,
+	// Update returns after both tiers are written (write-through).
+	WriteBehind bool
+	// QueueSize is the number of queued write-behind updates. If the queue is full,
+	// the update is written synchronously. Default is 1024.
+	QueueSize int
+	// OnError is called with the errors of write-behind updates and promotions.
+	OnError func(err error)
+}
+
+// TieredStats contains the usage statistics of the tiers of a TieredEngine.
+type TieredStats struct {
+	// L1 contains the statistics of the first tier.
+	L1 Stats `json:"l1"`
+	// L2 contains the statistics of the second tier. Only misses of the first tier are looked up.
+	L2 Stats `json:"l2"`
+	// Promotions is the number of hits of the second tier written to the first tier.
+	Promotions int64 `json:"promotions"`
+	// Errors is the number of failed write-behind updates and promotions.
+	Errors int64 `json:"errors"`
+}
+
+// TieredEngine is a cache engine implementation which composes a fast first tier,
+// e.g. an in-process LRUEngine, with a shared second tier, e.g. a RemoteEngine.
+// Lookups check the first tier, then the second tier, and promote hits of the second
+// tier into the first tier under the looked up prompt.
+type TieredEngine[T comparable] struct {
+	// l1 is the first tier.
+	l1 Engine[T]
+	// l2 is the second tier.
+	l2 Engine[T]
+	// opts contains options for configuring the TieredEngine.
+	opts TieredEngineOptions
+	// stats holds the counters of each tier.
+	stats [2]tierCounters
+	// promotions is the number of promoted hits.
+	promotions atomic.Int64
+	// errors is the number of failed asynchronous writes.
+	errors atomic.Int64
+	// queue holds the write-behind updates, or is nil for write-through.
+	queue chan tieredWrite[T]
+	// mu guards closed and sending to the queue.
+	mu     sync.RWMutex
+	closed bool
+	// done is closed when the write-behind worker stopped.
+	done chan struct{}
+}
+
+// tierCounters counts the operations of a tier.
+type tierCounters struct {
+	lookups atomic.Int64
+	hits    atomic.Int64
+	updates atomic.Int64
+	deletes atomic.Int64
+}
+
+// tieredWrite is a queued write-behind update, or a flush marker if flushed is set.
+type tieredWrite[T comparable] struct {
+	ctx     context.Context
+	prompt  string
+	result  T
+	flushed chan struct{}
+}
+
+// NewTieredEngine creates a new TieredEngine instance with the provided tiers and options.
+// Engines with write-behind must be closed to stop the background writer.
+func NewTieredEngine[T comparable](l1, l2 Engine[T], optFns ...func(o *TieredEngineOptions)) (*TieredEngine[T], error) {
+	if l1 == nil || l2 == nil {
+		return nil, errors.New("both tiers are required")
+	}
+
+	opts := TieredEngineOptions{
+		QueueSize: 1024,
+	}
+
+	for _, fn := range optFns {
+		fn(&opts)
+	}
+
+	if opts.QueueSize <= 0 {
+		opts.QueueSize = 1024
+	}
+
+	e := &TieredEngine[T]{
+		l1:   l1,
+		l2:   l2,
+		opts: opts,
+	}
+
+	if opts.WriteBehind {
+		e.queue = make(chan tieredWrite[T], opts.QueueSize)
+		e.done = make(chan struct{})
+
+		go e.writeBehind()
+	}
+
+	return e, nil
+}
+
+// Lookup retrieves the cached result associated with the given prompt.
+// It returns the result and a boolean indicating whether the result was found.
+func (e *TieredEngine[T]) Lookup(ctx context.Context, prompt string) (T, bool) {
+	match, ok := e.LookupMatch(ctx, prompt)
+	return match.Result, ok
+}
+
+// LookupMatch retrieves the cached entry matching the given prompt from the first tier
+// holding it. A hit of the second tier is written to the first tier.
+func (e *TieredEngine[T]) LookupMatch(ctx context.Context, prompt string) (Match[T], bool) {
+	e.stats[0].lookups.Add(1)
+
+	if match, ok := lookupMatch(ctx, e.l1, prompt); ok {
+		e.stats[0].hits.Add(1)
+		return match, true
+	}
+
+	e.stats[1].lookups.Add(1)
+
+	match, ok := lookupMatch(ctx, e.l2, prompt)
+	if !ok {
+		return Match[T]{}, false
+	}
+
+	e.stats[1].hits.Add(1)
+
+	if err := e.l1.Update(ctx, prompt, match.Result); err != nil {
+		e.onError(fmt.Errorf("promote: %w", err))
+	} else {
+		e.promotions.Add(1)
+	}
+
+	return match, true
+}
+
+// Update updates both tiers with the provided prompt and result. With write-behind,
+// the second tier is updated asynchronously and its errors are reported to OnError.
+func (e *TieredEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	if err := e.l1.Update(ctx, prompt, result); err != nil {
+		return err
+	}
+
+	e.stats[0].updates.Add(1)
+
+	if e.queue != nil {
+		e.mu.RLock()
+		defer e.mu.RUnlock()
+
+		if e.closed {
+			return ErrClosed
+		}
+
+		select {
+		case e.queue <- tieredWrite[T]{ctx: context.WithoutCancel(ctx), prompt: prompt, result: result}:
+			return nil
+		default:
+			// The queue is full, so write synchronously
+		}
+	}
+
+	if err := e.l2.Update(ctx, prompt, result); err != nil {
+		return err
+	}
+
+	e.stats[1].updates.Add(1)
+
+	return nil
+}
+
+// Delete removes the entry of the given prompt from both tiers. Queued updates are written first.
+// It returns an error if a tier does not implement Deleter.
+func (e *TieredEngine[T]) Delete(ctx context.Context, prompt string) error {
+	if err := e.Flush(ctx); err != nil {
+		return err
+	}
+
+	for i, tier := range []Engine[T]{e.l1, e.l2} {
+		deleter, ok := tier.(Deleter)
+		if !ok {
+			return fmt.Errorf("tier %d does not support delete", i+1)
+		}
+
+		if err := deleter.Delete(ctx, prompt); err != nil {
+			return err
+		}
+
+		e.stats[i].deletes.Add(1)
+	}
+
+	return nil
+}
+
+// Clear clears both tiers, removing all entries. Queued updates are written first.
+// It returns an error if the clear operation fails.
+func (e *TieredEngine[T]) Clear(ctx context.Context) error {
+	if err := e.Flush(ctx); err != nil {
+		return err
+	}
+
+	return errors.Join(e.l1.Clear(ctx), e.l2.Clear(ctx))
+}
+
+// ClearNamespace removes all entries of the namespace from both tiers. Queued updates are written first.
+// It returns an error if a tier does not support namespaces.
+func (e *TieredEngine[T]) ClearNamespace(ctx context.Context, namespace string) error {
+	if err := e.Flush(ctx); err != nil {
+		return err
+	}
+
+	for i, tier := range []Engine[T]{e.l1, e.l2} {
+		clearer, ok := tier.(interface {
+			ClearNamespace(ctx context.Context, namespace string) error
+		})
+		if !ok {
+			return fmt.Errorf("tier %d: %w", i+1, ErrNamespacesNotSupported)
+		}
+
+		if err := clearer.ClearNamespace(ctx, namespace); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Flush waits until all queued write-behind updates are written to the second tier.
+func (e *TieredEngine[T]) Flush(ctx context.Context) error {
+	if e.queue == nil {
+		return nil
+	}
+
+	flushed := make(chan struct{})
+
+	e.mu.RLock()
+
+	if e.closed {
+		e.mu.RUnlock()
+		<-e.done // Close writes all queued updates
+
+		return nil
+	}
+
+	select {
+	case e.queue <- tieredWrite[T]{flushed: flushed}:
+		e.mu.RUnlock()
+	case <-ctx.Done():
+		e.mu.RUnlock()
+		return ctx.Err()
+	}
+
+	select {
+	case <-flushed:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close writes all queued updates and stops the write-behind worker.
+func (e *TieredEngine[T]) Close() error {
+	if e.queue == nil {
+		return nil
+	}
+
+	e.mu.Lock()
+
+	if !e.closed {
+		e.closed = true
+		close(e.queue)
+	}
+
+	e.mu.Unlock()
+
+	<-e.done
+
+	return nil
+}
+
+// Stats returns the usage statistics of the tiers. The number of entries
+// is only reported for tiers with a Len method.
+func (e *TieredEngine[T]) Stats() TieredStats {
+	return TieredStats{
+		L1:         e.stats[0].snapshot(e.l1),
+		L2:         e.stats[1].snapshot(e.l2),
+		Promotions: e.promotions.Load(),
+		Errors:     e.errors.Load(),
+	}
+}
+
+// snapshot returns the statistics of the tier.
+func (c *tierCounters) snapshot(tier any) Stats {
+	s := Stats{
+		Lookups: c.lookups.Load(),
+		Hits:    c.hits.Load(),
+		Updates: c.updates.Load(),
+		Deletes: c.deletes.Load(),
+	}
+	s.Misses = s.Lookups - s.Hits
+
+	if l, ok := tier.(interface{ Len() int }); ok {
+		s.Entries = l.Len()
+	}
+
+	return s
+}
+
+// writeBehind writes the queued updates to the second tier until the queue is closed.
+func (e *TieredEngine[T]) writeBehind() {
+	defer close(e.done)
+
+	for w := range e.queue {
+		if w.flushed != nil {
+			close(w.flushed)
+			continue
+		}
+
+		if err := e.l2.Update(w.ctx, w.prompt, w.result); err != nil {
+			e.onError(fmt.Errorf("write behind: %w", err))
+			continue
+		}
+
+		e.stats[1].updates.Add(1)
+	}
+}
+
+// onError counts and reports an error of an asynchronous write or a promotion.
+func (e *TieredEngine[T]) onError(err error) {
+	e.errors.Add(1)
+
+	if e.opts.OnError != nil {
+		e.opts.OnError(err)
+	}
+}
@@ -0,0 +1,205 @@
+package llmcache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// failingEngine is an engine whose updates fail.
+type failingEngine[T comparable] struct {
+	Engine[T]
+}
+
+func (e failingEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	return errors.New("update failed")
+}
+
+// blockingEngine blocks its updates until released.
+type blockingEngine[T comparable] struct {
+	*LRUEngine[T]
+	release chan struct{}
+}
+
+func (e blockingEngine[T]) Update(ctx context.Context, prompt string, result T) error {
+	<-e.release
+	return e.LRUEngine.Update(ctx, prompt, result)
+}
+
+func TestTieredEngine(t *testing.T) {
+	ctx := context.TODO()
+
+	newTiers := func(t *testing.T) (*LRUEngine[string], *LRUEngine[string]) {
+		t.Helper()
+
+		l1, err := NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		l2, err := NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		return l1, l2
+	}
+
+	t.Run("Write Through And Promotion", func(t *testing.T) {
+		l1, l2 := newTiers(t)
+
+		engine, err := NewTieredEngine[string](l1, l2)
+		assert.NoError(t, err)
+
+		err = engine.Update(ctx, "prompt", "result")
+		assert.NoError(t, err)
+		assert.Equal(t, 1, l1.Len())
+		assert.Equal(t, 1, l2.Len())
+
+		// Another replica wrote to the shared tier
+		err = l2.Update(ctx, "other", "shared")
+		assert.NoError(t, err)
+
+		result, ok := engine.Lookup(ctx, "other")
+		assert.True(t, ok)
+		assert.Equal(t, "shared", result)
+
+		result, ok = l1.Lookup(ctx, "other")
+		assert.True(t, ok)
+		assert.Equal(t, "shared", result)
+
+		_, ok = engine.Lookup(ctx, "other")
+		assert.True(t, ok)
+
+		_, ok = engine.Lookup(ctx, "missing")
+		assert.False(t, ok)
+
+		assert.Equal(t, TieredStats{
+			L1:         Stats{Lookups: 3, Hits: 1, Misses: 2, Updates: 1, Entries: 2},
+			L2:         Stats{Lookups: 2, Hits: 1, Misses: 1, Updates: 1, Entries: 2},
+			Promotions: 1,
+		}, engine.Stats())
+	})
+
+	t.Run("Semantic Second Tier", func(t *testing.T) {
+		l1, err := NewLRUEngine[string]()
+		assert.NoError(t, err)
+
+		l2, err := NewLRUSimilarityEngine[string](NewHashEmbedder(), func(o *LRUSimilarityEngineOptions) {
+			o.Threshold = 0.5
+		})
+		assert.NoError(t, err)
+
+		engine, err := NewTieredEngine[string](l1, l2)
+		assert.NoError(t, err)
+
+		err = engine.Update(ctx, "What year was Albert Einstein born?", "1879")
+		assert.NoError(t, err)
+
+		match, ok := engine.LookupMatch(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.False(t, match.Exact)
+		assert.Equal(t, "What year was Albert Einstein born?", match.Prompt)
+
+		// The hit is promoted under the looked up prompt
+		result, ok := l1.Lookup(ctx, "In what year was Albert Einstein born?")
+		assert.True(t, ok)
+		assert.Equal(t, "1879", result)
+	})
+
+	t.Run("Delete And Clear", func(t *testing.T) {
+		l1, l2 := newTiers(t)
+
+		engine, err := NewTieredEngine[string](l1, l2)
+		assert.NoError(t, err)
+
+		assert.NoError(t, engine.Update(ctx, "a", "1"))
+		assert.NoError(t, engine.Update(ctx, "b", "2"))
+
+		assert.NoError(t, engine.Delete(ctx, "a"))
+		assert.Equal(t, 1, l1.Len())
+		assert.Equal(t, 1, l2.Len())
+
+		assert.NoError(t, engine.Clear(ctx))
+		assert.Equal(t, 0, l1.Len())
+		assert.Equal(t, 0, l2.Len())
+
+		withoutDelete, err := NewTieredEngine[string](l1, lookupOnly[string]{l2})
+		assert.NoError(t, err)
+		assert.ErrorContains(t, withoutDelete.Delete(ctx, "a"), "tier 2 does not support delete")
+	})
+
+	t.Run("Write Behind", func(t *testing.T) {
+		l1, l2 := newTiers(t)
+		release := make(chan struct{})
+
+		engine, err := NewTieredEngine[string](l1, blockingEngine[string]{l2, release}, func(o *TieredEngineOptions) {
+			o.WriteBehind = true
+		})
+		assert.NoError(t, err)
+
+		// The update returns before the second tier is written
+		assert.NoError(t, engine.Update(ctx, "prompt", "result"))
+		assert.Equal(t, 1, l1.Len())
+		assert.Equal(t, 0, l2.Len())
+
+		close(release)
+
+		assert.NoError(t, engine.Flush(ctx))
+		assert.Equal(t, 1, l2.Len())
+
+		assert.NoError(t, engine.Update(ctx, "other", "result"))
+		assert.NoError(t, engine.Close())
+		assert.Equal(t, 2, l2.Len())
+
+		assert.ErrorIs(t, engine.Update(ctx, "late", "result"), ErrClosed)
+		assert.NoError(t, engine.Flush(ctx))
+		assert.NoError(t, engine.Close())
+	})
+
+	t.Run("Write Behind Errors", func(t *testing.T) {
+		l1, l2 := newTiers(t)
+
+		var (
+			mu   sync.Mutex
+			errs []error
+		)
+
+		engine, err := NewTieredEngine[string](l1, failingEngine[string]{l2}, func(o *TieredEngineOptions) {
+			o.WriteBehind = true
+			o.OnError = func(err error) {
+				mu.Lock()
+				defer mu.Unlock()
+
+				errs = append(errs, err)
+			}
+		})
+		assert.NoError(t, err)
+
+		assert.NoError(t, engine.Update(ctx, "prompt", "result"))
+		assert.NoError(t, engine.Close())
+
+		assert.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], "write behind: update failed")
+		assert.Equal(t, int64(1), engine.Stats().Errors)
+	})
+
+	t.Run("Write Through Error", func(t *testing.T) {
+		l1, l2 := newTiers(t)
+
+		engine, err := NewTieredEngine[string](l1, failingEngine[string]{l2})
+		assert.NoError(t, err)
+		assert.Error(t, engine.Update(ctx, "prompt", "result"))
+	})
+
+	t.Run("Missing Tier", func(t *testing.T) {
+		l1, _ := newTiers(t)
+
+		_, err := NewTieredEngine[string](l1, nil)
+		assert.Error(t, err)
+	})
+}
+
+// lookupOnly hides all optional interfaces of an engine.
+type lookupOnly[T comparable] struct {
+	Engine[T]
+}
@@ -0,0 +1,134 @@
+package llmcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// VectorStore is a side store for the full precision embeddings of quantized cache entries.
+type VectorStore interface {
+	// Get returns the embedding stored for the prompt, or nil if there is none.
+	Get(ctx context.Context, prompt string) ([]float32, error)
+
+	// Set stores the embedding for the prompt.
+	Set(ctx context.Context, prompt string, embedding []float32) error
+
+	// Delete removes the embedding of the prompt.
+	Delete(ctx context.Context, prompt string) error
+}
+
+// Compile time check to ensure MemoryVectorStore satisfies the VectorStore interface.
+var _ VectorStore = (*MemoryVectorStore)(nil)
+
+// MemoryVectorStore is a VectorStore keeping the embeddings in memory.
+type MemoryVectorStore struct {
+	mu         sync.RWMutex
+	embeddings map[string][]float32
+}
+
+// NewMemoryVectorStore creates a new MemoryVectorStore instance.
+func NewMemoryVectorStore() *MemoryVectorStore {
+	return &MemoryVectorStore{
+		embeddings: make(map[string][]float32),
+	}
+}
+
+// Get returns the embedding stored for the prompt, or nil if there is none.
+func (s *MemoryVectorStore) Get(ctx context.Context, prompt string) ([]float32, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.embeddings[prompt], nil
+}
+
+// Set stores the embedding for the prompt.
+func (s *MemoryVectorStore) Set(ctx context.Context, prompt string, embedding []float32) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.embeddings[prompt] = embedding
+
+	return nil
+}
+
+// Delete removes the embedding of the prompt.
+func (s *MemoryVectorStore) Delete(ctx context.Context, prompt string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.embeddings, prompt)
+
+	return nil
+}
+
+// Compile time check to ensure FileVectorStore satisfies the VectorStore interface.
+var _ VectorStore = (*FileVectorStore)(nil)
+
+// FileVectorStore is a VectorStore keeping each embedding in a file of a local directory.
+type FileVectorStore struct {
+	dir string
+}
+
+// NewFileVectorStore creates a new FileVectorStore instance storing the embeddings in dir.
+// The directory is created if it does not exist.
+func NewFileVectorStore(dir string) (*FileVectorStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+
+	return &FileVectorStore{
+		dir: dir,
+	}, nil
+}
+
+// Get returns the embedding stored for the prompt, or nil if there is none.
+func (s *FileVectorStore) Get(ctx context.Context, prompt string) ([]float32, error) {
+	b, err := os.ReadFile(s.path(prompt))
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+
+		return nil, err
+	}
+
+	embedding := make([]float32, len(b)/4)
+	for i := range embedding {
+		embedding[i] = math.Float32frombits(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+
+	return embedding, nil
+}
+
+// Set stores the embedding for the prompt.
+func (s *FileVectorStore) Set(ctx context.Context, prompt string, embedding []float32) error {
+	b := make([]byte, len(embedding)*4)
+	for i, v := range embedding {
+		binary.LittleEndian.PutUint32(b[i*4:], math.Float32bits(v))
+	}
+
+	return os.WriteFile(s.path(prompt), b, 0o600)
+}
+
+// Delete removes the embedding of the prompt.
+func (s *FileVectorStore) Delete(ctx context.Context, prompt string) error {
+	if err := os.Remove(s.path(prompt)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+// path returns the file name of the prompt's embedding.
+func (s *FileVectorStore) path(prompt string) string {
+	sum := sha256.Sum256([]byte(prompt))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".vec")
+}
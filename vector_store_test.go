@@ -0,0 +1,46 @@
+package llmcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVectorStore(t *testing.T) {
+	fileStore, err := NewFileVectorStore(t.TempDir())
+	assert.NoError(t, err)
+
+	stores := map[string]VectorStore{
+		"Memory": NewMemoryVectorStore(),
+		"File":   fileStore,
+	}
+
+	for name, store := range stores {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.TODO()
+			embedding := []float32{0.1, -0.2, 0.3}
+
+			v, err := store.Get(ctx, "prompt")
+			assert.NoError(t, err)
+			assert.Nil(t, v)
+
+			err = store.Set(ctx, "prompt", embedding)
+			assert.NoError(t, err)
+
+			v, err = store.Get(ctx, "prompt")
+			assert.NoError(t, err)
+			assert.Equal(t, embedding, v)
+
+			err = store.Delete(ctx, "prompt")
+			assert.NoError(t, err)
+
+			v, err = store.Get(ctx, "prompt")
+			assert.NoError(t, err)
+			assert.Nil(t, v)
+
+			err = store.Delete(ctx, "prompt")
+			assert.NoError(t, err)
+		})
+	}
+}